@@ -0,0 +1,147 @@
+package tree_sitter_sand
+
+import (
+	"fmt"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Range is a span of source text. Byte offsets are half-open and columns are
+// counted in bytes, as everywhere in tree-sitter.
+type Range = tree_sitter.Range
+
+// Node is an element of the typed document tree returned by [Parse]: a
+// *Document, *Section, *Paragraph or *InlineSpan.
+type Node interface {
+	Span() Range
+}
+
+// Document is the root of a parsed Sand document.
+type Document struct {
+	Range Range
+
+	// Names are the identifiers declared by the name definition (`#(en, ja)`).
+	// If the document defines names more than once, the first definition is
+	// used. Names is nil when there is no definition at all.
+	Names      []string
+	NamesRange Range
+
+	// Children holds the *Section and *Paragraph nodes before the first
+	// section and the top-level sections.
+	Children []Node
+
+	// Errors lists the ERROR and MISSING nodes of the tree in document order.
+	Errors []ParseError
+
+	// Replaced lists the runs of invalid UTF-8 replaced with U+FFFD when
+	// parsing with [InvalidUTF8Replace], in document order.
+	Replaced []Replacement
+}
+
+// Section is a heading together with everything up to the next heading of the
+// same or a lower level.
+type Section struct {
+	// Range spans from the heading to the end of the last child.
+	Range   Range
+	Heading Range
+
+	Alias string
+	// Level is the number of hashes after the leading `#` and the optional
+	// alias, so both `## Title` and `#intro# Title` are level 1.
+	Level      int
+	Title      string
+	TitleRange Range
+
+	// Children holds *Paragraph and nested *Section nodes.
+	Children []Node
+}
+
+// Paragraph is a run of inline spans that is not interrupted by a blank line,
+// a heading or a name definition.
+type Paragraph struct {
+	Range Range
+	Spans []*InlineSpan
+}
+
+// SpanKind identifies the construct an InlineSpan was parsed from.
+type SpanKind int
+
+const (
+	// SpanText is plain prose (a non_escaped_string).
+	SpanText SpanKind = iota
+	// SpanSentence is a sentence definition: `#alias[...][...]`.
+	SpanSentence
+	// SpanApplyAll is an apply-all block: `#alias{[en], {...}}`.
+	SpanApplyAll
+	// SpanSelector is a selector: `#./path.to.name`.
+	SpanSelector
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanText:
+		return "text"
+	case SpanSentence:
+		return "sentence"
+	case SpanApplyAll:
+		return "apply_all"
+	case SpanSelector:
+		return "selector"
+	default:
+		return fmt.Sprintf("SpanKind(%d)", int(k))
+	}
+}
+
+// InlineSpan is a single construct inside a paragraph.
+type InlineSpan struct {
+	Kind  SpanKind
+	Range Range
+
+	// Text is the unescaped text of a SpanText span.
+	Text string
+
+	// Alias is the optional alias of a sentence or apply-all span.
+	Alias string
+	// Contents holds the bracketed contents of a sentence, one per name, or
+	// the single content of an apply-all span.
+	Contents []Content
+	// Targets lists the names an apply-all span is restricted to. It is nil
+	// when the span applies to all names.
+	Targets []string
+
+	// Local reports whether a selector starts from the current section (`#./`).
+	Local bool
+	// Path holds the aliases and indexes of a selector.
+	Path []string
+	// TrailingDot reports whether a selector ends with `.`, selecting every name.
+	TrailingDot bool
+}
+
+// Content is one bracketed content of a sentence or apply-all span.
+type Content struct {
+	Range Range
+	// Text is the content with escape sequences resolved.
+	Text string
+}
+
+// ParseError is an ERROR or MISSING node found in the tree.
+type ParseError struct {
+	Range Range
+	// Missing reports whether the parser inserted a missing token; Expected
+	// then names that token.
+	Missing  bool
+	Expected string
+}
+
+func (e ParseError) Error() string {
+	pos := e.Range.StartPoint
+	if e.Missing {
+		return fmt.Sprintf("sand: %d:%d: missing %q", pos.Row+1, pos.Column+1, e.Expected)
+	}
+	return fmt.Sprintf("sand: %d:%d: syntax error", pos.Row+1, pos.Column+1)
+}
+
+func (d *Document) Span() Range   { return d.Range }
+func (s *Section) Span() Range    { return s.Range }
+func (p *Paragraph) Span() Range  { return p.Range }
+func (s *InlineSpan) Span() Range { return s.Range }
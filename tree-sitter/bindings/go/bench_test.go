@@ -0,0 +1,101 @@
+package tree_sitter_sand_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/query"
+)
+
+// Fixture sizes for the benchmarks of the hot paths. Run them with
+//
+//	go test -run '^$' -bench . -benchmem
+const (
+	smallSize  = 1 << 10
+	mediumSize = 100 << 10
+	largeSize  = 5 << 20
+)
+
+// benchmarkParse measures parsing src into a tree alone and into a
+// Document. Nearly all of the time is spent in the C parser; building the
+// Document adds about a quarter on top, mostly allocating nodes.
+//
+// Looking kinds up by symbol id in NodeKind instead of copying them out of
+// C for every node cut the allocations of the document benchmarks:
+//
+//	                    before                   after
+//	Small/document     33296 B/op     830 allocs    31040 B/op     613 allocs
+//	Medium/document  3006784 B/op   72554 allocs  2803037 B/op   52978 allocs
+//	Large/document 149365664 B/op 3525301 allocs 139495176 B/op 2573577 allocs
+func benchmarkParse(b *testing.B, size int) {
+	src := generate(size)
+	b.Run("tree", func(b *testing.B) {
+		b.SetBytes(int64(len(src)))
+		b.ReportAllocs()
+		for range b.N {
+			tree, err := tree_sitter_sand.ParseTree(src)
+			if err != nil {
+				b.Fatal(err)
+			}
+			tree.Close()
+		}
+	})
+	b.Run("document", func(b *testing.B) {
+		b.SetBytes(int64(len(src)))
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := tree_sitter_sand.Parse(src); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkParseSmall(b *testing.B)  { benchmarkParse(b, smallSize) }
+func BenchmarkParseMedium(b *testing.B) { benchmarkParse(b, mediumSize) }
+func BenchmarkParseLarge(b *testing.B)  { benchmarkParse(b, largeSize) }
+
+func BenchmarkHighlightQuery(b *testing.B) {
+	q, err := query.Compile(tree_sitter_sand.HighlightsQuery())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer q.Close()
+	src := generate(mediumSize)
+
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for range b.N {
+		for range q.Matches(src) {
+		}
+	}
+}
+
+// BenchmarkParseObserver measures what observing a parse costs. Without an
+// observer the hooks allocate nothing, so "none" allocates as much as
+// BenchmarkParseSmall/document did before there were hooks; an observer adds
+// the metadata map and the walk counting the nodes.
+func BenchmarkParseObserver(b *testing.B) {
+	src := generate(smallSize)
+	for _, bb := range []struct {
+		name     string
+		observer tree_sitter_sand.Observer
+	}{
+		{"none", nil},
+		{"nop", tree_sitter_sand.NopObserver{}},
+		{"slog", tree_sitter_sand.SlogObserver{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			opts := tree_sitter_sand.ParseOptions{Observer: bb.observer}
+			b.SetBytes(int64(len(src)))
+			b.ReportAllocs()
+			for range b.N {
+				if _, err := tree_sitter_sand.ParseWithOptions(src, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,385 @@
+package tree_sitter_sand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Builder generates Sand markup programmatically. Strings passed to it are
+// escaped, so the result parses back to the same titles, text and contents
+// whatever characters they hold.
+//
+//	b := NewBuilder().Names("en", "ja")
+//	b.Section("intro", "Introduction", func(b *Builder) {
+//		b.Paragraph("Released on 2025-01-01 #1")
+//		b.Sentence("s1", "Hello.", "こんにちは。")
+//	})
+//	src, err := b.Bytes()
+//
+// Sections nest through [Builder.Section] or [Builder.BeginSection] and
+// [Builder.EndSection]; their level follows the nesting. Inline spans added
+// between [Builder.BeginParagraph] and [Builder.EndParagraph] share a
+// paragraph, and outside of one each forms a paragraph of its own.
+//
+// A call that would produce invalid or ambiguous markup records an error and
+// turns the rest of the calls into no-ops; [Builder.Bytes] and [Builder.Err]
+// report it.
+type Builder struct {
+	buf   bytes.Buffer
+	err   error
+	names bool
+	// depth is the number of open sections.
+	depth int
+	// closed reports whether a section was closed since the last heading.
+	// Content can't follow it: the parser would put it in that section.
+	closed bool
+	para   bool
+	// last is the kind of the last span written to the paragraph, and
+	// spans the number of them.
+	last  SpanKind
+	spans int
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+func (b *Builder) fail(format string, args ...any) {
+	if b.err == nil {
+		b.err = fmt.Errorf("sand: builder: "+format, args...)
+	}
+}
+
+// text fails if one of strs can't be written at all.
+func (b *Builder) text(strs ...string) bool {
+	for _, s := range strs {
+		if strings.IndexByte(s, 0) >= 0 {
+			// Tree-sitter reads NUL as the end of input.
+			b.fail("NUL byte in %q", s)
+			return false
+		}
+		if !utf8.ValidString(s) {
+			b.fail("invalid UTF-8 in %q", s)
+			return false
+		}
+	}
+	return b.err == nil
+}
+
+// block starts a top-level construct, failing if it can't appear here.
+func (b *Builder) block(what string) bool {
+	switch {
+	case b.err != nil:
+		return false
+	case b.para:
+		b.fail("%s inside a paragraph", what)
+		return false
+	}
+	if b.buf.Len() > 0 {
+		b.buf.WriteByte('\n')
+	}
+	return true
+}
+
+// content is block for constructs that belong to the current section.
+func (b *Builder) content(what string) bool {
+	if b.err == nil && !b.para && b.closed {
+		b.fail("%s after a closed section would belong to it", what)
+	}
+	return b.block(what)
+}
+
+// Names writes the name definition. It must come first.
+func (b *Builder) Names(names ...string) *Builder {
+	switch {
+	case b.err != nil:
+		return b
+	case b.names || b.buf.Len() > 0 || b.para:
+		b.fail("names must be defined first and once")
+		return b
+	case len(names) == 0:
+		b.fail("no names")
+		return b
+	}
+	for _, name := range names {
+		if !isIdentifier(name) {
+			b.fail("invalid name %q", name)
+			return b
+		}
+	}
+	b.names = true
+	fmt.Fprintf(&b.buf, "#(%s)\n", strings.Join(names, ", "))
+	return b
+}
+
+// Section adds a section with an optional alias nested in the current one,
+// and calls body to fill it.
+func (b *Builder) Section(alias, title string, body func(b *Builder)) *Builder {
+	b.BeginSection(alias, title)
+	if b.err == nil {
+		body(b)
+	}
+	return b.EndSection()
+}
+
+// BeginSection writes the heading of a section with an optional alias nested
+// in the current one. Content added up to the matching [Builder.EndSection]
+// belongs to it.
+func (b *Builder) BeginSection(alias, title string) *Builder {
+	title = strings.TrimFunc(title, func(r rune) bool { return r < 0x80 && isSpace(byte(r)) })
+	switch {
+	case b.err != nil:
+	case alias != "" && !isIdentifier(alias):
+		b.fail("invalid alias %q", alias)
+	case title == "":
+		b.fail("empty section title")
+	}
+	if !b.text(title) || !b.block("section") {
+		return b
+	}
+	b.depth++
+	b.closed = false
+	b.buf.WriteString("#" + alias + strings.Repeat("#", b.depth) + " ")
+	// A title ends at the line break.
+	escape(&b.buf, title, "\\]}\n")
+	b.buf.WriteByte('\n')
+	return b
+}
+
+// EndSection closes the innermost open section. Only sections can follow
+// until its parent is closed too.
+func (b *Builder) EndSection() *Builder {
+	switch {
+	case b.err != nil:
+	case b.para:
+		b.fail("section closed inside a paragraph")
+	case b.depth == 0:
+		b.fail("no open section")
+	default:
+		b.depth--
+		b.closed = true
+	}
+	return b
+}
+
+// Paragraph adds a paragraph of prose.
+func (b *Builder) Paragraph(text string) *Builder {
+	return b.BeginParagraph().Text(text).EndParagraph()
+}
+
+// BeginParagraph starts a paragraph that spans added up to the matching
+// [Builder.EndParagraph] share.
+func (b *Builder) BeginParagraph() *Builder {
+	if b.content("paragraph") {
+		b.para = true
+		b.spans = 0
+	}
+	return b
+}
+
+// EndParagraph ends the paragraph started by [Builder.BeginParagraph].
+func (b *Builder) EndParagraph() *Builder {
+	switch {
+	case b.err != nil:
+	case !b.para:
+		b.fail("no open paragraph")
+	default:
+		b.para = false
+		if b.spans == 0 {
+			// Undo the separator written by BeginParagraph.
+			if b.buf.Len() > 0 {
+				b.buf.Truncate(b.buf.Len() - 1)
+			}
+			break
+		}
+		b.buf.WriteByte('\n')
+	}
+	return b
+}
+
+// inline runs write as a span of kind, in its own paragraph when none is
+// open.
+func (b *Builder) inline(kind SpanKind, write func()) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if b.para {
+		write()
+		b.last = kind
+		b.spans++
+		return b
+	}
+	return b.BeginParagraph().inline(kind, write).EndParagraph()
+}
+
+// Text adds prose to the paragraph.
+func (b *Builder) Text(text string) *Builder {
+	if text == "" || !b.text(text) {
+		return b
+	}
+	if b.para && b.spans > 0 {
+		// Keep the text from extending the span before it. Spaces and tabs
+		// may separate the parts of both.
+		trimmed := strings.TrimLeft(text, " \t")
+		switch {
+		case b.last == SpanSentence && strings.HasPrefix(trimmed, "["),
+			b.last == SpanSelector && (isIdentByte(text[0]) || strings.HasPrefix(trimmed, ".") || strings.HasPrefix(trimmed, "/")):
+			b.buf.WriteByte('\n')
+		}
+	}
+	return b.inline(SpanText, func() {
+		for i := 0; i < len(text); i++ {
+			switch c := text[i]; {
+			case c == '#' || c == '\\':
+				b.buf.WriteByte('\\')
+				b.buf.WriteByte(c)
+			case c == '\n' && b.blankLine():
+				// A blank line would end the paragraph.
+				b.buf.WriteString(`\n`)
+			default:
+				b.buf.WriteByte(c)
+			}
+		}
+	})
+}
+
+// blankLine reports whether the output ends in a line holding only
+// whitespace, so that a line break would make it blank.
+func (b *Builder) blankLine() bool {
+	out := b.buf.Bytes()
+	line := out[bytes.LastIndexByte(out, '\n')+1:]
+	return len(bytes.Trim(line, " \t\r")) == 0
+}
+
+// Sentence adds a sentence definition with an optional alias and one content
+// per name.
+func (b *Builder) Sentence(alias string, contents ...string) *Builder {
+	switch {
+	case b.err != nil:
+		return b
+	case alias != "" && !isIdentifier(alias):
+		b.fail("invalid alias %q", alias)
+		return b
+	case len(contents) == 0:
+		b.fail("sentence without contents")
+		return b
+	case !b.text(contents...):
+		return b
+	}
+	for i, content := range contents {
+		if content == "" {
+			b.fail("empty content")
+			return b
+		}
+		// The generated parser reads the contents after the first as prose
+		// (see recoverSentence), where these can't be written.
+		if j := strings.IndexAny(content, "#]}"); i > 0 && j >= 0 {
+			b.fail("%q can only appear in the first content of a sentence", content[j])
+			return b
+		}
+	}
+	return b.inline(SpanSentence, func() {
+		b.buf.WriteString("#" + alias)
+		for _, content := range contents {
+			b.buf.WriteByte('[')
+			escape(&b.buf, content, `\]}`)
+			b.buf.WriteByte(']')
+		}
+	})
+}
+
+// ApplyAll adds an apply-all span with an optional alias. It applies to the
+// names in targets, or to all of them when targets is nil.
+func (b *Builder) ApplyAll(alias string, targets []string, content string) *Builder {
+	switch {
+	case b.err != nil:
+		return b
+	case alias != "" && !isIdentifier(alias):
+		b.fail("invalid alias %q", alias)
+		return b
+	case targets != nil && len(targets) == 0:
+		b.fail("apply-all without targets")
+		return b
+	case content == "":
+		b.fail("empty content")
+		return b
+	case !b.text(content):
+		return b
+	}
+	for _, target := range targets {
+		if !isIdentifier(target) {
+			b.fail("invalid name %q", target)
+			return b
+		}
+	}
+	return b.inline(SpanApplyAll, func() {
+		b.buf.WriteString("#" + alias + "{")
+		if targets != nil {
+			b.buf.WriteString("[" + strings.Join(targets, ", ") + "], ")
+		}
+		b.buf.WriteByte('{')
+		escape(&b.buf, content, `\]}`)
+		b.buf.WriteString("}}")
+	})
+}
+
+// Selector adds a selector. path is written after `#.` as is, so it is an
+// optional `/` followed by aliases, indexes and names separated by dots, with
+// an optional trailing dot, such as "/intro.s1.en".
+func (b *Builder) Selector(path string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	elems := strings.TrimPrefix(path, "/")
+	elems = strings.TrimSuffix(elems, ".")
+	if elems != "" || strings.HasSuffix(path, ".") {
+		for _, elem := range strings.Split(elems, ".") {
+			if !isIdentifier(elem) {
+				b.fail("invalid selector %q", path)
+				return b
+			}
+		}
+	}
+	return b.inline(SpanSelector, func() {
+		b.buf.WriteString("#." + path)
+	})
+}
+
+// Err returns the first error recorded by the Builder.
+func (b *Builder) Err() error {
+	return b.err
+}
+
+// Bytes returns the markup built so far. It fails if a call recorded an
+// error or a section or paragraph is still open.
+func (b *Builder) Bytes() ([]byte, error) {
+	switch {
+	case b.err != nil:
+		return nil, b.err
+	case b.para:
+		return nil, errors.New("sand: builder: unclosed paragraph")
+	case b.depth > 0:
+		return nil, fmt.Errorf("sand: builder: %d unclosed sections", b.depth)
+	}
+	return bytes.Clone(b.buf.Bytes()), nil
+}
+
+// escape writes s to buf with a backslash before each byte in special, and
+// line breaks as `\n` if special holds one.
+func escape(buf *bytes.Buffer, s, special string) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case strings.IndexByte(special, c) < 0:
+			buf.WriteByte(c)
+		case c == '\n':
+			buf.WriteString(`\n`)
+		default:
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		}
+	}
+}
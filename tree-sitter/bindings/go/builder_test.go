@@ -0,0 +1,213 @@
+package tree_sitter_sand_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+// build returns the output of b, failing the test unless it parses without
+// errors.
+func build(t *testing.T, b *tree_sitter_sand.Builder) ([]byte, *tree_sitter_sand.Document) {
+	t.Helper()
+	src, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := tree_sitter_sand.ParseTree(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	if tree.RootNode().HasError() {
+		t.Fatalf("output has errors: %s\n%s", tree.RootNode().ToSexp(), src)
+	}
+	doc := mustParse(t, string(src))
+	if len(doc.Errors) > 0 {
+		t.Fatalf("output has errors: %v\n%s", doc.Errors, src)
+	}
+	return src, doc
+}
+
+func TestBuilder(t *testing.T) {
+	b := tree_sitter_sand.NewBuilder().Names("en", "ja")
+	b.Paragraph("Release notes")
+	b.Section("v1", "Version 1", func(b *tree_sitter_sand.Builder) {
+		b.Sentence("s1", "First release.", "最初のリリース。")
+		b.Section("", "Fixes", func(b *tree_sitter_sand.Builder) {
+			b.BeginParagraph().Text("See ").Selector("v1.s1.en").Text(" and ").ApplyAll("", []string{"ja"}, "修正").EndParagraph()
+		})
+	})
+	b.BeginSection("v2", "Version 2").ApplyAll("note", nil, "unreleased").EndSection()
+
+	src, doc := build(t, b)
+	want := `#(en, ja)
+
+Release notes
+
+#v1# Version 1
+
+#s1[First release.][最初のリリース。]
+
+### Fixes
+
+See #.v1.s1.en and #{[ja], {修正}}
+
+#v2# Version 2
+
+#note{{unreleased}}
+`
+	if string(src) != want {
+		t.Errorf("got\n%s\nwant\n%s", src, want)
+	}
+	for _, d := range tree_sitter_sand.ValidateRefs(src) {
+		if d.Code != tree_sitter_sand.CodeUnusedAlias {
+			t.Errorf("unexpected diagnostic: %v", d)
+		}
+	}
+
+	v1 := doc.Children[1].(*tree_sitter_sand.Section)
+	fixes := v1.Children[1].(*tree_sitter_sand.Section)
+	if v1.Alias != "v1" || v1.Level != 1 || fixes.Level != 2 || fixes.Title != "Fixes" {
+		t.Errorf("sections: %+v, %+v", v1, fixes)
+	}
+	if n := len(fixes.Children[0].(*tree_sitter_sand.Paragraph).Spans); n != 4 {
+		t.Errorf("got %d spans in one paragraph, want 4", n)
+	}
+}
+
+func TestBuilderEscapes(t *testing.T) {
+	title := `#1 \ [x] {y}` + "\nnext"
+	text := "#(en)\n## not a heading \\n #[x]\n\n  \nafter a blank line"
+	contents := []string{`a] #[b] \ }`, "line\n\nbreaks", `back\slash`}
+
+	b := tree_sitter_sand.NewBuilder().Names("a", "b", "c")
+	b.Section("", title, func(b *tree_sitter_sand.Builder) {
+		b.Paragraph(text)
+		b.Sentence("", contents...)
+		b.ApplyAll("", nil, contents[0])
+	})
+	_, doc := build(t, b)
+
+	section := doc.Children[0].(*tree_sitter_sand.Section)
+	if section.Title != title {
+		t.Errorf("title: got %q, want %q", section.Title, title)
+	}
+	if len(section.Children) != 3 {
+		t.Fatalf("got %d children, want 3", len(section.Children))
+	}
+	spans := section.Children[0].(*tree_sitter_sand.Paragraph).Spans
+	if len(spans) != 1 || spans[0].Text != text {
+		t.Errorf("text: got %+v, want %q", spans, text)
+	}
+	var got []string
+	for _, c := range section.Children[1].(*tree_sitter_sand.Paragraph).Spans[0].Contents {
+		got = append(got, c.Text)
+	}
+	if !reflect.DeepEqual(got, contents) {
+		t.Errorf("contents: got %q, want %q", got, contents)
+	}
+	if c := section.Children[2].(*tree_sitter_sand.Paragraph).Spans[0].Contents[0].Text; c != contents[0] {
+		t.Errorf("apply-all: got %q, want %q", c, contents[0])
+	}
+}
+
+func TestBuilderSeparatesSpans(t *testing.T) {
+	b := tree_sitter_sand.NewBuilder().Names("en")
+	b.BeginParagraph().Sentence("", "a").Text(" [b]").Selector("").Text("/c").Selector("x.").Text("y").EndParagraph()
+	src, doc := build(t, b)
+	if want := "#(en)\n\n#[a]\n [b]#.\n/c#.x.\ny\n"; string(src) != want {
+		t.Errorf("got %q, want %q", src, want)
+	}
+	var kinds []string
+	for _, span := range doc.Children[0].(*tree_sitter_sand.Paragraph).Spans {
+		kinds = append(kinds, span.Kind.String())
+	}
+	if want := "sentence text selector text selector text"; strings.Join(kinds, " ") != want {
+		t.Errorf("got spans %s, want %s", kinds, want)
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		build func(b *tree_sitter_sand.Builder)
+		want  string
+	}{
+		{"section inside paragraph", func(b *tree_sitter_sand.Builder) {
+			b.BeginParagraph().Text("a").BeginSection("", "B")
+		}, "section inside a paragraph"},
+		{"paragraph inside paragraph", func(b *tree_sitter_sand.Builder) {
+			b.BeginParagraph().BeginParagraph()
+		}, "paragraph inside a paragraph"},
+		{"content after subsection", func(b *tree_sitter_sand.Builder) {
+			b.Section("", "A", func(b *tree_sitter_sand.Builder) {
+				b.Section("", "B", func(*tree_sitter_sand.Builder) {})
+				b.Paragraph("back in A")
+			})
+		}, "after a closed section"},
+		{"content after section", func(b *tree_sitter_sand.Builder) {
+			b.Section("", "A", func(*tree_sitter_sand.Builder) {}).Sentence("", "x")
+		}, "after a closed section"},
+		{"unclosed section", func(b *tree_sitter_sand.Builder) {
+			b.BeginSection("", "A").BeginSection("", "B")
+		}, "2 unclosed sections"},
+		{"unclosed paragraph", func(b *tree_sitter_sand.Builder) {
+			b.BeginParagraph()
+		}, "unclosed paragraph"},
+		{"section closed in paragraph", func(b *tree_sitter_sand.Builder) {
+			b.BeginSection("", "A").BeginParagraph().EndSection()
+		}, "section closed inside a paragraph"},
+		{"no open section", func(b *tree_sitter_sand.Builder) {
+			b.EndSection()
+		}, "no open section"},
+		{"no open paragraph", func(b *tree_sitter_sand.Builder) {
+			b.EndParagraph()
+		}, "no open paragraph"},
+		{"names after content", func(b *tree_sitter_sand.Builder) {
+			b.Paragraph("a").Names("en")
+		}, "names must be defined first"},
+		{"invalid name", func(b *tree_sitter_sand.Builder) {
+			b.Names("en", "en-US")
+		}, `invalid name "en-US"`},
+		{"invalid alias", func(b *tree_sitter_sand.Builder) {
+			b.Sentence("a b", "x")
+		}, `invalid alias "a b"`},
+		{"empty title", func(b *tree_sitter_sand.Builder) {
+			b.BeginSection("", " \t")
+		}, "empty section title"},
+		{"no contents", func(b *tree_sitter_sand.Builder) {
+			b.Sentence("s")
+		}, "sentence without contents"},
+		{"later content", func(b *tree_sitter_sand.Builder) {
+			b.Sentence("", "#1", "#2")
+		}, `'#' can only appear in the first content`},
+		{"empty content", func(b *tree_sitter_sand.Builder) {
+			b.ApplyAll("", nil, "")
+		}, "empty content"},
+		{"NUL", func(b *tree_sitter_sand.Builder) {
+			b.Paragraph("a\x00b")
+		}, "NUL byte"},
+		{"invalid UTF-8", func(b *tree_sitter_sand.Builder) {
+			b.Section("", "caf\xe9", nil)
+		}, "invalid UTF-8"},
+		{"no targets", func(b *tree_sitter_sand.Builder) {
+			b.ApplyAll("", []string{}, "x")
+		}, "apply-all without targets"},
+		{"invalid selector", func(b *tree_sitter_sand.Builder) {
+			b.Selector("a..b")
+		}, `invalid selector "a..b"`},
+		{"first error wins", func(b *tree_sitter_sand.Builder) {
+			b.EndSection().EndParagraph()
+		}, "no open section"},
+	} {
+		b := tree_sitter_sand.NewBuilder()
+		tt.build(b)
+		_, err := b.Bytes()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
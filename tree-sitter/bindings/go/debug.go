@@ -0,0 +1,58 @@
+package tree_sitter_sand
+
+import (
+	"fmt"
+	"io"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// DebugParse parses src with tree-sitter's logging on and writes the log to
+// w, one event per line: "parse:" lines for what the parser does with its
+// stack, such as shifts, reductions and error recovery, and "lex:" lines for
+// the characters the lexer consumes and the tokens it recognizes. Attach the
+// output to reports of grammar bugs.
+//
+// The grammar has no external scanner, so [ScannerState] has nothing to
+// return: everything the parser carries from one token to the next is on
+// the stack, which the log shows. Use [ParseTree] and a [Session] side by
+// side to check that an incremental reparse builds the same tree as a full
+// one.
+//
+// DebugParse returns the first error writing to w, or an error if the parser
+// cannot be used.
+func DebugParse(src []byte, w io.Writer) error {
+	parser, err := newParser()
+	if err != nil {
+		return err
+	}
+	defer parser.Close()
+
+	var werr error
+	parser.SetLogger(func(typ tree_sitter.LogType, msg string) {
+		if werr != nil {
+			return
+		}
+		kind := "parse"
+		if typ == tree_sitter.LogTypeLex {
+			kind = "lex"
+		}
+		_, werr = fmt.Fprintf(w, "%s: %s\n", kind, msg)
+	})
+	defer parser.SetLogger(nil)
+
+	tree := parse(parser, src, nil)
+	if tree == nil {
+		return ErrNoTree
+	}
+	tree.Close()
+	return werr
+}
+
+// ScannerState returns the state the external scanner serialized for node
+// in tree, which an incremental reparse restores before lexing node again,
+// and false if there is none. The grammar has no external scanner, so it
+// always returns nil and false.
+func ScannerState(tree *tree_sitter.Tree, node tree_sitter.Node) ([]byte, bool) {
+	return nil, false
+}
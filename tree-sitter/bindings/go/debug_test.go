@@ -0,0 +1,121 @@
+package tree_sitter_sand_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestDebugParse(t *testing.T) {
+	var out bytes.Buffer
+	if err := tree_sitter_sand.DebugParse([]byte("#(en)\n\n#s[a]\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	log := out.String()
+	for _, want := range []string{
+		"parse: new_parse\n",
+		"lex: consume character:'#'\n",
+		"parse: lexed_lookahead sym:identifier, size:1\n",
+		"parse: reduce sym:sentence_definition, child_count:4\n",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log does not contain %q:\n%s", want, log)
+		}
+	}
+	if !strings.HasSuffix(log, "parse: accept\nparse: done\n") {
+		t.Errorf("log does not end with the accepted parse:\n%s", log)
+	}
+}
+
+func TestScannerState(t *testing.T) {
+	tree, err := tree_sitter_sand.ParseTree([]byte("#(en)\n\n#s[a]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	for _, node := range []tree_sitter.Node{*tree.RootNode(), *tree.RootNode().Child(0)} {
+		if state, ok := tree_sitter_sand.ScannerState(tree, node); ok || state != nil {
+			t.Errorf("%s: got scanner state %q, %v", node.Kind(), state, ok)
+		}
+	}
+}
+
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n++; w.n > 3 {
+		return 0, errors.New("disk full")
+	}
+	return len(p), nil
+}
+
+func TestDebugParseWriteError(t *testing.T) {
+	w := &failingWriter{}
+	if err := tree_sitter_sand.DebugParse([]byte("#(en)\n\n#s[a]\n"), w); err == nil || err.Error() != "disk full" {
+		t.Errorf("got error %v, want the write error", err)
+	}
+	if w.n != 4 {
+		t.Errorf("DebugParse wrote %d times, want it to stop after the error", w.n)
+	}
+}
+
+// dump writes the nodes of tree with their ranges, which ToSexp leaves out.
+func dump(tree *tree_sitter.Tree) string {
+	var b strings.Builder
+	var walk func(n *tree_sitter.Node, depth int)
+	walk = func(n *tree_sitter.Node, depth int) {
+		fmt.Fprintf(&b, "%*s%s %d-%d %v-%v", 2*depth, "", n.GrammarName(), n.StartByte(), n.EndByte(), n.StartPosition(), n.EndPosition())
+		if n.IsMissing() {
+			b.WriteString(" missing")
+		}
+		b.WriteByte('\n')
+		for i := uint(0); i < n.ChildCount(); i++ {
+			walk(n.Child(i), depth+1)
+		}
+	}
+	walk(tree.RootNode(), 0)
+	return b.String()
+}
+
+// TestIncrementalMatchesFullParse edits every offset of a document that goes
+// through every lexer state: names, headings, contents with escapes, later
+// contents, apply-all blocks, selectors and prose. The grammar keeps no
+// external scanner state, so the lex state at the start of each token is all
+// an incremental reparse has to get right.
+func TestIncrementalMatchesFullParse(t *testing.T) {
+	src := []byte("#(en, ja)\n\n#a## T \\] x\n\n#s[a \\] b][c]\n#{[en], {d\\}}} #.a.s.en\nprose \\#\n")
+	pieces := []string{"[", "]", "{", "}", "\\", "#", ".", " ", "\n", "x"}
+	s := newSession(t)
+	for offset := 0; offset <= len(src); offset++ {
+		for _, piece := range pieces {
+			s.Parse(src)
+			edited, edit := insert(src, offset, piece)
+			check(t, s, edit, edited, fmt.Sprintf("inserting %q at %d", piece, offset))
+		}
+		if offset < len(src) {
+			s.Parse(src)
+			edited, input, err := tree_sitter_sand.ApplyEditsInput(src, []tree_sitter_sand.TextEdit{edit(uint(offset), uint(offset+1), "")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(t, s, input[0], edited, fmt.Sprintf("deleting %q at %d", src[offset], offset))
+		}
+	}
+}
+
+func check(t *testing.T, s *tree_sitter_sand.Session, edit tree_sitter.InputEdit, src []byte, what string) {
+	t.Helper()
+	tree := s.ApplyEdit(edit, src)
+	full := cst(t, src)
+	defer full.Close()
+	if got, want := dump(tree), dump(full); got != want {
+		var log bytes.Buffer
+		tree_sitter_sand.DebugParse(src, &log)
+		t.Fatalf("%s: incremental tree differs from a full parse of %q:\n%s\nwant\n%s\nparse log:\n%s", what, src, got, want, log.String())
+	}
+}
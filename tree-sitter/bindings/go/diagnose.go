@@ -0,0 +1,185 @@
+package tree_sitter_sand
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Severity is the severity of a [Diagnostic]. The values match the LSP
+// DiagnosticSeverity.
+type Severity int
+
+const (
+	SeverityError Severity = iota + 1
+	SeverityWarning
+	SeverityInformation
+	SeverityHint
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInformation:
+		return "information"
+	case SeverityHint:
+		return "hint"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// The codes of the diagnostics reported by [Diagnose].
+const (
+	CodeSyntaxError = "syntax-error"
+	CodeInvalidUTF8 = "invalid-utf8"
+)
+
+// Diagnostic is a problem found in a document.
+type Diagnostic struct {
+	Range    Range
+	Severity Severity
+	// Code identifies the kind of problem, such as [CodeSyntaxError].
+	Code    string
+	Message string
+	// Source is the offending part of the document. It is empty for a
+	// missing token.
+	Source []byte
+}
+
+func (d Diagnostic) String() string {
+	pos := d.Range.StartPoint
+	return fmt.Sprintf("%d:%d: %s: %s", pos.Row+1, pos.Column+1, d.Severity, d.Message)
+}
+
+// Diagnose reports the syntax errors of src, one diagnostic per ERROR or
+// MISSING node, with a message derived from the construct it occurs in, and
+// every run of invalid UTF-8. The document is parsed with those runs
+// replaced with U+FFFD, as [InvalidUTF8Replace] does, so the rest of it is
+// still checked. A UTF-16 source gives a single diagnostic.
+func Diagnose(src []byte) []Diagnostic {
+	fixed, replaced, err := CheckUTF8(src, InvalidUTF8Replace)
+	if err != nil {
+		return []Diagnostic{{Range: newLineIndex(src).rangeOf(0, 2), Severity: SeverityError, Code: CodeInvalidUTF8, Message: err.Error(), Source: src[:2]}}
+	}
+	tree, err := ParseTree(fixed)
+	if err != nil {
+		return []Diagnostic{{Severity: SeverityError, Code: CodeSyntaxError, Message: err.Error()}}
+	}
+	defer tree.Close()
+
+	var diags []Diagnostic
+	lines := newLineIndex(src)
+	for _, r := range replaced {
+		diags = append(diags, Diagnostic{
+			Range:    lines.rangeOf(r.Start, r.End),
+			Severity: SeverityError,
+			Code:     CodeInvalidUTF8,
+			Message:  fmt.Sprintf("invalid UTF-8: % x", src[r.Start:r.End]),
+			Source:   src[r.Start:r.End],
+		})
+	}
+	walkErrors(tree.RootNode(), func(node *tree_sitter.Node) {
+		msg, start, end := describeError(node, fixed)
+		start, end = OriginalOffset(replaced, start), OriginalOffset(replaced, end)
+		diags = append(diags, Diagnostic{
+			Range:    lines.rangeOf(start, end),
+			Severity: SeverityError,
+			Code:     CodeSyntaxError,
+			Message:  msg,
+			Source:   src[start:end],
+		})
+	})
+	slices.SortStableFunc(diags, func(a, b Diagnostic) int { return cmp.Compare(a.Range.StartByte, b.Range.StartByte) })
+	return diags
+}
+
+// constructs names the node kinds errors can occur in.
+var constructs = map[Kind]string{
+	KindNameDefinition:     "name definition",
+	KindSection:            "heading",
+	KindApplyAll:           "apply-all block",
+	KindSentenceDefinition: "sentence definition",
+	KindSelector:           "selector",
+	KindString:             "content",
+	KindNonEscapedString:   "text",
+}
+
+// construct returns the name of the construct node belongs to, or "" at the
+// top level.
+func construct(node *tree_sitter.Node) string {
+	for node = node.Parent(); node != nil; node = node.Parent() {
+		if name, ok := constructs[NodeKind(node)]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// describeError returns the message for an ERROR or MISSING node and the
+// part of src it applies to.
+func describeError(node *tree_sitter.Node, src []byte) (msg string, start, end uint) {
+	start, end = node.StartByte(), node.EndByte()
+	in := construct(node)
+
+	if node.IsMissing() {
+		expected := node.Kind()
+		if !node.IsNamed() {
+			expected = fmt.Sprintf("%q", expected)
+		}
+		if in == "text" || in == "content" {
+			return fmt.Sprintf("incomplete escape sequence: missing %s after \\", expected), start, end
+		}
+		if in == "" {
+			return "missing " + expected, start, end
+		}
+		return fmt.Sprintf("missing %s in %s", expected, in), start, end
+	}
+
+	if in != "" {
+		return fmt.Sprintf("unexpected %q in %s", src[start:end], in), start, end
+	}
+
+	// At the top level, the first tokens of the ERROR node tell which
+	// construct the parser gave up on.
+	count := map[Kind]int{}
+	for i := uint(0); i < node.ChildCount(); i++ {
+		count[NodeKind(node.Child(i))]++
+	}
+	first := node.Child(0)
+	switch {
+	case first == nil:
+		return "syntax error", start, end
+	case NodeKind(first) == "#(":
+		if count[")"] == 0 {
+			return `unterminated name definition: missing ")"`, start, end
+		}
+		return "invalid name definition", start, end
+	case NodeKind(first) == "#" && count["{"] > 0:
+		if count["{"] > count["}"] {
+			return `unterminated apply-all block: missing "}"`, start, end
+		}
+		return "invalid apply-all block", start, end
+	case NodeKind(first) == "#" && count["["] > 0:
+		if count["["] > count["]"] {
+			return `unterminated sentence definition: missing "]"`, start, end
+		}
+		return "invalid sentence definition", start, end
+	case NodeKind(first) == "#":
+		return `stray "#": write \# for a literal hash`, start, end
+	}
+	for i := uint(0); i < node.ChildCount(); i++ {
+		if child := node.Child(i); NodeKind(child) == `\` {
+			_, size := utf8.DecodeRune(src[child.EndByte():end])
+			start, end = child.StartByte(), child.EndByte()+uint(size)
+			return fmt.Sprintf("invalid escape sequence %q", src[start:end]), start, end
+		}
+	}
+	return "syntax error", start, end
+}
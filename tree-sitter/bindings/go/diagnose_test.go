@@ -0,0 +1,92 @@
+package tree_sitter_sand_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		src    string
+		msg    string
+		start  [2]uint // row and column, 0-based
+		end    [2]uint
+		source string
+	}{
+		{"#(en\n", `unterminated name definition: missing ")"`, [2]uint{0, 0}, [2]uint{0, 4}, "#(en"},
+		{"#(en,)\n", `unexpected "," in name definition`, [2]uint{0, 4}, [2]uint{0, 5}, ","},
+		{"#(en)\n#()\n", "missing identifier in name definition", [2]uint{1, 2}, [2]uint{1, 2}, ""},
+		{"#(en)\n\n#s[unterminated\n", `unterminated sentence definition: missing "]"`, [2]uint{2, 0}, [2]uint{3, 0}, "#s[unterminated\n"},
+		{"#(en)\n#{[en], {open\n", `unterminated apply-all block: missing "}"`, [2]uint{1, 0}, [2]uint{2, 0}, "#{[en], {open\n"},
+		{"#a{all, x}}\n", "invalid apply-all block", [2]uint{0, 0}, [2]uint{0, 11}, "#a{all, x}}"},
+		{"#{all {x}}\n", `unexpected "all" in apply-all block`, [2]uint{0, 2}, [2]uint{0, 5}, "all"},
+		{"x #\n", `stray "#": write \# for a literal hash`, [2]uint{0, 2}, [2]uint{0, 3}, "#"},
+		{"日本語 \\q b\n", `invalid escape sequence "\\q"`, [2]uint{0, 10}, [2]uint{0, 12}, `\q`},
+		{"a \\あ\n", `invalid escape sequence "\\あ"`, [2]uint{0, 2}, [2]uint{0, 6}, `\あ`},
+		{"text\\", `invalid escape sequence "\\"`, [2]uint{0, 4}, [2]uint{0, 5}, `\`},
+	}
+
+	for _, tt := range tests {
+		diags := tree_sitter_sand.Diagnose([]byte(tt.src))
+		if len(diags) != 1 {
+			t.Errorf("%q: got %d diagnostics, want 1: %v", tt.src, len(diags), diags)
+			continue
+		}
+		d := diags[0]
+		if d.Message != tt.msg {
+			t.Errorf("%q: message = %q, want %q", tt.src, d.Message, tt.msg)
+		}
+		start := [2]uint{d.Range.StartPoint.Row, d.Range.StartPoint.Column}
+		end := [2]uint{d.Range.EndPoint.Row, d.Range.EndPoint.Column}
+		if start != tt.start || end != tt.end {
+			t.Errorf("%q: range = %v-%v, want %v-%v", tt.src, start, end, tt.start, tt.end)
+		}
+		if string(d.Source) != tt.source {
+			t.Errorf("%q: source = %q, want %q", tt.src, d.Source, tt.source)
+		}
+		if d.Severity != tree_sitter_sand.SeverityError {
+			t.Errorf("%q: severity = %v", tt.src, d.Severity)
+		}
+	}
+}
+
+func TestDiagnoseValid(t *testing.T) {
+	for name, src := range corpus(t) {
+		if name == "errors.sand" {
+			continue
+		}
+		if diags := tree_sitter_sand.Diagnose(src); len(diags) > 0 {
+			t.Errorf("%s: unexpected diagnostics %v", name, diags)
+		}
+	}
+}
+
+func TestDiagnosticString(t *testing.T) {
+	d := tree_sitter_sand.Diagnose([]byte("#(en)\n\n#s[unterminated\n"))[0]
+	if got, want := d.String(), `3:1: error: unterminated sentence definition: missing "]"`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestDiagnoseInvalidUTF8(t *testing.T) {
+	var got []string
+	for _, d := range tree_sitter_sand.Diagnose([]byte("#(en)\n\n#s[a\xff\xfe")) {
+		got = append(got, fmt.Sprintf("%d-%d %s %s %q", d.Range.StartByte, d.Range.EndByte, d.Code, d.Message, d.Source))
+	}
+	want := []string{
+		`7-13 syntax-error unterminated sentence definition: missing "]" "#s[a\xff\xfe"`,
+		`11-13 invalid-utf8 invalid UTF-8: ff fe "\xff\xfe"`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	d := tree_sitter_sand.Diagnose([]byte("\xff\xfe#\x00"))
+	if len(d) != 1 || d[0].Code != tree_sitter_sand.CodeInvalidUTF8 || d[0].Range.EndByte != 2 {
+		t.Errorf("got %v for UTF-16", d)
+	}
+}
@@ -0,0 +1,300 @@
+package tree_sitter_sand
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ChangeKind is the kind of a [Change].
+type ChangeKind int
+
+const (
+	// Added is a node only in the new document.
+	Added ChangeKind = iota + 1
+	// Removed is a node only in the old document.
+	Removed
+	// Modified is a paragraph whose text changed, or a section whose
+	// heading changed.
+	Modified
+	// Moved is a section that changed places among its siblings.
+	Moved
+	// FormattingOnly is a node that differs only in whitespace.
+	FormattingOnly
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	case Moved:
+		return "moved"
+	case FormattingOnly:
+		return "reformatted"
+	default:
+		return fmt.Sprintf("ChangeKind(%d)", int(k))
+	}
+}
+
+// Change is a difference between two versions of a document, as reported by
+// [DiffStructural].
+type Change struct {
+	Kind ChangeKind
+	// Old and New are the changed node in each version, a *Section or a
+	// *Paragraph. Old is nil for Added and New is nil for Removed.
+	Old, New Node
+	// OldRange and NewRange are the ranges of Old and New, in the old and
+	// the new source.
+	OldRange, NewRange Range
+	// Path holds the titles of the sections containing the node, outermost
+	// first.
+	Path []string
+}
+
+func (c Change) String() string {
+	node := c.New
+	if node == nil {
+		node = c.Old
+	}
+	var what string
+	if sec, ok := node.(*Section); ok {
+		what = fmt.Sprintf("section %q", sec.Title)
+	} else if len(c.Path) == 0 {
+		what = "top-level paragraph"
+	} else {
+		what = fmt.Sprintf("paragraph under %q", strings.Join(c.Path, " > "))
+	}
+	return what + " " + c.Kind.String()
+}
+
+// DiffStructural compares two versions of a document section by section.
+//
+// Sections are matched among their siblings: first by alias, then by title,
+// and last by position, if the unmatched sections at the same place have the
+// same body. A renamed heading without an alias is therefore reported as
+// Modified when its body is unchanged, and as Removed and Added otherwise.
+// Matched sections whose order changed are Moved; a section moved under
+// another parent is Removed and Added. Matched sections are compared
+// recursively, and a heading change is reported as Modified on the section.
+//
+// Paragraphs are aligned by a longest common subsequence of their text.
+// Unaligned paragraphs between two aligned ones are paired up as Modified;
+// the rest are Added or Removed.
+//
+// Differences only in whitespace, which the formatter normalizes and the
+// renderer ignores, are FormattingOnly. Changes are grouped by section in
+// the order of the new document, with removals last in each group.
+func DiffStructural(old, new []byte) []Change {
+	a, err := parseLenient(old)
+	if err != nil {
+		return nil
+	}
+	b, err := parseLenient(new)
+	if err != nil {
+		return nil
+	}
+	d := &differ{old: old, new: new}
+	d.children(a.Children, b.Children, nil)
+	return d.changes
+}
+
+type differ struct {
+	old, new []byte
+	changes  []Change
+}
+
+func (d *differ) add(kind ChangeKind, a, b Node, path []string) {
+	c := Change{Kind: kind, Old: a, New: b, Path: path}
+	if a != nil {
+		c.OldRange = a.Span()
+	}
+	if b != nil {
+		c.NewRange = b.Span()
+	}
+	d.changes = append(d.changes, c)
+}
+
+func (d *differ) children(a, b []Node, path []string) {
+	d.paragraphs(filter[*Paragraph](a), filter[*Paragraph](b), path)
+	d.sections(filter[*Section](a), filter[*Section](b), path)
+}
+
+func filter[T Node](nodes []Node) []T {
+	var out []T
+	for _, n := range nodes {
+		if n, ok := n.(T); ok {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func (d *differ) paragraphs(a, b []*Paragraph, path []string) {
+	ka := make([]string, len(a))
+	for i, p := range a {
+		ka[i] = normalizeSpace(rangeText(d.old, p.Range))
+	}
+	kb := make([]string, len(b))
+	for i, p := range b {
+		kb[i] = normalizeSpace(rangeText(d.new, p.Range))
+	}
+
+	i, j := 0, 0
+	gap := func(endA, endB int) {
+		for ; i < endA && j < endB; i, j = i+1, j+1 {
+			d.add(Modified, a[i], b[j], path)
+		}
+		for ; j < endB; j++ {
+			d.add(Added, nil, b[j], path)
+		}
+		for ; i < endA; i++ {
+			d.add(Removed, a[i], nil, path)
+		}
+	}
+	for _, m := range lcs(ka, kb) {
+		gap(m[0], m[1])
+		if rangeText(d.old, a[i].Range) != rangeText(d.new, b[j].Range) {
+			d.add(FormattingOnly, a[i], b[j], path)
+		}
+		i, j = i+1, j+1
+	}
+	gap(len(a), len(b))
+}
+
+func (d *differ) sections(a, b []*Section, path []string) {
+	match := make([]int, len(b)) // index in a of the match of b[j], or -1
+	used := make([]bool, len(a))
+	for j := range match {
+		match[j] = -1
+	}
+	pair := func(ok func(x, y *Section) bool) {
+		for j, y := range b {
+			if match[j] >= 0 {
+				continue
+			}
+			for i, x := range a {
+				if !used[i] && ok(x, y) {
+					match[j], used[i] = i, true
+					break
+				}
+			}
+		}
+	}
+	pair(func(x, y *Section) bool { return x.Alias != "" && x.Alias == y.Alias })
+	pair(func(x, y *Section) bool { return normalizeSpace(x.Title) == normalizeSpace(y.Title) })
+	for j, y := range b {
+		if match[j] < 0 && j < len(a) && !used[j] && sectionBody(d.old, a[j]) == sectionBody(d.new, y) {
+			match[j], used[j] = j, true
+		}
+	}
+
+	// Matched sections outside the longest run in the old order moved.
+	var order []int
+	for _, i := range match {
+		if i >= 0 {
+			order = append(order, i)
+		}
+	}
+	stay := increasing(order)
+
+	for j, y := range b {
+		sub := append(slices.Clip(path), y.Title)
+		i := match[j]
+		if i < 0 {
+			d.add(Added, nil, y, path)
+			continue
+		}
+		x := a[i]
+		if !stay[i] {
+			d.add(Moved, x, y, path)
+		}
+		if x.Alias != y.Alias || x.Level != y.Level || normalizeSpace(x.Title) != normalizeSpace(y.Title) {
+			d.add(Modified, x, y, path)
+		} else if rangeText(d.old, x.Heading) != rangeText(d.new, y.Heading) {
+			d.add(FormattingOnly, x, y, path)
+		}
+		d.children(x.Children, y.Children, sub)
+	}
+	for i, x := range a {
+		if !used[i] {
+			d.add(Removed, x, nil, path)
+		}
+	}
+}
+
+// sectionBody returns the normalized text of sec after its heading.
+func sectionBody(src []byte, sec *Section) string {
+	return normalizeSpace(string(src[sec.Heading.EndByte:sec.Range.EndByte]))
+}
+
+func rangeText(src []byte, r Range) string {
+	return string(src[r.StartByte:r.EndByte])
+}
+
+// normalizeSpace collapses whitespace.
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// lcs returns the index pairs of a longest common subsequence of a and b.
+func lcs(a, b []string) [][2]int {
+	n := make([][]int, len(a)+1)
+	for i := range n {
+		n[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				n[i][j] = n[i+1][j+1] + 1
+			} else {
+				n[i][j] = max(n[i+1][j], n[i][j+1])
+			}
+		}
+	}
+	var out [][2]int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, [2]int{i, j})
+			i, j = i+1, j+1
+		case n[i+1][j] >= n[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return out
+}
+
+// increasing returns the values of a longest increasing subsequence of s,
+// which holds distinct values.
+func increasing(s []int) map[int]bool {
+	// tails[k] is the index in s of the smallest tail of an increasing
+	// subsequence of length k+1.
+	var tails []int
+	prev := make([]int, len(s))
+	for i, v := range s {
+		k, _ := slices.BinarySearchFunc(tails, v, func(t, v int) int { return s[t] - v })
+		if k > 0 {
+			prev[i] = tails[k-1]
+		} else {
+			prev[i] = -1
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	out := map[int]bool{}
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			out[s[i]] = true
+		}
+	}
+	return out
+}
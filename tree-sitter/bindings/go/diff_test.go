@@ -0,0 +1,123 @@
+package tree_sitter_sand_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+func diff(old, new string) []string {
+	var out []string
+	for _, c := range tree_sitter_sand.DiffStructural([]byte(old), []byte(new)) {
+		out = append(out, c.String())
+	}
+	return out
+}
+
+const diffBase = `#(en)
+
+Intro.
+
+#a# Alpha
+
+First #s[one].
+
+#b## Beta
+
+Nested.
+
+#c# Gamma
+
+Last.
+`
+
+func TestDiffStructural(t *testing.T) {
+	tests := []struct {
+		name string
+		new  string
+		want []string
+	}{
+		{name: "same", new: diffBase},
+		{
+			name: "paragraph changed",
+			new:  strings.Replace(diffBase, "Nested.", "Nested, changed.", 1),
+			want: []string{`paragraph under "Alpha > Beta" modified`},
+		},
+		{
+			name: "formatting only",
+			new:  strings.Replace(strings.Replace(diffBase, "First #s[one].", "First   #s[one].  ", 1), "#c# Gamma", "#c#   Gamma", 1),
+			want: []string{`paragraph under "Alpha" reformatted`, `section "Gamma" reformatted`},
+		},
+		{
+			name: "section added and removed",
+			new:  strings.Replace(diffBase, "#b## Beta\n\nNested.\n", "#d## Delta\n\nOther.\n", 1),
+			want: []string{`section "Delta" added`, `section "Beta" removed`},
+		},
+		{
+			name: "moved",
+			new:  "#(en)\n\nIntro.\n\n#c# Gamma\n\nLast.\n\n#a# Alpha\n\nFirst #s[one].\n\n#b## Beta\n\nNested.\n",
+			want: []string{`section "Gamma" moved`},
+		},
+		{
+			name: "top-level paragraph",
+			new:  strings.Replace(diffBase, "Intro.\n", "Intro.\n\nMore intro.\n", 1),
+			want: []string{"top-level paragraph added"},
+		},
+		{
+			name: "renamed with alias",
+			new:  strings.Replace(diffBase, "#a# Alpha", "#a# First letter", 1),
+			want: []string{`section "First letter" modified`},
+		},
+		{
+			name: "renamed without alias, same body",
+			new:  strings.Replace(diffBase, "#c# Gamma", "## Omega", 1),
+			want: []string{`section "Omega" modified`},
+		},
+		{
+			name: "renamed without alias, new body",
+			new:  strings.Replace(strings.Replace(diffBase, "#c# Gamma", "## Omega", 1), "Last.", "Final.", 1),
+			want: []string{`section "Omega" added`, `section "Gamma" removed`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diff(diffBase, tt.new); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffStructuralRanges(t *testing.T) {
+	old := diffBase
+	new := strings.Replace(diffBase, "Intro.\n", "Intro.\n\nAdded paragraph.\n", 1)
+	new = strings.Replace(new, "Nested.", "Nested!", 1)
+	changes := tree_sitter_sand.DiffStructural([]byte(old), []byte(new))
+	if len(changes) != 2 {
+		t.Fatalf("got %v", changes)
+	}
+	added, modified := changes[0], changes[1]
+	if added.Kind != tree_sitter_sand.Added || added.Old != nil || strings.TrimSpace(new[added.NewRange.StartByte:added.NewRange.EndByte]) != "Added paragraph." {
+		t.Errorf("added = %+v", added)
+	}
+	r := modified.OldRange
+	if got := strings.TrimSpace(old[r.StartByte:r.EndByte]); got != "Nested." {
+		t.Errorf("modified old range = %q", got)
+	}
+	r = modified.NewRange
+	if got := strings.TrimSpace(new[r.StartByte:r.EndByte]); got != "Nested!" {
+		t.Errorf("modified new range = %q", got)
+	}
+	if !slices.Equal(modified.Path, []string{"Alpha", "Beta"}) {
+		t.Errorf("path = %q", modified.Path)
+	}
+}
+
+func TestDiffStructuralInvalidUTF8(t *testing.T) {
+	changes := tree_sitter_sand.DiffStructural([]byte(invalidDoc), []byte("#(en)\n\n## Title \xff\n"))
+	if len(changes) != 3 {
+		t.Errorf("got %v, want the removed paragraphs and section", changes)
+	}
+}
@@ -0,0 +1,109 @@
+package tree_sitter_sand
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// TextEdit replaces the text in Range with NewText. An empty range inserts
+// NewText at its start.
+type TextEdit struct {
+	Range   Range
+	NewText string
+}
+
+// ApplyEdits returns src with edits applied. Edits are given in offsets of
+// src and may come in any order; only the byte offsets of their ranges are
+// used.
+//
+// Edits must not overlap. Insertions at the same offset are applied in the
+// order given, and before a replacement starting there. An edit outside src
+// or overlapping another makes ApplyEdits fail without applying anything.
+func ApplyEdits(src []byte, edits []TextEdit) ([]byte, error) {
+	out, _, err := ApplyEditsInput(src, edits)
+	return out, err
+}
+
+// ApplyEditsInput is like [ApplyEdits] and also returns the edits in the form
+// tree-sitter needs to reuse a tree of src. Pass them to
+// [tree_sitter.Tree.Edit] in the order returned, which is from the end of the
+// document to the start, then reparse the result with the edited tree.
+func ApplyEditsInput(src []byte, edits []TextEdit) ([]byte, []tree_sitter.InputEdit, error) {
+	order, err := sortEdits(src, edits)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size := len(src)
+	for _, i := range order {
+		e := edits[i]
+		size += len(e.NewText) - int(e.Range.EndByte-e.Range.StartByte)
+	}
+	out := make([]byte, 0, size)
+	var last uint
+	for _, i := range order {
+		e := edits[i]
+		out = append(out, src[last:e.Range.StartByte]...)
+		out = append(out, e.NewText...)
+		last = e.Range.EndByte
+	}
+	out = append(out, src[last:]...)
+
+	lines := newLineIndex(src)
+	input := make([]tree_sitter.InputEdit, 0, len(order))
+	for _, i := range slices.Backward(order) {
+		e := edits[i]
+		start := lines.point(e.Range.StartByte)
+		input = append(input, tree_sitter.InputEdit{
+			StartByte:      e.Range.StartByte,
+			OldEndByte:     e.Range.EndByte,
+			NewEndByte:     e.Range.StartByte + uint(len(e.NewText)),
+			StartPosition:  start,
+			OldEndPosition: lines.point(e.Range.EndByte),
+			NewEndPosition: advance(start, e.NewText),
+		})
+	}
+	return out, input, nil
+}
+
+// sortEdits returns the indexes of edits in the order they apply to src, or
+// an error naming the first edit out of range or the first two overlapping.
+func sortEdits(src []byte, edits []TextEdit) ([]int, error) {
+	order := make([]int, len(edits))
+	for i, e := range edits {
+		if e.Range.StartByte > e.Range.EndByte || e.Range.EndByte > uint(len(src)) {
+			return nil, fmt.Errorf("sand: edit %d (bytes %d-%d) is outside the %d-byte document", i, e.Range.StartByte, e.Range.EndByte, len(src))
+		}
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		ra, rb := edits[a].Range, edits[b].Range
+		return cmp.Or(cmp.Compare(ra.StartByte, rb.StartByte), cmp.Compare(ra.EndByte, rb.EndByte))
+	})
+	for k := 1; k < len(order); k++ {
+		a, b := order[k-1], order[k]
+		ra, rb := edits[a].Range, edits[b].Range
+		if ra.EndByte > rb.StartByte {
+			a, b = min(a, b), max(a, b)
+			ra, rb = edits[a].Range, edits[b].Range
+			return nil, fmt.Errorf("sand: edit %d (bytes %d-%d) overlaps edit %d (bytes %d-%d)", a, ra.StartByte, ra.EndByte, b, rb.StartByte, rb.EndByte)
+		}
+	}
+	return order, nil
+}
+
+// advance returns the point after text when it starts at p.
+func advance(p tree_sitter.Point, text string) tree_sitter.Point {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			p.Row++
+			p.Column = 0
+		} else {
+			p.Column++
+		}
+	}
+	return p
+}
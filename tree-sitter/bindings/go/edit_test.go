@@ -0,0 +1,184 @@
+package tree_sitter_sand_test
+
+import (
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+func edit(start, end uint, text string) tree_sitter_sand.TextEdit {
+	return tree_sitter_sand.TextEdit{Range: tree_sitter_sand.Range{StartByte: start, EndByte: end}, NewText: text}
+}
+
+func TestApplyEdits(t *testing.T) {
+	const src = "#(en)\n\n#s[x]\n"
+	tests := []struct {
+		name  string
+		edits []tree_sitter_sand.TextEdit
+		want  string
+	}{
+		{"none", nil, src},
+		{"start", []tree_sitter_sand.TextEdit{edit(0, 0, "hi\n")}, "hi\n" + src},
+		{"end", []tree_sitter_sand.TextEdit{edit(13, 13, "#t[y]\n")}, src + "#t[y]\n"},
+		{"replace all", []tree_sitter_sand.TextEdit{edit(0, 13, "")}, ""},
+		{"unordered", []tree_sitter_sand.TextEdit{edit(10, 11, "y"), edit(2, 4, "ja")}, "#(ja)\n\n#s[y]\n"},
+		{"same offset", []tree_sitter_sand.TextEdit{edit(10, 10, "a"), edit(10, 11, "X"), edit(10, 10, "b")}, "#(en)\n\n#s[abX]\n"},
+		{"adjacent", []tree_sitter_sand.TextEdit{edit(9, 10, "["), edit(10, 11, "z"), edit(11, 12, "]")}, src[:10] + "z" + src[11:]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tree_sitter_sand.ApplyEdits([]byte(src), tt.edits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEditsErrors(t *testing.T) {
+	const src = "#(en)\n\n#s[x]\n"
+	tests := []struct {
+		edits []tree_sitter_sand.TextEdit
+		want  string
+	}{
+		{
+			[]tree_sitter_sand.TextEdit{edit(0, 1, ""), edit(8, 12, "a"), edit(10, 11, "b")},
+			"sand: edit 1 (bytes 8-12) overlaps edit 2 (bytes 10-11)",
+		},
+		{
+			[]tree_sitter_sand.TextEdit{edit(10, 10, "a"), edit(9, 11, "b")},
+			"sand: edit 0 (bytes 10-10) overlaps edit 1 (bytes 9-11)",
+		},
+		{
+			[]tree_sitter_sand.TextEdit{edit(12, 14, "")},
+			"sand: edit 0 (bytes 12-14) is outside the 13-byte document",
+		},
+		{
+			[]tree_sitter_sand.TextEdit{edit(3, 2, "")},
+			"sand: edit 0 (bytes 3-2) is outside the 13-byte document",
+		},
+	}
+	for _, tt := range tests {
+		got, err := tree_sitter_sand.ApplyEdits([]byte(src), tt.edits)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%v: got %q, %v; want error %q", tt.edits, got, err, tt.want)
+		}
+	}
+}
+
+// randomEdits returns up to n non-overlapping edits of a document of size
+// bytes, in random order.
+func randomEdits(r *rand.Rand, size, n int) []tree_sitter_sand.TextEdit {
+	cuts := make([]uint, 2*r.IntN(n+1))
+	for i := range cuts {
+		cuts[i] = uint(r.IntN(size + 1))
+	}
+	slices.Sort(cuts)
+	texts := []string{"", "x", "#[y]", "\n", "日本\n語", "\\#"}
+	var edits []tree_sitter_sand.TextEdit
+	for i := 0; i < len(cuts); i += 2 {
+		start, end := cuts[i], cuts[i+1]
+		if r.IntN(3) == 0 {
+			end = start
+		}
+		edits = append(edits, edit(start, end, texts[r.IntN(len(texts))]))
+	}
+	r.Shuffle(len(edits), func(i, j int) { edits[i], edits[j] = edits[j], edits[i] })
+	return edits
+}
+
+// applyNaive applies edits one at a time from the last to the first.
+func applyNaive(src string, edits []tree_sitter_sand.TextEdit) string {
+	order := make([]int, len(edits))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		ra, rb := edits[a].Range, edits[b].Range
+		switch {
+		case ra.StartByte != rb.StartByte:
+			return int(rb.StartByte) - int(ra.StartByte)
+		case ra.EndByte != rb.EndByte:
+			return int(rb.EndByte) - int(ra.EndByte)
+		default:
+			return b - a
+		}
+	})
+	for _, i := range order {
+		e := edits[i]
+		src = src[:e.Range.StartByte] + e.NewText + src[e.Range.EndByte:]
+	}
+	return src
+}
+
+func TestApplyEditsRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	files := corpus(t)
+	names := slices.Sorted(maps.Keys(files))
+	parser := tree_sitter.NewParser()
+	defer parser.Close()
+	if err := parser.SetLanguage(tree_sitter.NewLanguage(tree_sitter_sand.Language())); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 500 {
+		src := files[names[i%len(names)]]
+		edits := randomEdits(r, len(src), 8)
+
+		got, input, err := tree_sitter_sand.ApplyEditsInput(src, edits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := applyNaive(string(src), edits); string(got) != want {
+			t.Fatalf("edits %v:\ngot  %q\nwant %q", edits, got, want)
+		}
+
+		// The input edits let an incremental reparse reach the same tree.
+		old := parser.Parse(src, nil)
+		for _, e := range input {
+			old.Edit(&e)
+		}
+		tree := parser.Parse(got, old)
+		want, err := tree_sitter_sand.ParseTree(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a, b := tree.RootNode().ToSexp(), want.RootNode().ToSexp(); a != b {
+			t.Fatalf("edits %v: incremental tree differs:\n%s\nwant\n%s", edits, a, b)
+		}
+		old.Close()
+		tree.Close()
+		want.Close()
+	}
+}
+
+func TestApplyEditsPositions(t *testing.T) {
+	src := []byte("#(en)\n\n#s[日本]\n")
+	_, input, err := tree_sitter_sand.ApplyEditsInput(src, []tree_sitter_sand.TextEdit{
+		edit(2, 4, "ja"),
+		edit(10, 16, "a\nbc"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(input) != 2 || input[0].StartByte != 10 || input[1].StartByte != 2 {
+		t.Fatalf("input edits not from the end: %+v", input)
+	}
+	e := input[0]
+	if e.StartPosition.Row != 2 || e.StartPosition.Column != 3 || e.OldEndPosition.Column != 9 {
+		t.Errorf("positions = %+v", e)
+	}
+	if e.NewEndByte != 14 || e.NewEndPosition.Row != 3 || e.NewEndPosition.Column != 2 {
+		t.Errorf("new end = %d %+v", e.NewEndByte, e.NewEndPosition)
+	}
+	if !strings.HasPrefix(string(src[e.StartByte:]), "日本") {
+		t.Errorf("edit starts at %q", src[e.StartByte:])
+	}
+}
@@ -0,0 +1,171 @@
+package tree_sitter_sand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// InvalidUTF8Mode is what [ParseWithOptions] and the renderers and formatter
+// do with a source that is not valid UTF-8.
+type InvalidUTF8Mode int
+
+const (
+	// InvalidUTF8Reject fails with an [*InvalidUTF8Error]. It is the
+	// default, as `sand` only reads UTF-8 text.
+	InvalidUTF8Reject InvalidUTF8Mode = iota
+	// InvalidUTF8Replace replaces each run of invalid bytes with U+FFFD and
+	// goes on with the result, listing the runs as [Replacement] values.
+	InvalidUTF8Replace
+)
+
+// ErrInvalidUTF8 is matched by [errors.Is] for every [*InvalidUTF8Error].
+var ErrInvalidUTF8 = errors.New("sand: invalid UTF-8")
+
+// InvalidUTF8Error reports a source that is not valid UTF-8.
+type InvalidUTF8Error struct {
+	// Offset is the byte offset of the first invalid sequence.
+	Offset uint
+	// Encoding is "UTF-16LE" or "UTF-16BE" for a source that starts with a
+	// UTF-16 byte order mark, which needs converting rather than
+	// replacing. It is empty otherwise.
+	Encoding string
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	if e.Encoding != "" {
+		return fmt.Sprintf("sand: the source is %s, not UTF-8; convert it to UTF-8 first", e.Encoding)
+	}
+	return fmt.Sprintf("sand: invalid UTF-8 at byte %d", e.Offset)
+}
+
+func (e *InvalidUTF8Error) Is(target error) bool {
+	return target == ErrInvalidUTF8
+}
+
+// Replacement is a run of invalid UTF-8 that [InvalidUTF8Replace] replaced
+// with U+FFFD.
+type Replacement struct {
+	// Start and End delimit the invalid bytes in the source as given.
+	Start, End uint
+	// Offset is where the U+FFFD is in the source after replacement.
+	Offset uint
+}
+
+// CheckUTF8 applies mode to src. It returns src itself if it is valid UTF-8,
+// and otherwise an [*InvalidUTF8Error] or, under [InvalidUTF8Replace], a copy
+// of src with the invalid runs replaced and the list of them.
+//
+// A source starting with a UTF-16 byte order mark fails in either mode.
+func CheckUTF8(src []byte, mode InvalidUTF8Mode) ([]byte, []Replacement, error) {
+	switch {
+	case bytes.HasPrefix(src, []byte{0xff, 0xfe}):
+		return nil, nil, &InvalidUTF8Error{Encoding: "UTF-16LE"}
+	case bytes.HasPrefix(src, []byte{0xfe, 0xff}):
+		return nil, nil, &InvalidUTF8Error{Encoding: "UTF-16BE"}
+	case utf8.Valid(src):
+		return src, nil, nil
+	}
+	if mode != InvalidUTF8Replace {
+		return nil, nil, &InvalidUTF8Error{Offset: uint(invalidAt(src))}
+	}
+
+	out := make([]byte, 0, len(src)+8)
+	var replaced []Replacement
+	for i := 0; i < len(src); {
+		n := invalidAt(src[i:])
+		if n < 0 {
+			out = append(out, src[i:]...)
+			break
+		}
+		out = append(out, src[i:i+n]...)
+		r := Replacement{Start: uint(i + n), Offset: uint(len(out))}
+		i += n
+		for i < len(src) {
+			if c, size := utf8.DecodeRune(src[i:]); c != utf8.RuneError || size > 1 {
+				break
+			}
+			i++
+		}
+		r.End = uint(i)
+		replaced = append(replaced, r)
+		out = utf8.AppendRune(out, utf8.RuneError)
+	}
+	return out, replaced, nil
+}
+
+// invalidAt returns the offset of the first invalid sequence in src, or -1.
+func invalidAt(src []byte) int {
+	for i := 0; i < len(src); {
+		c, size := utf8.DecodeRune(src[i:])
+		if c == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
+
+// replacementLen is the length of U+FFFD in UTF-8.
+const replacementLen = len("\ufffd")
+
+// OriginalOffset maps offset, in a source after replacement, back to the
+// source as given. An offset inside a U+FFFD maps to the start of the bytes
+// it replaced.
+func OriginalOffset(replaced []Replacement, offset uint) uint {
+	shift := 0
+	for _, r := range replaced {
+		if offset < r.Offset {
+			break
+		}
+		if offset < r.Offset+uint(replacementLen) {
+			return r.Start
+		}
+		shift += int(r.End-r.Start) - replacementLen
+	}
+	return uint(int(offset) + shift)
+}
+
+// parseLenient parses src for the helpers that have no error to return.
+// Invalid UTF-8 is replaced as under [InvalidUTF8Replace], so that one
+// stray byte does not empty their results, and the ranges of the document
+// are mapped back to src. The texts of the document keep the U+FFFD. Only
+// a UTF-16 source or an unusable parser make it fail.
+func parseLenient(src []byte) (*Document, error) {
+	doc, err := ParseWithOptions(src, ParseOptions{InvalidUTF8: InvalidUTF8Replace})
+	if err != nil || doc.Replaced == nil {
+		return doc, err
+	}
+	lines := newLineIndex(src)
+	orig := func(r *Range) {
+		*r = lines.rangeOf(OriginalOffset(doc.Replaced, r.StartByte), OriginalOffset(doc.Replaced, r.EndByte))
+	}
+	orig(&doc.Range)
+	orig(&doc.NamesRange)
+	for i := range doc.Errors {
+		orig(&doc.Errors[i].Range)
+	}
+	var walk func(nodes []Node)
+	walk = func(nodes []Node) {
+		for _, node := range nodes {
+			switch node := node.(type) {
+			case *Section:
+				orig(&node.Range)
+				orig(&node.Heading)
+				orig(&node.TitleRange)
+				walk(node.Children)
+			case *Paragraph:
+				orig(&node.Range)
+				for _, span := range node.Spans {
+					orig(&span.Range)
+					for i := range span.Contents {
+						orig(&span.Contents[i].Range)
+					}
+				}
+			}
+		}
+	}
+	walk(doc.Children)
+	return doc, nil
+}
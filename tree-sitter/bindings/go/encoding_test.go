@@ -0,0 +1,116 @@
+package tree_sitter_sand_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+func TestCheckUTF8(t *testing.T) {
+	for _, tt := range []struct {
+		file string
+		// err is the error under both modes, or under InvalidUTF8Reject
+		// if replaced is set.
+		err      string
+		replaced []tree_sitter_sand.Replacement
+	}{
+		{
+			// Smart quotes, a dash and an accent from Windows-1252.
+			file: "cp1252.sand",
+			err:  "sand: invalid UTF-8 at byte 10",
+			replaced: []tree_sitter_sand.Replacement{
+				{Start: 10, End: 11, Offset: 10},
+				{Start: 17, End: 18, Offset: 19},
+				{Start: 24, End: 25, Offset: 28},
+				{Start: 29, End: 30, Offset: 35},
+			},
+		},
+		{
+			// A multi-byte sequence cut short is one run.
+			file:     "truncated.sand",
+			err:      "sand: invalid UTF-8 at byte 12",
+			replaced: []tree_sitter_sand.Replacement{{Start: 12, End: 14, Offset: 12}},
+		},
+		{file: "utf16le.sand", err: "sand: the source is UTF-16LE, not UTF-8; convert it to UTF-8 first"},
+		{file: "utf16be.sand", err: "sand: the source is UTF-16BE, not UTF-8; convert it to UTF-8 first"},
+	} {
+		src, err := os.ReadFile("testdata/encoding/" + tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = tree_sitter_sand.CheckUTF8(src, tree_sitter_sand.InvalidUTF8Reject)
+		if !errors.Is(err, tree_sitter_sand.ErrInvalidUTF8) || err.Error() != tt.err {
+			t.Errorf("%s: got error %v, want %q", tt.file, err, tt.err)
+		}
+		if _, err := tree_sitter_sand.Parse(src); !errors.Is(err, tree_sitter_sand.ErrInvalidUTF8) {
+			t.Errorf("%s: Parse: got error %v, want ErrInvalidUTF8", tt.file, err)
+		}
+
+		out, replaced, err := tree_sitter_sand.CheckUTF8(src, tree_sitter_sand.InvalidUTF8Replace)
+		if tt.replaced == nil {
+			var ierr *tree_sitter_sand.InvalidUTF8Error
+			if !errors.As(err, &ierr) || ierr.Encoding == "" {
+				t.Errorf("%s: replacing: got error %v, want the encoding", tt.file, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.file, err)
+		}
+		if fmt.Sprint(replaced) != fmt.Sprint(tt.replaced) {
+			t.Errorf("%s: got replacements %v, want %v", tt.file, replaced, tt.replaced)
+		}
+		for _, r := range replaced {
+			if got := string(out[r.Offset : r.Offset+3]); got != "\ufffd" {
+				t.Errorf("%s: got %q at %d, want U+FFFD", tt.file, got, r.Offset)
+			}
+			if got := tree_sitter_sand.OriginalOffset(replaced, r.Offset+1); got != r.Start {
+				t.Errorf("%s: offset %d maps to %d, want %d", tt.file, r.Offset+1, got, r.Start)
+			}
+			if got := tree_sitter_sand.OriginalOffset(replaced, r.Offset+3); got != r.End {
+				t.Errorf("%s: offset %d maps to %d, want %d", tt.file, r.Offset+3, got, r.End)
+			}
+		}
+	}
+}
+
+func TestCheckUTF8Valid(t *testing.T) {
+	src := []byte("\ufeff#(ja)\n\n#[日本\ufffd]\n")
+	for _, mode := range []tree_sitter_sand.InvalidUTF8Mode{tree_sitter_sand.InvalidUTF8Reject, tree_sitter_sand.InvalidUTF8Replace} {
+		out, replaced, err := tree_sitter_sand.CheckUTF8(src, mode)
+		if err != nil || &out[0] != &src[0] || replaced != nil {
+			t.Errorf("mode %d: got %q, %v, %v; want the source unchanged", mode, out, replaced, err)
+		}
+	}
+}
+
+func TestParseWithOptions(t *testing.T) {
+	src, err := os.ReadFile("testdata/encoding/cp1252.sand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := tree_sitter_sand.ParseWithOptions(src, tree_sitter_sand.ParseOptions{InvalidUTF8: tree_sitter_sand.InvalidUTF8Replace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Errors) > 0 || len(doc.Replaced) != 4 {
+		t.Fatalf("got errors %v and replacements %v", doc.Errors, doc.Replaced)
+	}
+	c := doc.Children[0].(*tree_sitter_sand.Paragraph).Spans[0].Contents[0]
+	if want := "\ufffdQuoted\ufffd text \ufffd caf\ufffd"; c.Text != want {
+		t.Errorf("got content %q, want %q", c.Text, want)
+	}
+	start := tree_sitter_sand.OriginalOffset(doc.Replaced, c.Range.StartByte)
+	end := tree_sitter_sand.OriginalOffset(doc.Replaced, c.Range.EndByte)
+	if want := "\x93Quoted\x94 text \x96 caf\xe9"; string(src[start:end]) != want {
+		t.Errorf("content maps back to %q, want %q", src[start:end], want)
+	}
+}
+
+// invalidDoc has a stray byte in a title, in prose and in a sentence, for
+// the helpers that parse with invalid UTF-8 replaced. Offsets: the heading
+// is 7-17, the prose 19-27 and the selector 38-50.
+const invalidDoc = "#(en)\n\n## Title \xff\n\nProse \xff.\n\n#s[caf\xff] #.missing.en\n\n## Two\n\n#t[x]\n"
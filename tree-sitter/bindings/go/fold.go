@@ -0,0 +1,107 @@
+package tree_sitter_sand
+
+// FoldKind is what a [FoldRange] folds.
+type FoldKind int
+
+const (
+	// FoldSection folds a section to its heading line.
+	FoldSection FoldKind = iota + 1
+	// FoldComment folds a paragraph of prose alone, which is a note for the
+	// author that `sand out` leaves out.
+	FoldComment
+	// FoldBlock folds a sentence definition or apply-all block that spans
+	// several lines.
+	FoldBlock
+)
+
+func (k FoldKind) String() string {
+	switch k {
+	case FoldSection:
+		return "section"
+	case FoldComment:
+		return "comment"
+	case FoldBlock:
+		return "block"
+	}
+	return "unknown"
+}
+
+// FoldRange is a region an editor can fold, as in an LSP
+// textDocument/foldingRange response. Lines are counted from 0; the start
+// line stays visible when the range is folded.
+type FoldRange struct {
+	StartLine int
+	EndLine   int
+	Kind      FoldKind
+}
+
+// FoldOptions controls [FoldingRanges].
+type FoldOptions struct {
+	// TrailingBlankLines makes a section fold include the blank lines after
+	// its last content, up to the next heading or the end of the document.
+	TrailingBlankLines bool
+}
+
+// FoldingRanges returns the fold regions of src in document order, outer
+// ranges before the ranges inside them. A section folds from its heading to
+// its last line, which is before the next heading of the same or a lower
+// level. Constructs on a single line give no range.
+func FoldingRanges(src []byte, opts FoldOptions) []FoldRange {
+	doc, err := parseLenient(src)
+	if err != nil {
+		return nil
+	}
+	var folds []FoldRange
+	add := func(kind FoldKind, r Range) {
+		if r.EndPoint.Row > r.StartPoint.Row {
+			folds = append(folds, FoldRange{StartLine: int(r.StartPoint.Row), EndLine: int(r.EndPoint.Row), Kind: kind})
+		}
+	}
+	var walk func(nodes []Node)
+	walk = func(nodes []Node) {
+		for _, node := range nodes {
+			switch node := node.(type) {
+			case *Section:
+				r := node.Range
+				if opts.TrailingBlankLines {
+					r.EndPoint.Row = uint(blankLinesEnd(src, r))
+				}
+				add(FoldSection, r)
+				walk(node.Children)
+			case *Paragraph:
+				prose := true
+				for _, span := range node.Spans {
+					if span.Kind != SpanText {
+						prose = false
+						if span.Kind != SpanSelector {
+							add(FoldBlock, span.Range)
+						}
+					}
+				}
+				if prose {
+					add(FoldComment, node.Range)
+				}
+			}
+		}
+	}
+	walk(doc.Children)
+	return folds
+}
+
+// blankLinesEnd returns the last line of r and the blank lines after it.
+func blankLinesEnd(src []byte, r Range) int {
+	row := int(r.EndPoint.Row)
+	for i := r.EndByte; i < uint(len(src)); i++ {
+		switch src[i] {
+		case '\n':
+			// A line break at the very end starts no line of its own.
+			if i+1 < uint(len(src)) {
+				row++
+			}
+		case ' ', '\t', '\r':
+		default:
+			return row - 1
+		}
+	}
+	return row
+}
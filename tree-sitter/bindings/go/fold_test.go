@@ -0,0 +1,82 @@
+package tree_sitter_sand_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// describeFolds renders folds as "start-end kind | first line" lines, with
+// lines counted from 1 as editors show them.
+func describeFolds(src []byte, folds []tree_sitter_sand.FoldRange) string {
+	lines := strings.Split(string(src), "\n")
+	var b strings.Builder
+	for _, f := range folds {
+		fmt.Fprintf(&b, "%d-%d %s | %s\n", f.StartLine+1, f.EndLine+1, f.Kind, lines[f.StartLine])
+	}
+	return b.String()
+}
+
+func TestFoldingRangesGolden(t *testing.T) {
+	for name, src := range corpus(t) {
+		t.Run(name, func(t *testing.T) {
+			var got bytes.Buffer
+			got.WriteString(describeFolds(src, tree_sitter_sand.FoldingRanges(src, tree_sitter_sand.FoldOptions{})))
+			got.WriteString("\nwith trailing blank lines:\n")
+			got.WriteString(describeFolds(src, tree_sitter_sand.FoldingRanges(src, tree_sitter_sand.FoldOptions{TrailingBlankLines: true})))
+
+			golden := filepath.Join("testdata", strings.TrimSuffix(name, ".sand")+".folds")
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestFoldingRanges(t *testing.T) {
+	src := []byte("#(en)\n\n## One\n#[a]\n\n\n### Two\n#[b\nc]\n\n## Three\n")
+	got := describeFolds(src, tree_sitter_sand.FoldingRanges(src, tree_sitter_sand.FoldOptions{}))
+	want := "3-9 section | ## One\n7-9 section | ### Two\n8-9 block | #[b\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	got = describeFolds(src, tree_sitter_sand.FoldingRanges(src, tree_sitter_sand.FoldOptions{TrailingBlankLines: true}))
+	want = "3-10 section | ## One\n7-10 section | ### Two\n8-9 block | #[b\n"
+	if got != want {
+		t.Errorf("with trailing blank lines: got\n%s\nwant\n%s", got, want)
+	}
+
+	// A heading alone and the last section of a document ending in blank
+	// lines.
+	src = []byte("#(en)\n\n## Empty\n## Last\n#[x]\n\n\n")
+	got = describeFolds(src, tree_sitter_sand.FoldingRanges(src, tree_sitter_sand.FoldOptions{TrailingBlankLines: true}))
+	want = "4-7 section | ## Last\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFoldingRangesInvalidUTF8(t *testing.T) {
+	folds := tree_sitter_sand.FoldingRanges([]byte(invalidDoc), tree_sitter_sand.FoldOptions{})
+	if len(folds) != 2 || folds[0].StartLine != 2 || folds[0].EndLine != 6 {
+		t.Errorf("got %+v", folds)
+	}
+}
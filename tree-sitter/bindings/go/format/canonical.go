@@ -0,0 +1,149 @@
+package format
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"slices"
+	"strings"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+// canonicalOptions puts every paragraph on one line.
+var canonicalOptions = Options{MaxWidth: 1 << 30, ReflowProse: true, HeadingStyle: HeadingSpaced, FinalNewline: true}
+
+// Canonicalize returns the canonical form of src, for comparing documents by
+// content. Documents that render to the same HTML with the default options
+// of [github.com/satler-git/sand-markup/bindings/go/render/html.RenderHTML],
+// for every name they define, canonicalize to the same bytes. The canonical
+// form keeps only what is rendered:
+//
+//   - the first name definition, written as `#(en, ja)`;
+//   - headings without aliases, with the levels from 6 on, which all
+//     render as `h6`, numbered from the level of their parent so that they
+//     nest the same;
+//   - the paragraphs that hold a sentence or an apply-all block, without
+//     prose and selectors, on one line each;
+//   - the contents of sentences up to one per name, with runs of whitespace
+//     collapsed to one space and none at either end, as `sand out` reads
+//     them;
+//   - the apply-all blocks that are rendered for some name, with a target
+//     list only if they are not rendered for all of them.
+//
+// Heading ids are not rendered by default, so documents whose aliases or
+// titles give different [tree_sitter_sand.SectionAnchors] can canonicalize
+// the same. Documents with syntax errors are not canonicalized; the first
+// error is returned instead.
+func Canonicalize(src []byte) ([]byte, error) {
+	src = bytes.TrimPrefix(src, []byte("\ufeff"))
+	src = bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+	src = bytes.ReplaceAll(src, []byte("\r"), []byte("\n"))
+
+	doc, err := tree_sitter_sand.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Errors) > 0 {
+		return nil, doc.Errors[0]
+	}
+
+	// names are the names rendered: without a name definition, the
+	// renderers take the first content of sentences and leave out targeted
+	// apply-all blocks.
+	names := doc.Names
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	var out bytes.Buffer
+	if len(doc.Names) > 0 {
+		out.WriteString("#(" + strings.Join(doc.Names, ", ") + ")\n\n")
+	}
+	var walk func(nodes []tree_sitter_sand.Node, parent int)
+	walk = func(nodes []tree_sitter_sand.Node, parent int) {
+		for _, node := range nodes {
+			switch node := node.(type) {
+			case *tree_sitter_sand.Section:
+				level := node.Level
+				if level >= 6 {
+					level = max(parent, 5) + 1
+				}
+				out.WriteString("#" + strings.Repeat("#", level) + " " + escapeTitle(node.Title) + "\n\n")
+				walk(node.Children, level)
+			case *tree_sitter_sand.Paragraph:
+				n := out.Len()
+				for _, span := range node.Spans {
+					canonicalSpan(&out, src, span, names, out.Len() > n)
+				}
+				if out.Len() > n {
+					out.WriteString("\n\n")
+				}
+			}
+		}
+	}
+	walk(doc.Children, 0)
+
+	return FormatWithOptions(out.Bytes(), canonicalOptions)
+}
+
+// escapeTitle escapes the characters of a heading title that would be read
+// back differently.
+func escapeTitle(title string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(title)
+}
+
+// canonicalSpan writes the canonical form of span, after a space if sep is
+// set. Only sentences and apply-all blocks rendered for one of names are
+// written.
+func canonicalSpan(out *bytes.Buffer, src []byte, span *tree_sitter_sand.InlineSpan, names []string, sep bool) {
+	content := func(c tree_sitter_sand.Content) {
+		raw := src[c.Range.StartByte:c.Range.EndByte]
+		if fields := strings.Fields(string(raw)); len(fields) > 0 {
+			out.WriteString(strings.Join(fields, " "))
+		} else if len(raw) > 0 {
+			// Contents can't be empty.
+			out.WriteByte(' ')
+		}
+	}
+
+	switch span.Kind {
+	case tree_sitter_sand.SpanSentence:
+		if sep {
+			out.WriteByte(' ')
+		}
+		out.WriteByte('#')
+		for _, c := range span.Contents[:min(len(span.Contents), len(names))] {
+			out.WriteByte('[')
+			content(c)
+			out.WriteByte(']')
+		}
+	case tree_sitter_sand.SpanApplyAll:
+		var targets []string
+		for _, name := range names {
+			if span.Targets == nil || slices.Contains(span.Targets, name) {
+				targets = append(targets, name)
+			}
+		}
+		if len(targets) == 0 || len(span.Contents) == 0 {
+			return
+		}
+		if sep {
+			out.WriteByte(' ')
+		}
+		out.WriteString("#{")
+		if len(targets) < len(names) {
+			out.WriteString("[" + strings.Join(targets, ", ") + "], ")
+		}
+		out.WriteByte('{')
+		content(span.Contents[0])
+		out.WriteString("}}")
+	}
+}
+
+// ContentHash returns the SHA-256 hash of the [Canonicalize] form of src.
+func ContentHash(src []byte) ([32]byte, error) {
+	canonical, err := Canonicalize(src)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(canonical), nil
+}
@@ -0,0 +1,177 @@
+package format_test
+
+import (
+	"bytes"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/format"
+	"github.com/satler-git/sand-markup/bindings/go/render/html"
+)
+
+// renders returns the HTML of src for every name it defines, with the
+// default options that Canonicalize keeps the output of.
+func renders(t *testing.T, src []byte) []byte {
+	t.Helper()
+	doc, err := tree_sitter_sand.Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []byte
+	for _, name := range append([]string{""}, doc.Names...) {
+		b, err := html.RenderHTML(src, html.Options{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, "<!-- "+name+" -->\n"...)
+		out = append(out, b...)
+	}
+	return out
+}
+
+func TestCanonicalizeEquivalent(t *testing.T) {
+	for _, tt := range []struct {
+		name, a, b string
+	}{
+		{
+			"byte order mark and line endings",
+			"#(en)\n\n## Title\n\n#[Hello]\n",
+			"\ufeff#(en)\r\n\r\n## Title   \r\n\r\n\r\n#[Hello]\r\n",
+		},
+		{
+			"headings and names",
+			"#(en, ja)\n\n#intro# Intro\n\n#[a][b]\n",
+			"#( en ,ja )\n#intro#Intro\n#[a][b]",
+		},
+		{
+			"whitespace in contents",
+			"#(en, ja)\n\n#s[Hello world][こんにちは]\n",
+			"#(en, ja)\n\n#s[\n    Hello\n    world\n][  こんにちは\t]\n",
+		},
+		{
+			"space between contents",
+			"#(en, ja)\n\n#[a][b]\n",
+			"#(en, ja)\n\n#[a]  [b]\n",
+		},
+		{
+			"apply-all targets",
+			"#(en, ja)\n\n#{{x}} #{[en, ja], {y}}\n",
+			"#(en, ja)\n\n#{all, { x }} #{[en,ja] ,{y}}\n",
+		},
+		{
+			"prose wrapping",
+			"#(en)\n\nA note for the author\nover two lines. #[Text]\n",
+			"#(en)\n\nA note for the   author over\n  two lines.\n#[Text]\n",
+		},
+		{
+			"prose, aliases and selectors",
+			"#(en, ja)\n\n#s# Title\n\nNote. #a[Hello][こんにちは] #.s.a.en\n",
+			"#(en, ja)\n\n## Title\n\nOther note. #b[Hello][こんにちは]\n\n#.s.b.en\n",
+		},
+		{
+			"prose paragraphs",
+			"#(en)\n\n#[a]\n\n#[b]\n",
+			"#(en)\n\nIntro.\n\n#[a] between\n\nonly prose\n\n#[b]\n",
+		},
+		{
+			"contents past the names",
+			"#(en)\n\n#[a] #[b]\n",
+			"#(en)\n\n#[a][x] #[b][y][z]\n",
+		},
+		{
+			"targets of every name or none",
+			"#(en, ja)\n\n#[a][b] #{[en], {c}}\n",
+			"#(en, ja)\n\n#[a][b] #{[en, fr], {c}} #{[fr], {d}}\n",
+		},
+		{
+			"no names",
+			"#[a]\n",
+			"#[a][b] #{[en], {c}}\n",
+		},
+		{
+			"levels from 6 on",
+			"#(en)\n\n###### A\n\n####### B\n\n##### C\n\n####### D\n\n#[x]\n",
+			"#(en)\n\n###### A\n\n######### B\n\n##### C\n\n######## D\n\n#[x]\n",
+		},
+		{
+			"escaped titles",
+			"#(en)\n\n## a # b\n\n#[x]\n",
+			"#(en)\n\n## a \\# b\n\n#[x]\n",
+		},
+	} {
+		a, b := []byte(tt.a), []byte(tt.b)
+		if !bytes.Equal(renders(t, a), renders(t, b)) {
+			t.Fatalf("%s: the fixtures render differently", tt.name)
+		}
+		ca, err := format.Canonicalize(a)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		cb, err := format.Canonicalize(b)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !bytes.Equal(ca, cb) {
+			t.Errorf("%s: canonical forms differ:\n%s\nand:\n%s", tt.name, ca, cb)
+		}
+		ha, _ := format.ContentHash(a)
+		hb, _ := format.ContentHash(b)
+		if ha != hb {
+			t.Errorf("%s: content hashes differ", tt.name)
+		}
+	}
+}
+
+func TestCanonicalizeDistinct(t *testing.T) {
+	base := "#(en, ja)\n\n#s# Title\n\nNote. #a[Hello][こんにちは] #.s.a.en\n"
+	for _, other := range []string{
+		"#(en, ja)\n\n#s# Title\n\nNote. #a[Hello!][こんにちは] #.s.a.en\n",
+		"#(en, ja)\n\n#s# Other\n\nNote. #a[Hello][こんにちは] #.s.a.en\n",
+		"#(en, ja)\n\n#s## Title\n\nNote. #a[Hello][こんにちは] #.s.a.en\n",
+		"#(ja, en)\n\n#s# Title\n\nNote. #a[Hello][こんにちは] #.s.a.en\n",
+		"#(en, ja)\n\n#s# Title\n\nNote. #a[Hello][こんにちは] #{[ja], {!}}\n",
+		"#(en, ja)\n\n#s# Title\n\n#a[Hello][こんにちは]\n\n#[!][!]\n",
+	} {
+		if bytes.Equal(renders(t, []byte(base)), renders(t, []byte(other))) {
+			t.Fatalf("%q renders like the base", other)
+		}
+		h1, err := format.ContentHash([]byte(base))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h2, err := format.ContentHash([]byte(other))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h1 == h2 {
+			t.Errorf("%q and %q hash the same", base, other)
+		}
+	}
+}
+
+func TestCanonicalizeCorpus(t *testing.T) {
+	for path, src := range documents(t) {
+		once, err := format.Canonicalize(src)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if !bytes.Equal(renders(t, once), renders(t, src)) {
+			t.Errorf("%s: canonical form renders differently:\n%s", path, once)
+		}
+		twice, err := format.Canonicalize(once)
+		if err != nil {
+			t.Errorf("%s: canonical form does not parse: %v", path, err)
+			continue
+		}
+		if !bytes.Equal(once, twice) {
+			t.Errorf("%s: canonicalization is not idempotent:\n%s\nthen:\n%s", path, once, twice)
+		}
+	}
+}
+
+func TestCanonicalizeSyntaxError(t *testing.T) {
+	if _, err := format.ContentHash([]byte("#(en)\n\n#s[unterminated\n")); err == nil {
+		t.Error("ContentHash accepted a document with syntax errors")
+	}
+}
@@ -0,0 +1,555 @@
+// Package format formats Sand source files.
+//
+// The rules only touch whitespace the `sand out` renderer ignores, so a
+// formatted document renders exactly like the original:
+//
+//   - name definitions, headings and paragraphs are separated by a single
+//     blank line, and the file ends with one newline;
+//   - headings are written as `#alias## Title`, with one space before the
+//     title;
+//   - name definitions are written as `#(en, ja)`;
+//   - trailing whitespace is removed from prose lines. Line breaks and
+//     indentation inside a paragraph are kept.
+//
+// The bodies of sentence definitions and apply-all blocks are copied byte for
+// byte. [Options] changes some of these rules; [Format] uses
+// [DefaultOptions].
+package format
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/internal/observe"
+	"github.com/satler-git/sand-markup/bindings/go/internal/width"
+	"github.com/satler-git/sand-markup/bindings/go/position"
+)
+
+// HeadingStyle is how headings are written.
+type HeadingStyle int
+
+const (
+	// HeadingSpaced writes one space between the hashes and the title:
+	// `#alias## Title`.
+	HeadingSpaced HeadingStyle = iota
+	// HeadingCompact writes the title right after the hashes: `#alias##Title`.
+	// A space is still written before an empty title or one starting with
+	// `#`, which would otherwise be read as more hashes.
+	HeadingCompact
+)
+
+// Options controls [FormatWithOptions].
+type Options struct {
+	// MaxWidth is the number of columns ReflowProse fills lines up to, 80 if
+	// it is zero or less. Wide runes such as CJK ideographs take two columns.
+	MaxWidth int
+	// ReflowProse rewraps the lines of every paragraph to fill MaxWidth,
+	// instead of keeping its line breaks and indentation. Sentence
+	// definitions, apply-all blocks and selectors are never split, and a
+	// line ending in the `\n` escape keeps its break. Lines may also break
+	// between wide runes, and a single line break between two wide runes is
+	// read as no space at all, as usual in CJK text.
+	ReflowProse bool
+	// HeadingStyle is how headings are written.
+	HeadingStyle HeadingStyle
+	// FinalNewline ends a non-empty file with a newline.
+	FinalNewline bool
+	// InvalidUTF8 is what to do with a source that is not valid UTF-8. With
+	// [tree_sitter_sand.InvalidUTF8Replace] the output has the invalid runs
+	// replaced. [Range] always rejects such a source.
+	InvalidUTF8 tree_sitter_sand.InvalidUTF8Mode
+	// Observer, if not nil, is told about the
+	// [tree_sitter_sand.StageFormat] and the [tree_sitter_sand.StageParse]
+	// inside it.
+	Observer tree_sitter_sand.Observer
+}
+
+// DefaultOptions returns the options [Format] uses.
+func DefaultOptions() Options {
+	return Options{MaxWidth: 80, FinalNewline: true}
+}
+
+// block is a top-level piece of output and the source range it replaces.
+type block struct {
+	start, end uint
+	text       string
+}
+
+// Format returns the canonical formatting of src.
+//
+// Blocks that hold syntax errors are copied as they are, together with the
+// whitespace between blocks an error spans, and everything around them is
+// formatted. The output is then returned with a [*PartialError] listing the
+// regions left alone. If formatting around the errors would make the output
+// recover from them differently, so that formatting it again would skip
+// other text, the whole document is left alone instead. A document without
+// errors is formatted the same either way.
+func Format(src []byte) ([]byte, error) {
+	return FormatWithOptions(src, DefaultOptions())
+}
+
+// FormatWithOptions is like [Format] with the rules changed by opts.
+func FormatWithOptions(src []byte, opts Options) ([]byte, error) {
+	return FormatContext(context.Background(), src, opts)
+}
+
+// FormatContext is like [FormatWithOptions] but gives up when ctx is done,
+// with an error that wraps ctx.Err().
+func FormatContext(ctx context.Context, src []byte, opts Options) (out []byte, err error) {
+	stage := observe.Start(opts.Observer, tree_sitter_sand.StageFormat, len(src))
+	defer func() {
+		stage.End(err, func(m map[string]any) {
+			if out != nil {
+				m[tree_sitter_sand.MetaOutputBytes] = len(out)
+			}
+		})
+	}()
+
+	src, _, err = tree_sitter_sand.CheckUTF8(src, opts.InvalidUTF8)
+	if err != nil {
+		return nil, err
+	}
+	pieces, err := layout(ctx, src, opts)
+	if pieces == nil {
+		return nil, err
+	}
+	return []byte(join(pieces)), err
+}
+
+// PartialError is returned together with the output when the source has
+// syntax errors. It unwraps to the first [tree_sitter_sand.ParseError].
+type PartialError struct {
+	// Skipped lists the ranges of the source that were copied unformatted,
+	// in order. They span whole blocks, and at the start and end of the
+	// document the whitespace beyond them.
+	Skipped []tree_sitter_sand.Range
+	// Errors lists the syntax errors in the source.
+	Errors []tree_sitter_sand.ParseError
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("sand: format: %d regions with syntax errors left unformatted", len(e.Skipped))
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Errors[0]
+}
+
+// layout returns what src formats to, in pieces whose ranges cover src in
+// order: the blocks and the whitespace around them, alternating and starting
+// and ending with whitespace. If src has syntax errors, the pieces are
+// returned with a *PartialError.
+//
+// How the parser recovers from an error depends on what is around it, so
+// formatting the blocks next to a skipped one can make the output skip
+// different text. The output is laid out again to check, and if it would
+// not skip the same text and stay as it is, the whole of src is skipped.
+func layout(ctx context.Context, src []byte, opts Options) ([]block, error) {
+	pieces, err := arrange(ctx, src, opts)
+	var perr *PartialError
+	if !errors.As(err, &perr) {
+		return pieces, err
+	}
+	out := join(pieces)
+	check := opts
+	check.Observer = nil
+	again, err := arrange(ctx, []byte(out), check)
+	var aerr *PartialError
+	if err != nil && !errors.As(err, &aerr) {
+		return nil, err
+	}
+	if aerr != nil && join(again) == out && slices.Equal(skipped(src, perr), skipped([]byte(out), aerr)) {
+		return pieces, perr
+	}
+	perr.Skipped = []tree_sitter_sand.Range{position.NewIndex(src).Range(0, len(src))}
+	end := uint(len(src))
+	return []block{{}, {end: end, text: string(src)}, {start: end, end: end}}, perr
+}
+
+// join returns the text of pieces.
+func join(pieces []block) string {
+	var b strings.Builder
+	for _, p := range pieces {
+		b.WriteString(p.text)
+	}
+	return b.String()
+}
+
+// skipped returns the text of the ranges of src that err left unformatted.
+func skipped(src []byte, err *PartialError) []string {
+	var texts []string
+	for _, r := range err.Skipped {
+		texts = append(texts, string(src[r.StartByte:r.EndByte]))
+	}
+	return texts
+}
+
+// arrange is layout without the check that skipped text stays skipped.
+func arrange(ctx context.Context, src []byte, opts Options) ([]block, error) {
+	if opts.MaxWidth <= 0 {
+		opts.MaxWidth = 80
+	}
+	doc, err := tree_sitter_sand.ParseContextWithOptions(ctx, src, tree_sitter_sand.ParseOptions{Observer: opts.Observer})
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []block
+	if doc.Names != nil {
+		blocks = append(blocks, block{
+			start: doc.NamesRange.StartByte,
+			end:   doc.NamesRange.EndByte,
+			text:  "#(" + strings.Join(doc.Names, ", ") + ")",
+		})
+	}
+	var walk func(nodes []tree_sitter_sand.Node)
+	walk = func(nodes []tree_sitter_sand.Node) {
+		for _, node := range nodes {
+			if err != nil {
+				return
+			}
+			switch node := node.(type) {
+			case *tree_sitter_sand.Section:
+				if err = ctx.Err(); err != nil {
+					return
+				}
+				blocks = append(blocks, heading(src, node, opts.HeadingStyle))
+				walk(node.Children)
+			case *tree_sitter_sand.Paragraph:
+				if opts.ReflowProse {
+					// A paragraph of whitespace that the parser does not
+					// skip, such as a form feed, reflows to nothing. It is
+					// left to the whitespace between blocks.
+					if b := reflow(src, node, opts.MaxWidth); b.text != "" {
+						blocks = append(blocks, b)
+					}
+				} else {
+					blocks = append(blocks, paragraph(src, node))
+				}
+			}
+		}
+	}
+	walk(doc.Children)
+	if err != nil {
+		return nil, fmt.Errorf("sand: format: %w", err)
+	}
+	slices.SortFunc(blocks, func(a, b block) int { return cmp.Compare(a.start, b.start) })
+
+	var pieces []block
+	var pos uint
+	add := func(b block) {
+		sep := ""
+		if len(pieces) > 0 {
+			sep = "\n\n"
+		}
+		pieces = append(pieces, block{start: pos, end: b.start, text: sep}, b)
+		pos = b.end
+	}
+	// Source outside every block, such as a repeated name definition, is
+	// kept as it is rather than dropped.
+	gap := func(end uint) {
+		text := src[pos:end]
+		start := pos + uint(len(text)-len(bytes.TrimLeftFunc(text, unicode.IsSpace)))
+		text = bytes.TrimSpace(text)
+		if len(text) > 0 {
+			add(block{start: start, end: start + uint(len(text)), text: string(text)})
+		}
+	}
+	for _, b := range blocks {
+		if b.start < pos {
+			// Blocks only overlap around syntax errors. Copy them both.
+			last := &pieces[len(pieces)-1]
+			last.end = max(last.end, b.end)
+			last.text = string(src[last.start:last.end])
+			pos = last.end
+			continue
+		}
+		gap(b.start)
+		add(b)
+	}
+	gap(uint(len(src)))
+	end := ""
+	if len(pieces) > 0 && opts.FinalNewline {
+		end = "\n"
+	}
+	pieces = append(pieces, block{start: pos, end: uint(len(src)), text: end})
+	if len(doc.Errors) > 0 {
+		return skipErrors(src, pieces, doc.Errors)
+	}
+	return pieces, nil
+}
+
+// skipErrors replaces the pieces that errs touch with the source they
+// cover. A run of blocks an error spans becomes one piece, so the whitespace
+// inside it is kept too.
+func skipErrors(src []byte, pieces []block, errs []tree_sitter_sand.ParseError) ([]block, error) {
+	last := len(pieces) - 1
+	touches := func(p block, r tree_sitter_sand.Range) bool {
+		if r.StartByte == r.EndByte {
+			return p.start <= r.StartByte && r.StartByte <= p.end
+		}
+		return r.StartByte < p.end && r.EndByte > p.start
+	}
+	// runs holds the first and last block piece of every skipped run.
+	var runs [][2]int
+	for _, e := range errs {
+		lo, hi := -1, -1
+		for i, p := range pieces {
+			if touches(p, e.Range) {
+				if lo < 0 {
+					lo = i
+				}
+				hi = i
+			}
+		}
+		if lo < 0 {
+			continue
+		}
+		// Snap whitespace to the blocks around it.
+		if lo%2 == 0 {
+			lo = max(lo-1, 1)
+		}
+		if hi%2 == 0 {
+			hi = min(hi+1, last-1)
+		}
+		if lo <= hi {
+			runs = append(runs, [2]int{lo, hi})
+		}
+	}
+	// Errors nest, so merge the runs in the order of the pieces.
+	slices.SortFunc(runs, func(a, b [2]int) int { return a[0] - b[0] })
+	merged := runs[:0]
+	for _, run := range runs {
+		if n := len(merged); n > 0 && run[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], run[1])
+			continue
+		}
+		merged = append(merged, run)
+	}
+	runs = merged
+
+	x := position.NewIndex(src)
+	perr := &PartialError{Errors: errs}
+	var out []block
+	next := 0
+	for _, run := range runs {
+		lo, hi := run[0], run[1]
+		out = append(out, pieces[next:lo]...)
+		out = append(out, block{start: pieces[lo].start, end: pieces[hi].end, text: string(src[pieces[lo].start:pieces[hi].end])})
+		next = hi + 1
+
+		start, end := pieces[lo].start, pieces[hi].end
+		if lo == 1 {
+			// Keep what comes before the first block, too.
+			out[0].text = string(src[out[0].start:out[0].end])
+			start = 0
+		}
+		if hi == last-1 {
+			pieces[last].text = string(src[pieces[last].start:pieces[last].end])
+			end = uint(len(src))
+		}
+		perr.Skipped = append(perr.Skipped, x.Range(int(start), int(end)))
+	}
+	out = append(out, pieces[next:]...)
+	return out, perr
+}
+
+func heading(src []byte, s *tree_sitter_sand.Section, style HeadingStyle) block {
+	b := block{start: s.Heading.StartByte, end: s.Heading.EndByte}
+	title := string(src[s.TitleRange.StartByte:s.TitleRange.EndByte])
+	space := " "
+	if style == HeadingCompact && title != "" && title[0] != '#' {
+		space = ""
+	}
+	b.text = "#" + s.Alias + strings.Repeat("#", s.Level) + space + title
+	if title == "" {
+		// A whitespace-only title is still a title; the space written
+		// above keeps the heading from turning into prose. The empty title
+		// range sits at the end of the whitespace it replaces.
+		b.end = s.TitleRange.EndByte
+	}
+	return b
+}
+
+func paragraph(src []byte, p *tree_sitter_sand.Paragraph) block {
+	var text strings.Builder
+	pos := p.Range.StartByte
+	for _, span := range p.Spans {
+		text.WriteString(trimLines(src[pos:span.Range.StartByte]))
+		switch span.Kind {
+		case tree_sitter_sand.SpanSentence, tree_sitter_sand.SpanApplyAll:
+			text.Write(src[span.Range.StartByte:span.Range.EndByte])
+		default:
+			text.WriteString(trimLines(src[span.Range.StartByte:span.Range.EndByte]))
+		}
+		pos = span.Range.EndByte
+	}
+	text.WriteString(trimLines(src[pos:p.Range.EndByte]))
+	return block{start: p.Range.StartByte, end: p.Range.EndByte, text: text.String()}
+}
+
+// trimLines removes the spaces and tabs before every line break of prose.
+func trimLines(prose []byte) string {
+	lines := strings.Split(string(prose), "\n")
+	for i := range lines[:len(lines)-1] {
+		lines[i] = strings.TrimRight(lines[i], " \t")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// word is a piece of a paragraph that reflow does not break.
+type word struct {
+	text string
+	// sep is the whitespace before the word in the source.
+	sep string
+	// keep says that sep must be written as it is.
+	keep bool
+	// wide says that a line break may be written before the word even
+	// though sep is empty, because it is between wide runes.
+	wide bool
+	// hardBreak says that a line break follows the word.
+	hardBreak bool
+}
+
+// reflow formats p with its words filling lines up to maxWidth.
+func reflow(src []byte, p *tree_sitter_sand.Paragraph, maxWidth int) block {
+	var words []word
+	var sep strings.Builder
+	afterSpan := false
+	prose := func(text string) {
+		for text != "" {
+			i := strings.IndexFunc(text, func(r rune) bool { return !unicode.IsSpace(r) })
+			if i < 0 {
+				sep.WriteString(text)
+				return
+			}
+			sep.WriteString(text[:i])
+			text = text[i:]
+			j := strings.IndexFunc(text, unicode.IsSpace)
+			if j < 0 {
+				j = len(text)
+			}
+			words = appendProse(words, text[:j], sep.String(), afterSpan)
+			sep.Reset()
+			afterSpan = false
+			text = text[j:]
+		}
+	}
+
+	pos := p.Range.StartByte
+	for _, span := range p.Spans {
+		prose(string(src[pos:span.Range.StartByte]))
+		if span.Kind == tree_sitter_sand.SpanText {
+			prose(string(src[span.Range.StartByte:span.Range.EndByte]))
+		} else {
+			words = append(words, word{text: string(src[span.Range.StartByte:span.Range.EndByte]), sep: sep.String()})
+			sep.Reset()
+			afterSpan = true
+		}
+		pos = span.Range.EndByte
+	}
+	prose(string(src[pos:p.Range.EndByte]))
+
+	var text strings.Builder
+	col := 0
+	for i, w := range words {
+		switch {
+		case i == 0:
+		case w.keep:
+			text.WriteString(trimLines([]byte(w.sep)))
+			if j := strings.LastIndexByte(w.sep, '\n'); j >= 0 {
+				col = width.String(w.sep[j+1:])
+			} else {
+				col += width.String(w.sep)
+			}
+		case w.sep == "":
+			if w.wide && col+lineWidth(w.text) > maxWidth {
+				text.WriteByte('\n')
+				col = 0
+			}
+		case words[i-1].hardBreak:
+			text.WriteByte('\n')
+			col = 0
+		case col+1+lineWidth(w.text) > maxWidth:
+			if wideEnd(words[i-1].text) && wideStart(w.text) && col+lineWidth(w.text) <= maxWidth {
+				// A line break here would be read back as no space, and
+				// the word would then fit.
+				break
+			}
+			text.WriteByte('\n')
+			col = 0
+		default:
+			text.WriteByte(' ')
+			col++
+		}
+		text.WriteString(w.text)
+		if j := strings.LastIndexByte(w.text, '\n'); j >= 0 {
+			col = width.String(w.text[j+1:])
+		} else {
+			col += width.String(w.text)
+		}
+	}
+	return block{start: p.Range.StartByte, end: p.Range.EndByte, text: text.String()}
+}
+
+// appendProse appends the words of a run of prose without whitespace. Lines
+// may break between wide runes, so the run is split there, keeping closing
+// punctuation with the rune before it.
+func appendProse(words []word, text, sep string, afterSpan bool) []word {
+	w := word{sep: sep}
+	// The sand parser skips spaces, but not line breaks, inside a construct,
+	// so `#s[a] [b]` is one sentence definition and `#.a .b` one selector.
+	// Whitespace after a construct is kept as it is when the next word could
+	// continue it.
+	w.keep = afterSpan && strings.ContainsAny(text[:1], "[{.")
+	if n := len(words); n > 0 && !afterSpan && strings.Count(sep, "\n") == 1 &&
+		wideEnd(words[n-1].text) && wideStart(text) {
+		// A single line break between wide runes is not a space, as usual
+		// in CJK text; reflow may have written it.
+		w.sep, w.wide = "", true
+	}
+
+	start := 0
+	var prev rune
+	for j, c := range text {
+		if j > 0 && (width.Rune(prev) == 2 || width.Rune(c) == 2) && !width.NoLineStart(c) {
+			w.text = text[start:j]
+			words = append(words, w)
+			w = word{wide: true}
+			start = j
+		}
+		prev = c
+	}
+	w.text = text[start:]
+	if n := len(w.text); n >= 2 && w.text[n-2:] == `\n` {
+		backslashes := n - 1 - len(strings.TrimRight(w.text[:n-1], `\`))
+		w.hardBreak = backslashes%2 == 1
+	}
+	return append(words, w)
+}
+
+func wideStart(text string) bool {
+	c, _ := utf8.DecodeRuneInString(text)
+	return width.Rune(c) == 2
+}
+
+func wideEnd(text string) bool {
+	c, _ := utf8.DecodeLastRuneInString(text)
+	return width.Rune(c) == 2
+}
+
+// lineWidth returns the width of the first line of text.
+func lineWidth(text string) int {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return width.String(text)
+}
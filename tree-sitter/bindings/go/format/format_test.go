@@ -0,0 +1,478 @@
+package format_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/format"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files")
+
+func TestFormatGolden(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/*.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range inputs {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := format.Format(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := strings.TrimSuffix(input, ".input") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// documents returns every well-formed document of the test corpus.
+func documents(t testing.TB) map[string][]byte {
+	t.Helper()
+
+	var paths []string
+	for _, pattern := range []string{"testdata/*.input", "testdata/*.golden", "../testdata/*.sand"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, matches...)
+	}
+	paths = append(paths, "../../../../README.sand")
+
+	docs := map[string][]byte{}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc, err := tree_sitter_sand.Parse(src); err != nil || len(doc.Errors) > 0 {
+			continue
+		}
+		docs[path] = src
+	}
+	return docs
+}
+
+func TestFormatIdempotent(t *testing.T) {
+	for path, src := range documents(t) {
+		once, err := format.Format(src)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		twice, err := format.Format(once)
+		if err != nil {
+			t.Errorf("%s: formatted output does not parse: %v", path, err)
+			continue
+		}
+		if !bytes.Equal(once, twice) {
+			t.Errorf("%s: formatting is not idempotent:\n%s\nthen:\n%s", path, once, twice)
+		}
+	}
+}
+
+// outline describes what the renderer sees of a document: its names,
+// headings and the contents of its sentences and apply-all blocks.
+func outline(t testing.TB, src []byte) []string {
+	t.Helper()
+
+	doc, err := tree_sitter_sand.Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := []string{fmt.Sprint(doc.Names)}
+	var walk func(nodes []tree_sitter_sand.Node)
+	walk = func(nodes []tree_sitter_sand.Node) {
+		for _, node := range nodes {
+			switch node := node.(type) {
+			case *tree_sitter_sand.Section:
+				out = append(out, fmt.Sprintf("section %q %d %q", node.Alias, node.Level, node.Title))
+				walk(node.Children)
+			case *tree_sitter_sand.Paragraph:
+				for _, span := range node.Spans {
+					switch span.Kind {
+					case tree_sitter_sand.SpanSentence, tree_sitter_sand.SpanApplyAll:
+						var contents []string
+						for _, c := range span.Contents {
+							contents = append(contents, c.Text)
+						}
+						out = append(out, fmt.Sprintf("%v %q %v %q", span.Kind, span.Alias, span.Targets, contents))
+					case tree_sitter_sand.SpanSelector:
+						out = append(out, fmt.Sprintf("%v %v %v %v", span.Kind, span.Local, span.Path, span.TrailingDot))
+					}
+				}
+			}
+		}
+	}
+	walk(doc.Children)
+	return out
+}
+
+func TestFormatPreservesDocument(t *testing.T) {
+	for path, src := range documents(t) {
+		formatted, err := format.Format(src)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if got, want := outline(t, formatted), outline(t, src); !slices.Equal(got, want) {
+			t.Errorf("%s: formatting changed the document:\n%q\nwant:\n%q", path, got, want)
+		}
+	}
+}
+
+func TestFormatSyntaxError(t *testing.T) {
+	_, err := format.Format([]byte("#(en)\n\n#s[unterminated\n"))
+	var perr *format.PartialError
+	if !errors.As(err, &perr) {
+		t.Fatalf("error is %T, want a *PartialError", err)
+	}
+	var parseErr tree_sitter_sand.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("%v does not unwrap to a ParseError", err)
+	}
+}
+
+func TestFormatPartial(t *testing.T) {
+	var before, after strings.Builder
+	for i := range 200 {
+		fmt.Fprintf(&before, "##   Before %d  \n#[a]   #[b]\nprose  \n\n\n", i)
+		fmt.Fprintf(&after, "#s%d##After %d\n\n\n#{ {c} }  \n", i, i)
+	}
+	broken := "#{[en], x}"
+	src := before.String() + broken + "\n\n\n" + after.String()
+
+	got, err := format.Format([]byte(src))
+	var perr *format.PartialError
+	if !errors.As(err, &perr) {
+		t.Fatalf("got error %v, want a *PartialError", err)
+	}
+	if len(perr.Skipped) != 1 {
+		t.Fatalf("got %d skipped ranges, want 1", len(perr.Skipped))
+	}
+	if r := perr.Skipped[0]; src[r.StartByte:r.EndByte] != broken {
+		t.Errorf("skipped %q, want %q", src[r.StartByte:r.EndByte], broken)
+	}
+	formatted := func(s string) string {
+		out, err := format.Format([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+	if want := formatted(before.String()) + "\n" + broken + "\n\n" + formatted(after.String()); string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatBroken(t *testing.T) {
+	src := "  #{ broken\n\n"
+	got, err := format.Format([]byte(src))
+	var perr *format.PartialError
+	if !errors.As(err, &perr) {
+		t.Fatalf("got error %v, want a *PartialError", err)
+	}
+	if string(got) != src {
+		t.Errorf("got %q, want the input unchanged", got)
+	}
+	if len(perr.Skipped) != 1 || perr.Skipped[0].StartByte != 0 || perr.Skipped[0].EndByte != uint(len(src)) {
+		t.Errorf("skipped %+v, want the whole document", perr.Skipped)
+	}
+}
+
+func TestFormatUnstableErrors(t *testing.T) {
+	// Separated from the heading after it, the skipped "#！#" would recover
+	// as "#！" and a heading "#\n\n#0# 0".
+	src := "#！##0\n#0"
+	got, err := format.Format([]byte(src))
+	var perr *format.PartialError
+	if !errors.As(err, &perr) {
+		t.Fatalf("got error %v, want a *PartialError", err)
+	}
+	if string(got) != src {
+		t.Errorf("got %q, want the input unchanged", got)
+	}
+	if len(perr.Skipped) != 1 || perr.Skipped[0].StartByte != 0 || perr.Skipped[0].EndByte != uint(len(src)) {
+		t.Errorf("skipped %+v, want the whole document", perr.Skipped)
+	}
+}
+
+func TestFormatInvalidUTF8(t *testing.T) {
+	src := []byte("#( en )\n\n#s[\x93Quoted\x94]\n")
+	_, err := format.Format(src)
+	var ierr *tree_sitter_sand.InvalidUTF8Error
+	if !errors.As(err, &ierr) || ierr.Offset != 12 {
+		t.Fatalf("got error %v, want an *InvalidUTF8Error at byte 12", err)
+	}
+	if _, err := format.Range(src, tree_sitter_sand.Range{}, format.Options{InvalidUTF8: tree_sitter_sand.InvalidUTF8Replace}); !errors.Is(err, tree_sitter_sand.ErrInvalidUTF8) {
+		t.Errorf("Range: got error %v, want ErrInvalidUTF8", err)
+	}
+
+	opts := format.DefaultOptions()
+	opts.InvalidUTF8 = tree_sitter_sand.InvalidUTF8Replace
+	got, err := format.FormatWithOptions(src, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#(en)\n\n#s[\ufffdQuoted\ufffd]\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatContext(t *testing.T) {
+	src := []byte("#( en )\n\n\n##   Title\n")
+	want, err := format.Format(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := format.FormatContext(context.Background(), src, format.DefaultOptions())
+	if err != nil || string(got) != string(want) {
+		t.Errorf("got %q, %v; want %q", got, err, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := format.FormatContext(ctx, src, format.DefaultOptions()); !errors.Is(err, context.Canceled) {
+		t.Errorf("formatting with a done context gave %v", err)
+	}
+}
+
+func FuzzFormat(f *testing.F) {
+	for _, src := range documents(f) {
+		f.Add(src)
+	}
+	sets := maps.Clone(optionSets)
+	sets["default"] = format.DefaultOptions()
+	f.Fuzz(func(t *testing.T, src []byte) {
+		for set, opts := range sets {
+			once, err := format.FormatWithOptions(src, opts)
+			var perr *format.PartialError
+			if errors.As(err, &perr) {
+				for _, r := range perr.Skipped {
+					if !bytes.Contains(once, src[r.StartByte:r.EndByte]) {
+						t.Fatalf("%s: skipped %q is not in the output:\n%q", set, src[r.StartByte:r.EndByte], once)
+					}
+				}
+				// Formatting the output again must skip the same text and
+				// leave the rest as it is.
+				twice, err := format.FormatWithOptions(once, opts)
+				var again *format.PartialError
+				if !errors.As(err, &again) {
+					t.Fatalf("%s: formatted output has no errors left to skip: %v\n%q", set, err, once)
+				}
+				if got, want := skipped(once, again), skipped(src, perr); !slices.Equal(got, want) {
+					t.Fatalf("%s: formatting again skipped:\n%q\nwant:\n%q", set, got, want)
+				}
+				if !bytes.Equal(once, twice) {
+					t.Fatalf("%s: formatting is not idempotent:\n%q\nthen:\n%q", set, once, twice)
+				}
+				continue
+			}
+			if err != nil {
+				return
+			}
+			twice, err := format.FormatWithOptions(once, opts)
+			if err != nil {
+				t.Fatalf("%s: formatted output does not parse: %v\n%q", set, err, once)
+			}
+			if !bytes.Equal(once, twice) {
+				t.Fatalf("%s: formatting is not idempotent:\n%q\nthen:\n%q", set, once, twice)
+			}
+			if got, want := outline(t, once), outline(t, src); !slices.Equal(got, want) {
+				t.Fatalf("%s: formatting changed the document:\n%q\nwant:\n%q", set, got, want)
+			}
+		}
+	})
+}
+
+// skipped returns the text of the ranges of src that err left unformatted.
+func skipped(src []byte, err *format.PartialError) []string {
+	var texts []string
+	for _, r := range err.Skipped {
+		texts = append(texts, string(src[r.StartByte:r.EndByte]))
+	}
+	return texts
+}
+
+// optionSets are the option combinations with golden files.
+var optionSets = map[string]format.Options{
+	"reflow":  {MaxWidth: 40, ReflowProse: true, FinalNewline: true},
+	"compact": {HeadingStyle: format.HeadingCompact},
+	"narrow":  {MaxWidth: 20, ReflowProse: true, HeadingStyle: format.HeadingCompact, FinalNewline: true},
+}
+
+func TestFormatWithOptionsGolden(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/*.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for set, opts := range optionSets {
+		for _, input := range inputs {
+			t.Run(set+"/"+filepath.Base(input), func(t *testing.T) {
+				src, err := os.ReadFile(input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := format.FormatWithOptions(src, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				golden := filepath.Join("testdata", set, strings.TrimSuffix(filepath.Base(input), ".input")+".golden")
+				if *update {
+					if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(golden, got, 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("got:\n%s\nwant:\n%s", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestFormatWithOptionsIdempotent(t *testing.T) {
+	for set, opts := range optionSets {
+		for path, src := range documents(t) {
+			once, err := format.FormatWithOptions(src, opts)
+			if err != nil {
+				t.Errorf("%s %s: %v", set, path, err)
+				continue
+			}
+			twice, err := format.FormatWithOptions(once, opts)
+			if err != nil {
+				t.Errorf("%s %s: formatted output does not parse: %v", set, path, err)
+				continue
+			}
+			if !bytes.Equal(once, twice) {
+				t.Errorf("%s %s: formatting is not idempotent:\n%s\nthen:\n%s", set, path, once, twice)
+			}
+			if got, want := outline(t, once), outline(t, src); !slices.Equal(got, want) {
+				t.Errorf("%s %s: formatting changed the document:\n%q\nwant:\n%q", set, path, got, want)
+			}
+		}
+	}
+}
+
+func TestReflowWidth(t *testing.T) {
+	src := []byte("#(en)\n\naaaa bbbb cccc 日本語 dd\n")
+	got, err := format.FormatWithOptions(src, format.Options{MaxWidth: 14, ReflowProse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 日本語 takes six columns, so it does not fit after "cccc".
+	if want := "#(en)\n\naaaa bbbb cccc\n日本語 dd"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// counter is an Observer that records the stages it is told about, and
+// whether they failed.
+type counter struct{ events []string }
+
+func (c *counter) OnStageStart(stage string, _ int) {
+	c.events = append(c.events, "start "+stage)
+}
+
+func (c *counter) OnStageEnd(stage string, _ time.Duration, meta map[string]any) {
+	event := "end " + stage
+	if _, ok := meta[tree_sitter_sand.MetaError]; ok {
+		event += " failed"
+	}
+	if n, ok := meta[tree_sitter_sand.MetaOutputBytes]; ok {
+		event += fmt.Sprintf(" %d", n)
+	}
+	c.events = append(c.events, event)
+}
+
+func TestFormatObserver(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		src  string
+		want string
+	}{
+		{"clean", context.Background(), "#( en )\n", "start format, start parse, end parse, end format 6"},
+		{"syntax error", context.Background(), "#(en)\n\n#s[a\n", "start format, start parse, end parse, end format failed 12"},
+		{"invalid UTF-8", context.Background(), "#(en)\n\xff", "start format, end format failed"},
+		{"canceled", canceled, "#(en)\n", "start format, start parse, end parse failed, end format failed"},
+	} {
+		c := &counter{}
+		opts := format.DefaultOptions()
+		opts.Observer = c
+		format.FormatContext(tt.ctx, []byte(tt.src), opts)
+		if got := strings.Join(c.events, ", "); got != tt.want {
+			t.Errorf("%s: got events %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	c := &counter{}
+	opts := format.DefaultOptions()
+	opts.Observer = c
+	if _, err := format.Range([]byte("#( en )\n"), tree_sitter_sand.Range{}, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(c.events, ", "), "start format, start parse, end parse, end format"; got != want {
+		t.Errorf("Range: got events %s, want %s", got, want)
+	}
+}
+
+// TestReflowWhitespaceParagraph checks that a paragraph of whitespace the
+// parser takes for prose, such as a form feed, formats to nothing rather
+// than to an empty block that then ends the file with a newline.
+func TestReflowWhitespaceParagraph(t *testing.T) {
+	opts := format.Options{ReflowProse: true, FinalNewline: true}
+	for _, tt := range []struct{ src, want string }{
+		{"\f", ""},
+		{"\v", ""},
+		{"\f\n\f", ""},
+		{"#(en)\n\n\f\n\n#s[a]\n", "#(en)\n\n#s[a]\n"},
+	} {
+		once, err := format.FormatWithOptions([]byte(tt.src), opts)
+		if err != nil || string(once) != tt.want {
+			t.Errorf("%q: got %q, %v; want %q", tt.src, once, err, tt.want)
+			continue
+		}
+		if twice, err := format.FormatWithOptions(once, opts); err != nil || !bytes.Equal(once, twice) {
+			t.Errorf("%q: formatting is not idempotent: %q then %q, %v", tt.src, once, twice, err)
+		}
+	}
+}
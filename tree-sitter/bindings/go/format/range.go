@@ -0,0 +1,98 @@
+package format
+
+import (
+	"context"
+	"unicode/utf8"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/internal/observe"
+	"github.com/satler-git/sand-markup/bindings/go/position"
+)
+
+// Range formats the part of src that r overlaps, as for an editor's "format
+// selection". Only the byte offsets of r are used.
+//
+// The range is widened to whole blocks: a range starting inside a paragraph
+// formats the entire paragraph and the whitespace after it, and one covering
+// only the blank lines between blocks fixes just those. An empty range
+// formats what is around its offset. Applying the edits gives the same text for those
+// blocks as [FormatWithOptions] does, and leaves the rest of src alone.
+//
+// The edits are as small as they can be: each block or whitespace run that
+// changes gives one edit, trimmed to the bytes that differ. Like
+// [FormatWithOptions], Range leaves blocks with syntax errors alone and
+// returns the edits for the rest with a [*PartialError].
+func Range(src []byte, r tree_sitter_sand.Range, opts Options) (edits []tree_sitter_sand.TextEdit, err error) {
+	stage := observe.Start(opts.Observer, tree_sitter_sand.StageFormat, len(src))
+	defer func() { stage.End(err, nil) }()
+
+	pieces, err := layout(context.Background(), src, opts)
+	if pieces == nil {
+		return nil, err
+	}
+	start := min(r.StartByte, uint(len(src)))
+	end := min(max(r.EndByte, start), uint(len(src)))
+
+	var x *position.Index
+	// Pieces alternate between whitespace and blocks, starting and ending
+	// with whitespace. A block is formatted with the whitespace after it.
+	for i, p := range pieces {
+		if !overlaps(p, start, end) && (i%2 == 1 || i == 0 || !overlaps(pieces[i-1], start, end)) {
+			continue
+		}
+
+		old := string(src[p.start:p.end])
+		if old == p.text {
+			continue
+		}
+		prefix, suffix := common(old, p.text)
+		if x == nil {
+			x = position.NewIndex(src)
+		}
+		edits = append(edits, tree_sitter_sand.TextEdit{
+			Range:   x.Range(int(p.start)+prefix, int(p.end)-suffix),
+			NewText: p.text[prefix : len(p.text)-suffix],
+		})
+	}
+	return edits, err
+}
+
+// overlaps reports whether the range from start to end selects p.
+func overlaps(p block, start, end uint) bool {
+	switch {
+	case start == end:
+		// A cursor selects what it touches.
+		return p.start <= start && start <= p.end
+	case p.start == p.end:
+		return start < p.start && p.start < end
+	default:
+		return p.start < end && start < p.end
+	}
+}
+
+// common returns the lengths of the longest common prefix and suffix of a and
+// b that do not overlap and do not split a rune or a "\r\n".
+func common(a, b string) (prefix, suffix int) {
+	n := min(len(a), len(b))
+	for prefix < n && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for prefix > 0 && (!boundary(a, prefix) || !boundary(b, prefix)) {
+		prefix--
+	}
+	for suffix < n-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && (!boundary(a, len(a)-suffix) || !boundary(b, len(b)-suffix)) {
+		suffix--
+	}
+	return prefix, suffix
+}
+
+// boundary reports whether s can be cut at i.
+func boundary(s string, i int) bool {
+	if i <= 0 || i >= len(s) {
+		return true
+	}
+	return utf8.RuneStart(s[i]) && (s[i-1] != '\r' || s[i] != '\n')
+}
@@ -0,0 +1,134 @@
+package format_test
+
+import (
+	"errors"
+	"maps"
+	"math/rand"
+	"slices"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/format"
+)
+
+func byteRange(start, end int) tree_sitter_sand.Range {
+	return tree_sitter_sand.Range{StartByte: uint(start), EndByte: uint(end)}
+}
+
+func TestRangeMatchesFormat(t *testing.T) {
+	sets := maps.Clone(optionSets)
+	sets["default"] = format.DefaultOptions()
+	r := rand.New(rand.NewSource(1))
+
+	for set, opts := range sets {
+		for path, src := range documents(t) {
+			want, err := format.FormatWithOptions(src, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			edits, err := format.Range(src, byteRange(0, len(src)), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tree_sitter_sand.ApplyEdits(src, edits)
+			if err != nil {
+				t.Fatalf("%s %s: %v", set, path, err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("%s %s: formatting the whole range gives\n%q\nwant\n%q", set, path, got, want)
+			}
+
+			// Formatting consecutive ranges of the original adds up to
+			// formatting the whole document.
+			for range 5 {
+				cuts := []int{0, len(src)}
+				for range r.Intn(6) {
+					cuts = append(cuts, r.Intn(len(src)+1))
+				}
+				slices.Sort(cuts)
+				var all []tree_sitter_sand.TextEdit
+				for i := range cuts[:len(cuts)-1] {
+					edits, err := format.Range(src, byteRange(cuts[i], cuts[i+1]), opts)
+					if err != nil {
+						t.Fatal(err)
+					}
+					for _, e := range edits {
+						if !slices.Contains(all, e) {
+							all = append(all, e)
+						}
+					}
+				}
+				got, err := tree_sitter_sand.ApplyEdits(src, all)
+				if err != nil {
+					t.Fatalf("%s %s %v: %v", set, path, cuts, err)
+				}
+				if string(got) != string(want) {
+					t.Errorf("%s %s: formatting ranges %v gives\n%q\nwant\n%q", set, path, cuts, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	src := "#( en )\n\n\n\nfirst   \nparagraph  \n\n\nsecond   \nparagraph  \n##   Title"
+	for _, test := range []struct {
+		name       string
+		start, end int
+		want       string
+	}{
+		{"name definition", 1, 2, "#(en)\n\nfirst   \nparagraph  \n\n\nsecond   \nparagraph  \n##   Title"},
+		{"blank lines", 8, 9, "#( en )\n\nfirst   \nparagraph  \n\n\nsecond   \nparagraph  \n##   Title"},
+		{"mid-paragraph", 14, 14, "#( en )\n\n\n\nfirst\nparagraph\n\nsecond   \nparagraph  \n##   Title"},
+		{"two paragraphs", 20, 40, "#( en )\n\n\n\nfirst\nparagraph\n\nsecond\nparagraph\n\n##   Title"},
+		{"to the end", 60, 62, "#( en )\n\n\n\nfirst   \nparagraph  \n\n\nsecond   \nparagraph  \n## Title\n"},
+	} {
+		edits, err := format.Range([]byte(src), byteRange(test.start, test.end), format.DefaultOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := tree_sitter_sand.ApplyEdits([]byte(src), edits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got\n%q\nwant\n%q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRangeMinimalEdits(t *testing.T) {
+	src := []byte("#(en)\n\n#s[日本]   \n#t[語] 　\n")
+	edits, err := format.Range(src, byteRange(0, len(src)), format.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range edits {
+		got = append(got, string(src[e.Range.StartByte:e.Range.EndByte])+" -> "+e.NewText)
+	}
+	// The ideographic space after #t[語] is not trimmed from prose lines, so
+	// only the spaces before the first line break change.
+	if want := []string{"    -> "}; !slices.Equal(got, want) {
+		t.Errorf("edits = %q, want %q", got, want)
+	}
+	for _, e := range edits {
+		if p := e.Range.StartPoint; p.Row != 2 || p.Column != uint(len("#s[日本]")) {
+			t.Errorf("edit starts at %+v", p)
+		}
+	}
+}
+
+func TestRangeSyntaxError(t *testing.T) {
+	src := []byte("#( en )\n\n#s[unterminated  \n")
+	edits, err := format.Range(src, byteRange(0, len(src)), format.DefaultOptions())
+	var perr *format.PartialError
+	if !errors.As(err, &perr) {
+		t.Fatalf("got error %v, want a *PartialError", err)
+	}
+	// Only the name definition is formatted.
+	if len(edits) != 1 || edits[0].NewText != "en" || edits[0].Range.EndByte > perr.Skipped[0].StartByte {
+		t.Errorf("got edits %+v", edits)
+	}
+}
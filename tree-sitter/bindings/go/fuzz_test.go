@@ -0,0 +1,147 @@
+package tree_sitter_sand_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+func FuzzParse(f *testing.F) {
+	for _, src := range corpus(f) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if _, err := tree_sitter_sand.Parse(src); errors.Is(err, tree_sitter_sand.ErrInvalidUTF8) == utf8.Valid(src) {
+			t.Fatalf("Parse of %d-byte source: %v", len(src), err)
+		}
+		src, _, err := tree_sitter_sand.CheckUTF8(src, tree_sitter_sand.InvalidUTF8Replace)
+		if err != nil {
+			// A UTF-16 byte order mark.
+			return
+		}
+		doc, err := tree_sitter_sand.Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var check func(nodes []tree_sitter_sand.Node)
+		check = func(nodes []tree_sitter_sand.Node) {
+			for _, node := range nodes {
+				if r := node.Span(); r.StartByte > r.EndByte || r.EndByte > uint(len(src)) {
+					t.Fatalf("%T range %d-%d outside the %d-byte source", node, r.StartByte, r.EndByte, len(src))
+				}
+				switch node := node.(type) {
+				case *tree_sitter_sand.Section:
+					check(node.Children)
+				case *tree_sitter_sand.Paragraph:
+					for _, span := range node.Spans {
+						check([]tree_sitter_sand.Node{span})
+					}
+				}
+			}
+		}
+		check(doc.Children)
+
+		// The tools built on the document must not fail on it either.
+		for _, d := range tree_sitter_sand.Diagnose(src) {
+			if d.Range.EndByte > uint(len(src)) {
+				t.Fatalf("diagnostic %v outside the source", d)
+			}
+		}
+		tree_sitter_sand.ValidateRefs(src)
+		tree_sitter_sand.Outline(src)
+		text, m := tree_sitter_sand.PlainTextWithMap(src, tree_sitter_sand.TextOptions{Prose: true})
+		for i := range len(text) + 1 {
+			if off := m.Source(i); off < 0 || off > len(src) {
+				t.Fatalf("output offset %d maps to %d", i, off)
+			}
+		}
+	})
+}
+
+func FuzzBuilder(f *testing.F) {
+	f.Add("Title", "text", "content", "second")
+	f.Add(`#a## x \ ]`, "#[x] \\n\n\n", `x] \ }`, "y\n\nz")
+	f.Fuzz(func(t *testing.T, title, text, first, second string) {
+		b := tree_sitter_sand.NewBuilder().Names("en", "ja")
+		b.BeginSection("", title).Paragraph(text).Sentence("", first, second).EndSection()
+		if _, err := b.Bytes(); err != nil {
+			// Refused input, such as an empty title.
+			return
+		}
+		_, doc := build(t, b)
+		section := doc.Children[0].(*tree_sitter_sand.Section)
+		if want := strings.Trim(title, " \t\n\r"); section.Title != want {
+			t.Errorf("title: got %q, want %q", section.Title, want)
+		}
+		last := section.Children[len(section.Children)-1].(*tree_sitter_sand.Paragraph)
+		contents := last.Spans[0].Contents
+		if len(contents) != 2 || contents[0].Text != first || contents[1].Text != second {
+			t.Errorf("contents: got %+v, want %q, %q", contents, first, second)
+		}
+	})
+}
+
+func TestParseWithTimeout(t *testing.T) {
+	src := generate(1 << 20)
+	start := time.Now()
+	doc, err := tree_sitter_sand.ParseWithTimeout(src, time.Millisecond)
+	if !errors.Is(err, tree_sitter_sand.ErrTimeout) {
+		t.Fatalf("got %v, %v; want ErrTimeout", doc, err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("timed out after %v", elapsed)
+	}
+
+	for _, d := range []time.Duration{0, time.Minute} {
+		doc, err := tree_sitter_sand.ParseWithTimeout([]byte(textDoc), d)
+		if err != nil {
+			t.Fatalf("%v: %v", d, err)
+		}
+		if len(doc.Names) != 2 {
+			t.Errorf("%v: names = %q", d, doc.Names)
+		}
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	src := generate(8 << 20)
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var canceled atomic.Int64
+	timer := time.AfterFunc(5*time.Millisecond, func() {
+		canceled.Store(time.Now().UnixNano())
+		cancel()
+	})
+	defer timer.Stop()
+	doc, err := tree_sitter_sand.ParseContext(ctx, src)
+	returned := time.Now()
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, %v; want context.Canceled", doc, err)
+	}
+	if latency := returned.Sub(time.Unix(0, canceled.Load())); latency > 50*time.Millisecond {
+		t.Errorf("returned %v after cancellation", latency)
+	}
+
+	// Nothing is left running once the timer's goroutine is done.
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines after parsing, %d before", n, before)
+	}
+
+	if _, err := tree_sitter_sand.ParseContext(ctx, []byte(textDoc)); !errors.Is(err, context.Canceled) {
+		t.Errorf("parsing with a done context gave %v", err)
+	}
+	if doc, err := tree_sitter_sand.ParseContext(context.Background(), []byte(textDoc)); err != nil || len(doc.Names) != 2 {
+		t.Errorf("got %v, %v", doc, err)
+	}
+}
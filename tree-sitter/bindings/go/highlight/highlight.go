@@ -0,0 +1,263 @@
+// Package highlight styles the source of Sand documents line by line, for
+// terminal pagers and diff views.
+//
+// [Lines] parses a document once, runs the highlight query of the grammar
+// once and splits the captures at line breaks. A [State] keeps the tree
+// between edits so that [UpdateLines] only recomputes the lines an edit
+// touched.
+package highlight
+
+import (
+	"bytes"
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Theme maps highlight captures, such as "string" or "punctuation.special",
+// to styles. A capture that is not in the map falls back to its parent, so
+// "punctuation.special" uses "punctuation" if only that is present. Captures
+// with no entry, or an empty style, produce no span.
+//
+// Styles are opaque to the package; [DefaultTheme] uses SGR parameters as
+// the term renderer does.
+type Theme map[string]string
+
+// DefaultTheme returns SGR styles for a dark or light terminal.
+func DefaultTheme() Theme {
+	return Theme{
+		"punctuation.special": "35",
+		"punctuation":         "2",
+		"variable":            "36",
+		"string":              "32",
+	}
+}
+
+// style returns the style of capture, and false if it has none.
+func (t Theme) style(capture string) (string, bool) {
+	for name := capture; ; {
+		if s, ok := t[name]; ok {
+			return s, s != ""
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			return "", false
+		}
+		name = name[:i]
+	}
+}
+
+// StyledLine holds the styled spans of one line.
+type StyledLine struct {
+	// Spans are sorted and do not overlap. Text without a style is not
+	// covered, and neither is the line break.
+	Spans []Span
+}
+
+// Span is styled text from byte Start to End of its line.
+type Span struct {
+	Start, End uint
+	Style      string
+}
+
+var highlights = sync.OnceValue(func() *tree_sitter.Query {
+	language := tree_sitter.NewLanguage(tree_sitter_sand.Language())
+	q, err := tree_sitter.NewQuery(language, tree_sitter_sand.HighlightsQuery())
+	if err != nil {
+		panic("highlight: invalid highlight query: " + err.Error())
+	}
+	return q
+})
+
+// Lines returns the styled lines of src, one more than it has line breaks.
+// Where captures overlap, the innermost wins, as in editors applying the
+// highlight query. Syntax errors do not make Lines fail; an error is only
+// returned when the parser cannot be used.
+func Lines(src []byte, theme Theme) ([]StyledLine, error) {
+	tree, err := tree_sitter_sand.ParseTree(src)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	starts := lineStarts(src)
+	return styleLines(tree, src, theme, starts, 0, len(starts)), nil
+}
+
+// State is the highlighting of one document, kept up to date by
+// [UpdateLines]. It must not be used by several goroutines at once.
+type State struct {
+	session *tree_sitter_sand.Session
+	theme   Theme
+	lines   []StyledLine
+}
+
+// NewState highlights src. The caller must call [State.Close] when it is
+// done with the state.
+func NewState(src []byte, theme Theme) (*State, error) {
+	session, err := tree_sitter_sand.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	tree := session.Parse(src)
+	if tree == nil {
+		session.Close()
+		return nil, tree_sitter_sand.ErrNoTree
+	}
+	starts := lineStarts(src)
+	s := &State{session: session, theme: theme}
+	s.lines = styleLines(tree, src, theme, starts, 0, len(starts))
+	return s, nil
+}
+
+// Lines returns the styled lines of the current source. They belong to the
+// state and are replaced by the next [UpdateLines].
+func (s *State) Lines() []StyledLine { return s.lines }
+
+// Close releases the parser and tree of the state.
+func (s *State) Close() { s.session.Close() }
+
+// UpdateLines records edit, which turned the source of prev into newSrc, and
+// reparses newSrc incrementally. Only the lines the edit touched and those
+// whose syntax changed are highlighted again; the others are kept, moved
+// down or up by the number of lines the edit added or removed.
+//
+// The lines from start up to end of [State.Lines] are the dirty ones the
+// caller must redraw. Lines after them are unchanged save for their position.
+func UpdateLines(prev *State, edit tree_sitter.InputEdit, newSrc []byte) (start, end int, err error) {
+	tree := prev.session.ApplyEdit(edit, newSrc)
+	if tree == nil {
+		return 0, 0, tree_sitter_sand.ErrNoTree
+	}
+	starts := lineStarts(newSrc)
+
+	first, last := int(edit.StartPosition.Row), int(edit.NewEndPosition.Row)
+	for _, r := range prev.session.ChangedRanges() {
+		first = min(first, int(r.StartPoint.Row))
+		last = max(last, int(r.EndPoint.Row))
+	}
+	first = min(first, len(starts)-1)
+	last = min(last, len(starts)-1)
+
+	// Lines after the dirty ones follow the edit, so they moved by as
+	// many lines as it added.
+	shift := int(edit.NewEndPosition.Row) - int(edit.OldEndPosition.Row)
+	lines := make([]StyledLine, 0, len(starts))
+	lines = append(lines, prev.lines[:first]...)
+	lines = append(lines, styleLines(tree, newSrc, prev.theme, starts, first, last+1)...)
+	lines = append(lines, prev.lines[last+1-shift:]...)
+
+	prev.lines = lines
+	return first, last + 1, nil
+}
+
+// lineStarts returns the offsets the lines of src start at.
+func lineStarts(src []byte) []uint {
+	starts := []uint{0}
+	for i, c := range src {
+		if c == '\n' {
+			starts = append(starts, uint(i)+1)
+		}
+	}
+	return starts
+}
+
+// styleLines returns the styled lines from first up to end, running the
+// highlight query over their bytes only.
+func styleLines(tree *tree_sitter.Tree, src []byte, theme Theme, starts []uint, first, end int) []StyledLine {
+	lo, hi := starts[first], uint(len(src))
+	if end < len(starts) {
+		hi = starts[end]
+	}
+
+	type capture struct {
+		start, end uint
+		style      string
+	}
+	var captures []capture
+	q := highlights()
+	names := q.CaptureNames()
+	cursor := tree_sitter.NewQueryCursor()
+	defer cursor.Close()
+	cursor.SetByteRange(lo, hi)
+	matches := cursor.Matches(q, tree.RootNode(), src)
+	for m := matches.Next(); m != nil; m = matches.Next() {
+		for _, c := range m.Captures {
+			if style, ok := theme.style(names[c.Index]); ok && c.Node.EndByte() > c.Node.StartByte() {
+				captures = append(captures, capture{c.Node.StartByte(), c.Node.EndByte(), style})
+			}
+		}
+	}
+	// Captures are nodes, so they nest. Outer ones sort first and a later
+	// capture of the same node overrides an earlier one.
+	slices.SortStableFunc(captures, func(a, b capture) int {
+		return cmp.Or(cmp.Compare(a.start, b.start), cmp.Compare(b.end, a.end))
+	})
+
+	l := &splitter{src: src, starts: starts, first: first, lo: lo, hi: hi, line: first, out: make([]StyledLine, end-first)}
+	var stack []capture
+	var pos uint
+	// flush emits the innermost open captures up to offset upto.
+	flush := func(upto uint) {
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if end := min(top.end, upto); end > pos {
+				l.emit(pos, end, top.style)
+				pos = end
+			}
+			if top.end > upto {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+		pos = max(pos, upto)
+	}
+	for _, c := range captures {
+		flush(c.start)
+		stack = append(stack, c)
+	}
+	flush(uint(len(src)))
+	return l.out
+}
+
+// splitter splits styled text into the spans of the lines from first, which
+// cover the bytes from lo to hi. Text must be emitted in document order.
+type splitter struct {
+	src    []byte
+	starts []uint
+	first  int
+	lo, hi uint
+	line   int // the line of the last text emitted
+	out    []StyledLine
+}
+
+func (l *splitter) emit(from, to uint, style string) {
+	from, to = max(from, l.lo), min(to, l.hi)
+	for from < to {
+		for l.line+1 < len(l.starts) && l.starts[l.line+1] <= from {
+			l.line++
+		}
+		// Split at line breaks, leaving them out of the spans.
+		end := to
+		if i := bytes.IndexByte(l.src[from:to], '\n'); i >= 0 {
+			end = from + uint(i)
+		}
+		text := bytes.TrimSuffix(l.src[from:end], []byte("\r"))
+		if len(text) > 0 {
+			start := l.starts[l.line]
+			s := Span{Start: from - start, End: from - start + uint(len(text)), Style: style}
+			line := &l.out[l.line-l.first]
+			// Join the pieces of a capture that was split around a child.
+			if n := len(line.Spans); n > 0 && line.Spans[n-1].End == s.Start && line.Spans[n-1].Style == style {
+				line.Spans[n-1].End = s.End
+			} else {
+				line.Spans = append(line.Spans, s)
+			}
+		}
+		from = end + 1
+	}
+}
@@ -0,0 +1,191 @@
+package highlight_test
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/highlight"
+)
+
+func corpus(t *testing.T) map[string][]byte {
+	t.Helper()
+
+	paths, err := filepath.Glob("../testdata/*.sand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths = append(paths, "../../../../README.sand")
+
+	files := map[string][]byte{}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[filepath.Base(path)] = src
+	}
+	return files
+}
+
+var theme = highlight.Theme{"punctuation.special": "k", "punctuation": "p", "variable": "v", "string": "s"}
+
+// show turns lines into "row:start style text" using the lines of src.
+func show(src []byte, lines []highlight.StyledLine) []string {
+	rows := bytes.Split(src, []byte("\n"))
+	var out []string
+	for i, l := range lines {
+		for _, s := range l.Spans {
+			out = append(out, fmt.Sprintf("%d:%d %s %s", i, s.Start, s.Style, rows[i][s.Start:s.End]))
+		}
+	}
+	return out
+}
+
+func TestLines(t *testing.T) {
+	src := []byte("#(en, ja)\n\n#s[one\r\n two\n three][x]\n#a## Title\n")
+	lines, err := highlight.Lines(src, theme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 7 {
+		t.Fatalf("got %d lines, want 7", len(lines))
+	}
+	got := show(src, lines)
+	want := []string{
+		"0:0 k #(",
+		"0:2 v en, ja",
+		"0:8 p )",
+		"2:0 k #",
+		"2:1 v s",
+		"2:2 p [",
+		// The content is split at both line breaks, leaving them out.
+		"2:3 s one",
+		"3:0 s  two",
+		"4:0 s  three",
+		"4:6 p ]",
+		"5:0 k #",
+		"5:1 v a",
+		"5:2 k ##",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestLinesTheme(t *testing.T) {
+	src := []byte("#(en)\n\n#s[x]\n")
+	lines, err := highlight.Lines(src, highlight.Theme{"punctuation": "p", "string": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Special punctuation falls back to punctuation and strings have no
+	// style.
+	want := []string{"0:0 p #(", "0:4 p )", "2:0 p #", "2:2 p [", "2:4 p ]"}
+	if got := show(src, lines); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLinesCorpus(t *testing.T) {
+	for name, src := range corpus(t) {
+		lines, err := highlight.Lines(src, highlight.DefaultTheme())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows := bytes.Split(src, []byte("\n"))
+		if len(lines) != len(rows) {
+			t.Fatalf("%s: got %d lines, want %d", name, len(lines), len(rows))
+		}
+		for i, l := range lines {
+			var end uint
+			for _, s := range l.Spans {
+				if s.Start < end || s.End <= s.Start || s.End > uint(len(rows[i])) {
+					t.Errorf("%s:%d: span %d-%d out of order or out of the line", name, i+1, s.Start, s.End)
+				}
+				end = s.End
+			}
+		}
+	}
+}
+
+func TestUpdateLinesShift(t *testing.T) {
+	src := []byte("#(en)\n\nProse.\n\n#s[one\ntwo\nthree]\n#a## Title\n")
+	state, err := highlight.NewState(src, theme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	before := state.Lines()
+
+	// Two new lines in the prose shift everything below it.
+	newSrc, edits, err := tree_sitter_sand.ApplyEditsInput(src, []tree_sitter_sand.TextEdit{
+		{Range: tree_sitter_sand.Range{StartByte: 13, EndByte: 13}, NewText: " More\nprose.\n"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end, err := highlight.UpdateLines(state, edits[0], newSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != 2 || end != 5 {
+		t.Errorf("dirty lines %d-%d, want 2-5", start, end)
+	}
+	lines := state.Lines()
+	if len(lines) != len(before)+2 {
+		t.Fatalf("got %d lines, want %d", len(lines), len(before)+2)
+	}
+	if !reflect.DeepEqual(lines[:2], before[:2]) || !reflect.DeepEqual(lines[5:], before[3:]) {
+		t.Errorf("lines outside the edit changed:\n%q\nwas\n%q", show(newSrc, lines), show(src, before))
+	}
+	want, err := highlight.Lines(newSrc, theme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("got\n%q\nwant\n%q", show(newSrc, lines), show(newSrc, want))
+	}
+}
+
+func TestUpdateLinesRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	pieces := []string{"\n", "\n\n", "#", "[", "]", "x", "#s[a\nb]", "## T\n", "{", "}", "#.s.a.en", " "}
+	for name, src := range corpus(t) {
+		state, err := highlight.NewState(src, theme)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range 30 {
+			start := uint(r.IntN(len(src) + 1))
+			end := min(start+uint(r.IntN(8)), uint(len(src)))
+			text := ""
+			if r.IntN(3) > 0 {
+				text = pieces[r.IntN(len(pieces))]
+			}
+			newSrc, edits, err := tree_sitter_sand.ApplyEditsInput(src, []tree_sitter_sand.TextEdit{
+				{Range: tree_sitter_sand.Range{StartByte: start, EndByte: end}, NewText: text},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := highlight.UpdateLines(state, edits[0], newSrc); err != nil {
+				t.Fatal(err)
+			}
+			want, err := highlight.Lines(newSrc, theme)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := state.Lines(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("%s: replacing %q with %q:\ngot\n%q\nwant\n%q", name, src[start:end], text, show(newSrc, got), show(newSrc, want))
+			}
+			src = newSrc
+		}
+		state.Close()
+	}
+}
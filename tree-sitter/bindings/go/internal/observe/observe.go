@@ -0,0 +1,52 @@
+// Package observe reports the stages of the binding's pipeline to an
+// observer without costing anything when there is none.
+package observe
+
+import "time"
+
+// Observer has the methods of tree_sitter_sand.Observer, which this package
+// cannot import.
+type Observer interface {
+	OnStageStart(stage string, docBytes int)
+	OnStageEnd(stage string, d time.Duration, meta map[string]any)
+}
+
+// ErrorKey is the metadata key of the error a stage ended with.
+const ErrorKey = "error"
+
+// Stage is a stage that has been reported as started. The zero Stage, which
+// Start returns for a nil observer, reports nothing.
+type Stage struct {
+	observer Observer
+	name     string
+	start    time.Time
+}
+
+// Start reports to o that the stage called name started on a document of
+// docBytes bytes.
+func Start(o Observer, name string, docBytes int) Stage {
+	if o == nil {
+		return Stage{}
+	}
+	o.OnStageStart(name, docBytes)
+	return Stage{observer: o, name: name, start: time.Now()}
+}
+
+// End reports that the stage ended, with err under ErrorKey if it is not
+// nil. If meta is not nil, it is called to fill in the rest of the
+// metadata, and only if there is an observer to report to. End must be
+// called exactly once for every Start, usually deferred.
+func (s Stage) End(err error, meta func(m map[string]any)) {
+	if s.observer == nil {
+		return
+	}
+	d := time.Since(s.start)
+	m := map[string]any{}
+	if meta != nil {
+		meta(m)
+	}
+	if err != nil {
+		m[ErrorKey] = err
+	}
+	s.observer.OnStageEnd(s.name, d, m)
+}
@@ -0,0 +1,50 @@
+package observe
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type counter struct{ starts, ends int }
+
+func (c *counter) OnStageStart(string, int)                         { c.starts++ }
+func (c *counter) OnStageEnd(string, time.Duration, map[string]any) { c.ends++ }
+
+var (
+	output  = []byte("out")
+	errFail = errors.New("failed")
+)
+
+// stage is a stage as the callers write it, with the end deferred and the
+// metadata read from the named results.
+func stage(o Observer, fail bool) (out []byte, err error) {
+	s := Start(o, "stage", 3)
+	defer func() {
+		s.End(err, func(m map[string]any) {
+			m["output_bytes"] = len(out)
+		})
+	}()
+	if fail {
+		return nil, errFail
+	}
+	return output, nil
+}
+
+func TestNoObserverAllocs(t *testing.T) {
+	for _, fail := range []bool{false, true} {
+		if n := testing.AllocsPerRun(100, func() { stage(nil, fail) }); n != 0 {
+			t.Errorf("fail %v: %v allocations without an observer", fail, n)
+		}
+	}
+}
+
+func TestEndOnce(t *testing.T) {
+	for _, fail := range []bool{false, true} {
+		c := &counter{}
+		stage(c, fail)
+		if c.starts != 1 || c.ends != 1 {
+			t.Errorf("fail %v: %d starts and %d ends", fail, c.starts, c.ends)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Package width measures text in terminal columns.
+package width
+
+import (
+	"strings"
+	"unicode"
+)
+
+// String returns the number of columns s takes.
+func String(s string) int {
+	n := 0
+	for _, c := range s {
+		n += Rune(c)
+	}
+	return n
+}
+
+// Rune returns the number of terminal columns c takes: 0 for combining
+// marks, 2 for East Asian wide and fullwidth runes and emoji, and 1 for the
+// rest.
+func Rune(c rune) int {
+	switch {
+	case unicode.In(c, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case c >= 0x1100 && c <= 0x115F, // Hangul Jamo
+		c >= 0x2E80 && c <= 0x303E, // CJK radicals, symbols and punctuation
+		c >= 0x3041 && c <= 0x33FF, // kana, CJK compatibility
+		c >= 0x3400 && c <= 0x4DBF, // CJK extension A
+		c >= 0x4E00 && c <= 0x9FFF, // CJK unified ideographs
+		c >= 0xA000 && c <= 0xA4CF, // Yi
+		c >= 0xAC00 && c <= 0xD7A3, // Hangul syllables
+		c >= 0xF900 && c <= 0xFAFF, // CJK compatibility ideographs
+		c >= 0xFE30 && c <= 0xFE4F, // CJK compatibility forms
+		c >= 0xFF00 && c <= 0xFF60, // fullwidth forms
+		c >= 0xFFE0 && c <= 0xFFE6,
+		c >= 0x1F300 && c <= 0x1F64F, // emoji
+		c >= 0x1F900 && c <= 0x1F9FF,
+		c >= 0x20000 && c <= 0x3FFFD: // CJK extensions B and later
+		return 2
+	}
+	return 1
+}
+
+// noLineStart holds the wide punctuation that must not start a line.
+const noLineStart = "、。，．！？）」』】〉》〕］｝ー…・：；"
+
+// NoLineStart reports whether c is closing punctuation that must stay on the
+// line before it.
+func NoLineStart(c rune) bool {
+	return strings.ContainsRune(noLineStart, c)
+}
@@ -0,0 +1,186 @@
+package tree_sitter_sand
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"unicode/utf8"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// JSONOptions controls [MarshalTree].
+type JSONOptions struct {
+	// IncludeText adds the source text of every node. Bytes that are not
+	// valid UTF-8 are written as U+FFFD, as encoding/json does.
+	IncludeText bool
+	// Anonymous includes anonymous nodes, such as the "#" and "[" tokens.
+	// Named nodes are always included.
+	Anonymous bool
+}
+
+// MarshalTree serializes tree, parsed from src, as JSON. Every node is an
+// object with these keys, in this order, omitting those that do not apply:
+//
+//	kind        the node kind, such as "section" or "ERROR"
+//	named       whether the node is named
+//	field       the field name of the node in its parent
+//	missing     true for a node the parser inserted to recover from an error
+//	start_byte  the byte offset of the start
+//	end_byte    the byte offset of the end
+//	start       the start point as {"row": r, "column": c}, columns in bytes
+//	end         the end point
+//	text        the source text, with IncludeText
+//	children    the child nodes
+//
+// The output depends only on the tree and src, so it can be diffed.
+func MarshalTree(tree *tree_sitter.Tree, src []byte, opts JSONOptions) ([]byte, error) {
+	var b bytes.Buffer
+	if err := WriteTree(&b, tree, src, opts); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// WriteTree is like [MarshalTree] but streams the JSON to w.
+func WriteTree(w io.Writer, tree *tree_sitter.Tree, src []byte, opts JSONOptions) error {
+	cursor := tree.Walk()
+	defer cursor.Close()
+
+	e := &treeEncoder{w: bufio.NewWriter(w), src: src, opts: opts, language: tree.Language()}
+	e.node(cursor)
+	e.w.WriteByte('\n')
+	return e.w.Flush()
+}
+
+type treeEncoder struct {
+	w    *bufio.Writer
+	src  []byte
+	opts JSONOptions
+	buf  []byte
+
+	// Field names are looked up once per ID, like kinds in [NodeKind]: each
+	// conversion from C allocates.
+	language *tree_sitter.Language
+	fields   map[uint16]string
+}
+
+func (e *treeEncoder) field(cursor *tree_sitter.TreeCursor) string {
+	id := cursor.FieldId()
+	if id == 0 {
+		return ""
+	}
+	field, ok := e.fields[id]
+	if !ok {
+		if e.fields == nil {
+			e.fields = map[uint16]string{}
+		}
+		field = e.language.FieldNameForId(id)
+		e.fields[id] = field
+	}
+	return field
+}
+
+func (e *treeEncoder) node(cursor *tree_sitter.TreeCursor) {
+	node := cursor.Node()
+	e.w.WriteString(`{"kind":`)
+	e.string(string(NodeKind(node)))
+	if node.IsNamed() {
+		e.w.WriteString(`,"named":true`)
+	} else {
+		e.w.WriteString(`,"named":false`)
+	}
+	if field := e.field(cursor); field != "" {
+		e.w.WriteString(`,"field":`)
+		e.string(field)
+	}
+	if node.IsMissing() {
+		e.w.WriteString(`,"missing":true`)
+	}
+	e.w.WriteString(`,"start_byte":`)
+	e.uint(node.StartByte())
+	e.w.WriteString(`,"end_byte":`)
+	e.uint(node.EndByte())
+	e.w.WriteString(`,"start":`)
+	e.point(node.StartPosition())
+	e.w.WriteString(`,"end":`)
+	e.point(node.EndPosition())
+	if e.opts.IncludeText {
+		e.w.WriteString(`,"text":`)
+		e.string(string(NoCopyText(node, e.src)))
+	}
+
+	first := true
+	if cursor.GotoFirstChild() {
+		for {
+			if e.opts.Anonymous || cursor.Node().IsNamed() {
+				if first {
+					e.w.WriteString(`,"children":[`)
+					first = false
+				} else {
+					e.w.WriteByte(',')
+				}
+				e.node(cursor)
+			}
+			if !cursor.GotoNextSibling() {
+				break
+			}
+		}
+		cursor.GotoParent()
+	}
+	if !first {
+		e.w.WriteByte(']')
+	}
+	e.w.WriteByte('}')
+}
+
+func (e *treeEncoder) uint(n uint) {
+	e.buf = strconv.AppendUint(e.buf[:0], uint64(n), 10)
+	e.w.Write(e.buf)
+}
+
+func (e *treeEncoder) point(p tree_sitter.Point) {
+	e.w.WriteString(`{"row":`)
+	e.uint(p.Row)
+	e.w.WriteString(`,"column":`)
+	e.uint(p.Column)
+	e.w.WriteByte('}')
+}
+
+// string writes s as a JSON string. Invalid UTF-8 is replaced with U+FFFD.
+func (e *treeEncoder) string(s string) {
+	const hex = "0123456789abcdef"
+	e.w.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			e.w.WriteByte('\\')
+			e.w.WriteByte(c)
+		case c == '\n':
+			e.w.WriteString(`\n`)
+		case c == '\r':
+			e.w.WriteString(`\r`)
+		case c == '\t':
+			e.w.WriteString(`\t`)
+		case c < 0x20:
+			e.w.WriteString(`\u00`)
+			e.w.WriteByte(hex[c>>4])
+			e.w.WriteByte(hex[c&0xf])
+		case c < utf8.RuneSelf:
+			e.w.WriteByte(c)
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				e.w.WriteString(`�`)
+			} else {
+				e.w.WriteString(s[i : i+size])
+			}
+			i += size
+			continue
+		}
+		i++
+	}
+	e.w.WriteByte('"')
+}
@@ -0,0 +1,181 @@
+package tree_sitter_sand_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"maps"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+type jsonPoint struct {
+	Row    uint `json:"row"`
+	Column uint `json:"column"`
+}
+
+type jsonNode struct {
+	Kind      string      `json:"kind"`
+	Named     bool        `json:"named"`
+	Field     string      `json:"field"`
+	Missing   bool        `json:"missing"`
+	StartByte uint        `json:"start_byte"`
+	EndByte   uint        `json:"end_byte"`
+	Start     jsonPoint   `json:"start"`
+	End       jsonPoint   `json:"end"`
+	Text      *string     `json:"text"`
+	Children  []*jsonNode `json:"children"`
+}
+
+// compareJSON walks node and the cursor together, reporting the first
+// difference.
+func compareJSON(t *testing.T, src []byte, node *jsonNode, cursor *tree_sitter.TreeCursor, opts tree_sitter_sand.JSONOptions) {
+	t.Helper()
+
+	want := cursor.Node()
+	got := jsonNode{
+		Kind:      want.Kind(),
+		Named:     want.IsNamed(),
+		Field:     cursor.FieldName(),
+		Missing:   want.IsMissing(),
+		StartByte: want.StartByte(),
+		EndByte:   want.EndByte(),
+		Start:     jsonPoint{want.StartPosition().Row, want.StartPosition().Column},
+		End:       jsonPoint{want.EndPosition().Row, want.EndPosition().Column},
+	}
+	if node.Kind != got.Kind || node.Named != got.Named || node.Field != got.Field ||
+		node.Missing != got.Missing || node.StartByte != got.StartByte || node.EndByte != got.EndByte ||
+		node.Start != got.Start || node.End != got.End {
+		t.Fatalf("node = %+v, cursor has %+v", *node, got)
+	}
+	if opts.IncludeText {
+		// Invalid UTF-8 comes back the way encoding/json replaces it.
+		var text string
+		raw, _ := json.Marshal(string(src[got.StartByte:got.EndByte]))
+		if err := json.Unmarshal(raw, &text); err != nil {
+			t.Fatal(err)
+		}
+		if node.Text == nil || *node.Text != text {
+			t.Fatalf("%s text = %v, want %q", got.Kind, node.Text, text)
+		}
+	} else if node.Text != nil {
+		t.Fatalf("%s has text without IncludeText", got.Kind)
+	}
+
+	i := 0
+	if cursor.GotoFirstChild() {
+		for {
+			if opts.Anonymous || cursor.Node().IsNamed() {
+				if i >= len(node.Children) {
+					t.Fatalf("%s has %d children, cursor has more", got.Kind, len(node.Children))
+				}
+				compareJSON(t, src, node.Children[i], cursor, opts)
+				i++
+			}
+			if !cursor.GotoNextSibling() {
+				break
+			}
+		}
+		cursor.GotoParent()
+	}
+	if i != len(node.Children) {
+		t.Fatalf("%s has %d children, cursor has %d", got.Kind, len(node.Children), i)
+	}
+}
+
+func TestMarshalTree(t *testing.T) {
+	files := corpus(t)
+	files["crlf.sand"] = []byte("#(en)\r\n\r\n## \"quoted\" \\ title\r\n\r\n#s[tab\there]\r\n")
+	files["invalid.sand"] = []byte("#(en, ja)\n\n#[\xff\x01]")
+
+	for name, src := range files {
+		tree := cst(t, src)
+		defer tree.Close()
+
+		for _, opts := range []tree_sitter_sand.JSONOptions{
+			{},
+			{IncludeText: true},
+			{Anonymous: true},
+			{IncludeText: true, Anonymous: true},
+		} {
+			out, err := tree_sitter_sand.MarshalTree(tree, src, opts)
+			if err != nil {
+				t.Fatalf("%s %+v: %v", name, opts, err)
+			}
+			if !json.Valid(out) {
+				t.Fatalf("%s %+v: invalid JSON: %s", name, opts, out)
+			}
+			var root jsonNode
+			if err := json.Unmarshal(out, &root); err != nil {
+				t.Fatalf("%s %+v: %v", name, opts, err)
+			}
+			cursor := tree.Walk()
+			compareJSON(t, src, &root, cursor, opts)
+			cursor.Close()
+
+			other := cst(t, src)
+			again, err := tree_sitter_sand.MarshalTree(other, src, opts)
+			other.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, again) {
+				t.Errorf("%s %+v: output differs between parses", name, opts)
+			}
+		}
+	}
+}
+
+func TestMarshalTreeFields(t *testing.T) {
+	src := []byte("#(en)\n\n#intro## Hello\n")
+	tree := cst(t, src)
+	defer tree.Close()
+
+	out, err := tree_sitter_sand.MarshalTree(tree, src, tree_sitter_sand.JSONOptions{IncludeText: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root jsonNode
+	if err := json.Unmarshal(out, &root); err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]string{}
+	var walk func(n *jsonNode)
+	walk = func(n *jsonNode) {
+		if n.Field != "" {
+			fields[n.Field] = *n.Text
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(&root)
+	want := map[string]string{"languages": "en", "alias": "intro", "hashes": "##", "title": " Hello"}
+	if !maps.Equal(fields, want) {
+		t.Errorf("fields = %q, want %q", fields, want)
+	}
+}
+
+func BenchmarkMarshalTree(b *testing.B) {
+	src := generate(1 << 20)
+	tree := cst(b, src)
+	defer tree.Close()
+
+	for _, opts := range []tree_sitter_sand.JSONOptions{{}, {IncludeText: true, Anonymous: true}} {
+		name := "named"
+		if opts.Anonymous {
+			name = "all"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(src)))
+			for range b.N {
+				if err := tree_sitter_sand.WriteTree(io.Discard, tree, src, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
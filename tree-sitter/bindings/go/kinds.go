@@ -0,0 +1,72 @@
+package tree_sitter_sand
+
+import (
+	"sync"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Kind is the type of a named node in the Sand grammar, as reported by
+// [tree_sitter.Node.Kind].
+type Kind string
+
+// Named node kinds of the Sand grammar. The set mirrors src/node-types.json;
+// kinds_test.go checks it against the symbol table of the compiled parser.
+const (
+	KindSourceFile         Kind = "source_file"
+	KindNameDefinition     Kind = "name_definition"
+	KindIdentifierList     Kind = "identifier_list"
+	KindSection            Kind = "section"
+	KindHashes             Kind = "hashes"
+	KindOneLineStr         Kind = "one_line_str"
+	KindApplyAll           Kind = "apply_all"
+	KindSentenceDefinition Kind = "sentence_definition"
+	KindSelector           Kind = "selector"
+	KindString             Kind = "string"
+	KindNonEscapedString   Kind = "non_escaped_string"
+	KindIdentifier         Kind = "identifier"
+
+	// KindError is the kind tree-sitter gives to nodes it could not parse.
+	KindError Kind = "ERROR"
+)
+
+// Kinds lists every named node kind of the grammar, excluding KindError.
+var Kinds = []Kind{
+	KindSourceFile,
+	KindNameDefinition,
+	KindIdentifierList,
+	KindSection,
+	KindHashes,
+	KindOneLineStr,
+	KindApplyAll,
+	KindSentenceDefinition,
+	KindSelector,
+	KindString,
+	KindNonEscapedString,
+	KindIdentifier,
+}
+
+// kindNames holds the kind of every symbol of the grammar by id.
+// [tree_sitter.Node.Kind] copies the name out of C on every call, which
+// made it the most frequent allocation when building a [Document].
+var kindNames = sync.OnceValue(func() []Kind {
+	lang := language()
+	names := make([]Kind, lang.NodeKindCount())
+	for id := range names {
+		names[id] = Kind(lang.NodeKindForId(uint16(id)))
+	}
+	return names
+})
+
+// NodeKind returns the Kind of node. Unlike [tree_sitter.Node.Kind], it does
+// not allocate, and it also works for anonymous nodes: NodeKind(n) == "]".
+func NodeKind(node *tree_sitter.Node) Kind {
+	if names := kindNames(); int(node.KindId()) < len(names) {
+		return names[node.KindId()]
+	}
+	return Kind(node.Kind())
+}
+
+func (k Kind) String() string {
+	return string(k)
+}
@@ -0,0 +1,31 @@
+package tree_sitter_sand_test
+
+import (
+	"slices"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestKindsMatchSymbolTable(t *testing.T) {
+	language := tree_sitter.NewLanguage(tree_sitter_sand.Language())
+
+	seen := map[tree_sitter_sand.Kind]bool{}
+	for id := uint16(0); id < uint16(language.NodeKindCount()); id++ {
+		if !language.NodeKindIsNamed(id) || !language.NodeKindIsVisible(id) {
+			continue
+		}
+		kind := tree_sitter_sand.Kind(language.NodeKindForId(id))
+		seen[kind] = true
+		if !slices.Contains(tree_sitter_sand.Kinds, kind) {
+			t.Errorf("named kind %q has no constant", kind)
+		}
+	}
+
+	for _, kind := range tree_sitter_sand.Kinds {
+		if !seen[kind] {
+			t.Errorf("constant %q is not a named kind of the grammar", kind)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package lint checks Sand documents against style rules.
+//
+// A [Rule] looks at a [Document] and reports [Diagnostic]s, optionally with
+// edits that fix them. A [Runner] runs a set of rules over a source and
+// returns their diagnostics in document order. [Builtin] returns the rules
+// that ship with this package.
+package lint
+
+import (
+	"slices"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/position"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Document is what rules check: the typed tree together with the source it
+// was parsed from and the tree-sitter tree.
+type Document struct {
+	*tree_sitter_sand.Document
+	Source []byte
+	Tree   *tree_sitter.Tree
+	// Index converts offsets in Source to positions.
+	Index *position.Index
+}
+
+// Range returns the range from offset start to offset end.
+func (d *Document) Range(start, end int) tree_sitter_sand.Range {
+	return d.Index.Range(start, end)
+}
+
+// Diagnostic is a problem reported by a rule.
+type Diagnostic struct {
+	tree_sitter_sand.Diagnostic
+	// Fixes are edits that together fix the problem. They do not overlap.
+	Fixes []tree_sitter_sand.TextEdit
+}
+
+// Rule is a lint check. Check must not modify the document.
+type Rule interface {
+	// Name identifies the rule, such as "trailing-whitespace".
+	Name() string
+	Check(doc *Document) []Diagnostic
+}
+
+// Runner runs rules over documents.
+type Runner struct {
+	Rules []Rule
+}
+
+// NewRunner returns a runner for rules.
+func NewRunner(rules ...Rule) *Runner {
+	return &Runner{Rules: rules}
+}
+
+// Run parses src and returns the diagnostics of all rules sorted by
+// position. Diagnostics starting at the same offset keep the order of the
+// rules. A diagnostic without a code gets the name of its rule.
+//
+// Syntax errors do not stop the rules, which see the partial document; use
+// [tree_sitter_sand.Diagnose] to report them.
+func (r *Runner) Run(src []byte) ([]Diagnostic, error) {
+	tree, err := tree_sitter_sand.ParseTree(src)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	doc, err := tree_sitter_sand.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Document{Document: doc, Source: src, Tree: tree, Index: position.NewIndex(src)}
+	var diags []Diagnostic
+	for _, rule := range r.Rules {
+		for _, diag := range rule.Check(d) {
+			if diag.Code == "" {
+				diag.Code = rule.Name()
+			}
+			diags = append(diags, diag)
+		}
+	}
+	slices.SortStableFunc(diags, func(a, b Diagnostic) int {
+		return int(a.Range.StartByte) - int(b.Range.StartByte)
+	})
+	return diags, nil
+}
+
+// Builtin returns the built-in rules with their default settings.
+func Builtin() []Rule {
+	return []Rule{
+		HeadingIncrement{},
+		TrailingWhitespace{},
+		LineLength{Max: 80},
+		SentenceCount{},
+	}
+}
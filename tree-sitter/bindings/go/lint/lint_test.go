@@ -0,0 +1,142 @@
+package lint_test
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/satler-git/sand-markup/bindings/go/lint"
+)
+
+// run returns "row:col code: message" for every diagnostic of rules in src.
+func run(t *testing.T, src string, rules ...lint.Rule) []string {
+	t.Helper()
+	diags, err := lint.NewRunner(rules...).Run([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, d := range diags {
+		p := d.Range.StartPoint
+		out = append(out, fmt.Sprintf("%d:%d %s: %s", p.Row+1, p.Column+1, d.Code, d.Message))
+	}
+	return out
+}
+
+type ruleTest struct {
+	name string
+	src  string
+	want []string
+}
+
+func testRule(t *testing.T, rule lint.Rule, tests []ruleTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(t, tt.src, rule); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadingIncrement(t *testing.T) {
+	testRule(t, lint.HeadingIncrement{}, []ruleTest{
+		{"ok", "#(en)\n\n## A\n### B\n#### C\n## D\n", nil},
+		{"skip", "#(en)\n\n## A\n#### C\n", []string{"4:1 heading-increment: heading level 3 skips level 2"}},
+		{"top level", "#(en)\n\n#a## A\n", []string{"3:1 heading-increment: heading level 2 skips level 1"}},
+		{"back up", "#(en)\n\n## A\n### B\n## C\n#### D\n", []string{"6:1 heading-increment: heading level 3 skips level 2"}},
+	})
+}
+
+func TestTrailingWhitespace(t *testing.T) {
+	testRule(t, lint.TrailingWhitespace{}, []ruleTest{
+		{"ok", "#(en)\n\ntext\n", nil},
+		{"spaces and tabs", "#(en) \n\ntext\t \r\n#s[x]  ", []string{
+			"1:6 trailing-whitespace: trailing whitespace",
+			"3:5 trailing-whitespace: trailing whitespace",
+			"4:6 trailing-whitespace: trailing whitespace",
+		}},
+		{"blank line", "#(en)\n   \ntext\n", []string{"2:1 trailing-whitespace: trailing whitespace"}},
+	})
+}
+
+func TestTrailingWhitespaceFix(t *testing.T) {
+	src := "#(en)\n\ntext\t \r\n"
+	diags, err := lint.NewRunner(lint.TrailingWhitespace{}).Run([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || len(diags[0].Fixes) != 1 {
+		t.Fatalf("got %+v", diags)
+	}
+	fix := diags[0].Fixes[0]
+	if got := src[:fix.Range.StartByte] + fix.NewText + src[fix.Range.EndByte:]; got != "#(en)\n\ntext\r\n" {
+		t.Errorf("fixed source = %q", got)
+	}
+}
+
+func TestLineLength(t *testing.T) {
+	testRule(t, lint.LineLength{Max: 10}, []ruleTest{
+		{"ok", "#(en)\n\nshort line\n", nil},
+		{"long", "#(en)\n\nshort\nthis line is long\n", []string{"4:11 line-length: line is 17 characters long, more than 10"}},
+		{"runes", "#(en)\n\n日本語の文章は十文字\n日本語の文章は十一文字\n", nil},
+		{"wide runes", "#(en)\n\n日本語の文章は十一文字 です\n", []string{"3:31 line-length: line is 14 characters long, more than 10"}},
+		{"no space after the limit", "#(en)\n\nsee https://example.com/long\n", nil},
+		{"headings are not prose", "#(en)\n\n## a very long heading title\n", nil},
+		{"inside sentences", "#(en)\n\n#s[\n    a long sentence content\n]\n", []string{"4:11 line-length: line is 27 characters long, more than 10"}},
+	})
+}
+
+func TestSentenceCount(t *testing.T) {
+	testRule(t, lint.SentenceCount{}, []ruleTest{
+		{"ok", "#(en, ja)\n\n#[a][b]\n", nil},
+		{"too few", "#(en, ja)\n\n## A\n#s[a]\n", []string{"4:1 sentence-count: sentence has 1 contents for 2 names"}},
+		{"too many", "#(en)\n\nx #[a][b]\n", []string{"3:3 sentence-count: sentence has 2 contents for 1 names"}},
+		{"no names", "#[a][b]\n", nil},
+	})
+}
+
+func TestRunnerOrder(t *testing.T) {
+	src := strings.Join([]string{
+		"#(en, ja) ",
+		"",
+		"#a## A",
+		"#s[a] and some more words",
+		"#b# B ",
+	}, "\n")
+	got := run(t, src, lint.HeadingIncrement{}, lint.TrailingWhitespace{}, lint.LineLength{Max: 10}, lint.SentenceCount{})
+	want := []string{
+		"1:10 trailing-whitespace: trailing whitespace",
+		"3:1 heading-increment: heading level 2 skips level 1",
+		"4:1 sentence-count: sentence has 1 contents for 2 names",
+		"4:11 line-length: line is 25 characters long, more than 10",
+		"5:6 trailing-whitespace: trailing whitespace",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestBuiltinOnCorpus(t *testing.T) {
+	// The README follows the built-in style except for its long lines.
+	for _, rule := range lint.Builtin() {
+		if _, ok := rule.(lint.LineLength); ok {
+			continue
+		}
+		if got := run(t, readme(t), rule); len(got) > 0 {
+			t.Errorf("%s: %q", rule.Name(), got)
+		}
+	}
+}
+
+func readme(t *testing.T) string {
+	t.Helper()
+	src, err := os.ReadFile("../../../../README.sand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(src)
+}
@@ -0,0 +1,154 @@
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/position"
+)
+
+// HeadingIncrement reports sections more than one level below their parent.
+// Top-level sections must be level 1.
+type HeadingIncrement struct{}
+
+func (HeadingIncrement) Name() string { return "heading-increment" }
+
+func (HeadingIncrement) Check(doc *Document) []Diagnostic {
+	var diags []Diagnostic
+	var walk func(nodes []tree_sitter_sand.Node, parent int)
+	walk = func(nodes []tree_sitter_sand.Node, parent int) {
+		for _, node := range nodes {
+			sec, ok := node.(*tree_sitter_sand.Section)
+			if !ok {
+				continue
+			}
+			if sec.Level > parent+1 {
+				diags = append(diags, warning(doc, sec.Heading,
+					fmt.Sprintf("heading level %d skips level %d", sec.Level, parent+1)))
+			}
+			walk(sec.Children, sec.Level)
+		}
+	}
+	walk(doc.Children, 0)
+	return diags
+}
+
+// TrailingWhitespace reports spaces and tabs at the end of lines, with a fix
+// that removes them.
+type TrailingWhitespace struct{}
+
+func (TrailingWhitespace) Name() string { return "trailing-whitespace" }
+
+func (TrailingWhitespace) Check(doc *Document) []Diagnostic {
+	var diags []Diagnostic
+	for line := range doc.Index.Lines() {
+		text := lineText(doc, line)
+		trimmed := bytes.TrimRight(text, " \t")
+		if len(trimmed) == len(text) {
+			continue
+		}
+		start := doc.Index.Offset(line, 0, position.Bytes)
+		r := doc.Range(start+len(trimmed), start+len(text))
+		d := warning(doc, r, "trailing whitespace")
+		d.Fixes = []tree_sitter_sand.TextEdit{{Range: r}}
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// LineLength reports prose lines longer than Max runes. Lines with no space
+// after the limit, such as long URLs, are allowed.
+type LineLength struct {
+	Max int
+}
+
+func (LineLength) Name() string { return "line-length" }
+
+func (l LineLength) Check(doc *Document) []Diagnostic {
+	var diags []Diagnostic
+	last := -1 // the last line checked, as paragraphs may share one
+	var walk func(nodes []tree_sitter_sand.Node)
+	walk = func(nodes []tree_sitter_sand.Node) {
+		for _, node := range nodes {
+			switch node := node.(type) {
+			case *tree_sitter_sand.Section:
+				walk(node.Children)
+			case *tree_sitter_sand.Paragraph:
+				first := max(int(node.Range.StartPoint.Row), last+1)
+				for line := first; line <= int(node.Range.EndPoint.Row); line++ {
+					if d, ok := l.check(doc, line); ok {
+						diags = append(diags, d)
+					}
+					last = line
+				}
+			}
+		}
+	}
+	walk(doc.Children)
+	return diags
+}
+
+func (l LineLength) check(doc *Document, line int) (Diagnostic, bool) {
+	text := lineText(doc, line)
+	n := utf8.RuneCount(text)
+	if n <= l.Max {
+		return Diagnostic{}, false
+	}
+	from := doc.Index.Offset(line, l.Max, position.Runes)
+	start := doc.Index.Offset(line, 0, position.Bytes)
+	if !bytes.ContainsAny(doc.Source[from:start+len(text)], " \t") {
+		return Diagnostic{}, false
+	}
+	return warning(doc, doc.Range(from, start+len(text)),
+		fmt.Sprintf("line is %d characters long, more than %d", n, l.Max)), true
+}
+
+// SentenceCount reports sentence definitions whose number of contents
+// differs from the number of names, which `sand` rejects.
+type SentenceCount struct{}
+
+func (SentenceCount) Name() string { return "sentence-count" }
+
+func (SentenceCount) Check(doc *Document) []Diagnostic {
+	if doc.Names == nil {
+		return nil
+	}
+	var diags []Diagnostic
+	var walk func(nodes []tree_sitter_sand.Node)
+	walk = func(nodes []tree_sitter_sand.Node) {
+		for _, node := range nodes {
+			switch node := node.(type) {
+			case *tree_sitter_sand.Section:
+				walk(node.Children)
+			case *tree_sitter_sand.Paragraph:
+				for _, span := range node.Spans {
+					if span.Kind == tree_sitter_sand.SpanSentence && len(span.Contents) != len(doc.Names) {
+						d := warning(doc, span.Range,
+							fmt.Sprintf("sentence has %d contents for %d names", len(span.Contents), len(doc.Names)))
+						d.Severity = tree_sitter_sand.SeverityError
+						diags = append(diags, d)
+					}
+				}
+			}
+		}
+	}
+	walk(doc.Children)
+	return diags
+}
+
+// lineText returns line without its line break.
+func lineText(doc *Document, line int) []byte {
+	start := doc.Index.Offset(line, 0, position.Bytes)
+	return doc.Source[start:doc.Index.Offset(line, len(doc.Source), position.Bytes)]
+}
+
+func warning(doc *Document, r tree_sitter_sand.Range, msg string) Diagnostic {
+	return Diagnostic{Diagnostic: tree_sitter_sand.Diagnostic{
+		Range:    r,
+		Severity: tree_sitter_sand.SeverityWarning,
+		Message:  msg,
+		Source:   doc.Source[r.StartByte:r.EndByte],
+	}}
+}
@@ -0,0 +1,299 @@
+// Package complete computes completion candidates for Sand documents, as for
+// an LSP textDocument/completion request.
+//
+// Sand references things in two places: the path of a selector (`#.a.b.en`),
+// whose elements are aliases and whose last element is a name, and the
+// target list of an apply-all block (`#{[en, ja], {...}}`), which holds
+// names. [At] completes both.
+package complete
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/position"
+)
+
+// Kind is what a candidate refers to.
+type Kind int
+
+const (
+	// KindSection is the alias of a section, which is also the anchor of
+	// its heading.
+	KindSection Kind = iota + 1
+	// KindSentence is the alias of a sentence definition.
+	KindSentence
+	// KindApplyAll is the alias of an apply-all block.
+	KindApplyAll
+	// KindName is a name of the document's name definition.
+	KindName
+)
+
+// Candidate is one completion item.
+type Candidate struct {
+	Label string
+	// InsertText replaces Range when the candidate is accepted.
+	InsertText string
+	Kind       Kind
+	// Detail is the heading title of a section or the first content of a
+	// sentence or apply-all block.
+	Detail string
+	// Range is the identifier at the offset, including what follows the
+	// offset, so a partly typed label is replaced as a whole. It is empty
+	// right after a delimiter.
+	Range tree_sitter_sand.Range
+}
+
+// At returns the candidates for completing src at offset, in the order of
+// their definitions, aliases before names. Only candidates starting with
+// the part of the identifier before offset are returned. At returns nil if
+// offset is not in a selector or an apply-all target list.
+func At(src []byte, offset uint) []Candidate {
+	doc, err := tree_sitter_sand.Parse(src)
+	if err != nil {
+		return nil
+	}
+	span, container := spanAt(doc, offset)
+	if span == nil {
+		return nil
+	}
+
+	c := &completer{src: src, doc: doc}
+	switch span.Kind {
+	case tree_sitter_sand.SpanSelector:
+		return c.selector(span, container, offset)
+	case tree_sitter_sand.SpanApplyAll:
+		return c.targets(span, offset)
+	}
+	return nil
+}
+
+// spanAt returns the selector or apply-all span whose references contain
+// offset, and the section or document it is in.
+func spanAt(doc *tree_sitter_sand.Document, offset uint) (*tree_sitter_sand.InlineSpan, tree_sitter_sand.Node) {
+	var find func(container tree_sitter_sand.Node, nodes []tree_sitter_sand.Node) (*tree_sitter_sand.InlineSpan, tree_sitter_sand.Node)
+	find = func(container tree_sitter_sand.Node, nodes []tree_sitter_sand.Node) (*tree_sitter_sand.InlineSpan, tree_sitter_sand.Node) {
+		for _, node := range nodes {
+			switch node := node.(type) {
+			case *tree_sitter_sand.Section:
+				if span, in := find(node, node.Children); span != nil {
+					return span, in
+				}
+			case *tree_sitter_sand.Paragraph:
+				for _, span := range node.Spans {
+					// The references start after `#.` or `#{`.
+					r := span.Range
+					if offset < r.StartByte+2 || offset > r.EndByte {
+						continue
+					}
+					if span.Kind == tree_sitter_sand.SpanSelector || span.Kind == tree_sitter_sand.SpanApplyAll {
+						return span, container
+					}
+				}
+			}
+		}
+		return nil, nil
+	}
+	return find(doc, doc.Children)
+}
+
+type completer struct {
+	src []byte
+	doc *tree_sitter_sand.Document
+	x   *position.Index
+}
+
+// word returns the bounds of the identifier around offset, which may be
+// empty, without going past limit.
+func (c *completer) word(offset, limit uint) (start, end uint) {
+	start, end = offset, offset
+	for start > 0 && isIdent(c.src[start-1]) {
+		start--
+	}
+	for end < limit && isIdent(c.src[end]) {
+		end++
+	}
+	return start, end
+}
+
+func isIdent(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || b == '_'
+}
+
+// candidate returns a candidate replacing the identifier from start to end.
+func (c *completer) candidate(label string, kind Kind, detail string, start, end uint) Candidate {
+	if c.x == nil {
+		c.x = position.NewIndex(c.src)
+	}
+	return Candidate{
+		Label:      label,
+		InsertText: label,
+		Kind:       kind,
+		Detail:     detail,
+		Range:      c.x.Range(int(start), int(end)),
+	}
+}
+
+// names returns the names of the document starting with prefix, leaving
+// out those in skip.
+func (c *completer) names(prefix string, skip []string, start, end uint) []Candidate {
+	var out []Candidate
+	for _, name := range c.doc.Names {
+		if strings.HasPrefix(name, prefix) && !slices.Contains(skip, name) {
+			out = append(out, c.candidate(name, KindName, "", start, end))
+		}
+	}
+	return out
+}
+
+// selector completes the path element of span at offset: the aliases of the
+// scope the elements before it lead to, and the names.
+func (c *completer) selector(span *tree_sitter_sand.InlineSpan, container tree_sitter_sand.Node, offset uint) []Candidate {
+	start, end := c.word(offset, span.Range.EndByte)
+	path := string(c.src[span.Range.StartByte+2 : start])
+	from := tree_sitter_sand.Node(c.doc)
+	if p, ok := strings.CutPrefix(path, "/"); ok {
+		path, from = p, container
+	}
+	prefix := string(c.src[start:offset])
+
+	var out []Candidate
+	// The elements before the one at the offset are complete; a trailing
+	// "." ends the path at start.
+	var elements []string
+	if path != "" {
+		elements = strings.Split(strings.TrimSuffix(path, "."), ".")
+	}
+	if scope := resolve(from, elements); scope != nil {
+		seen := map[string]bool{}
+		for _, item := range items(scope) {
+			alias, kind, detail := describe(item)
+			if alias == "" || seen[alias] || !strings.HasPrefix(alias, prefix) {
+				continue
+			}
+			seen[alias] = true
+			out = append(out, c.candidate(alias, kind, detail, start, end))
+		}
+	}
+	return append(out, c.names(prefix, nil, start, end)...)
+}
+
+// targets completes the name at offset in the target list of an apply-all
+// span.
+func (c *completer) targets(span *tree_sitter_sand.InlineSpan, offset uint) []Candidate {
+	if span.Targets == nil {
+		return nil
+	}
+	open := span.Range.StartByte + 2
+	if c.src[open] != '[' || offset <= open {
+		return nil
+	}
+	i := strings.IndexByte(string(c.src[open:span.Range.EndByte]), ']')
+	if i < 0 || offset > open+uint(i) {
+		return nil
+	}
+	start, end := c.word(offset, open+uint(i))
+	if b := c.src[start-1]; b != '[' && b != ',' && b != ' ' && b != '\t' {
+		return nil
+	}
+	// Names listed elsewhere in the list are not offered again.
+	current := string(c.src[start:end])
+	var skip []string
+	for _, target := range span.Targets {
+		if target != current {
+			skip = append(skip, target)
+		}
+	}
+	return c.names(string(c.src[start:offset]), skip, start, end)
+}
+
+// resolve follows elements from scope as a selector does, by alias or by an
+// index that skips selectors. It returns nil if an element does not resolve
+// or leads to a sentence or apply-all block, which have no aliases below
+// them.
+func resolve(scope tree_sitter_sand.Node, elements []string) tree_sitter_sand.Node {
+	for _, key := range elements {
+		var next tree_sitter_sand.Node
+		for _, item := range items(scope) {
+			if alias, _, _ := describe(item); alias == key {
+				next = item
+				break
+			}
+		}
+		if next == nil {
+			next = index(scope, key)
+		}
+		if _, ok := next.(*tree_sitter_sand.Section); !ok {
+			return nil
+		}
+		scope = next
+	}
+	return scope
+}
+
+// index returns the item of scope at the index key, not counting selectors.
+func index(scope tree_sitter_sand.Node, key string) tree_sitter_sand.Node {
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 {
+		return nil
+	}
+	for _, item := range items(scope) {
+		if span, ok := item.(*tree_sitter_sand.InlineSpan); ok && span.Kind == tree_sitter_sand.SpanSelector {
+			continue
+		}
+		if i == 0 {
+			return item
+		}
+		i--
+	}
+	return nil
+}
+
+// items returns what selectors see directly inside a document or section:
+// subsections, sentences, apply-all blocks and selectors.
+func items(scope tree_sitter_sand.Node) []tree_sitter_sand.Node {
+	var children []tree_sitter_sand.Node
+	switch scope := scope.(type) {
+	case *tree_sitter_sand.Document:
+		children = scope.Children
+	case *tree_sitter_sand.Section:
+		children = scope.Children
+	}
+	var out []tree_sitter_sand.Node
+	for _, child := range children {
+		switch child := child.(type) {
+		case *tree_sitter_sand.Section:
+			out = append(out, child)
+		case *tree_sitter_sand.Paragraph:
+			for _, span := range child.Spans {
+				if span.Kind != tree_sitter_sand.SpanText {
+					out = append(out, span)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// describe returns the alias of a scope item, its candidate kind and its
+// detail.
+func describe(item tree_sitter_sand.Node) (string, Kind, string) {
+	switch item := item.(type) {
+	case *tree_sitter_sand.Section:
+		return item.Alias, KindSection, item.Title
+	case *tree_sitter_sand.InlineSpan:
+		var detail string
+		if len(item.Contents) > 0 {
+			detail = item.Contents[0].Text
+		}
+		switch item.Kind {
+		case tree_sitter_sand.SpanSentence:
+			return item.Alias, KindSentence, detail
+		case tree_sitter_sand.SpanApplyAll:
+			return item.Alias, KindApplyAll, detail
+		}
+	}
+	return "", 0, ""
+}
@@ -0,0 +1,128 @@
+package complete_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/satler-git/sand-markup/bindings/go/lsp/complete"
+)
+
+const doc = `#(en, ja)
+
+#intro# Introduction
+#hello[Hello][こんにちは]
+#{[en], {Welcome.}}
+
+#usage# Usage
+#install[Install it][インストール]
+
+#s[x][y] #.
+`
+
+// at completes src at the offset of the "|" in it, which is removed, and
+// describes the candidates as "label kind replaced-text".
+func at(t *testing.T, src string) []string {
+	t.Helper()
+	offset := strings.Index(src, "|")
+	if offset < 0 {
+		t.Fatalf("no cursor in %q", src)
+	}
+	src = src[:offset] + src[offset+1:]
+	var out []string
+	for _, c := range complete.At([]byte(src), uint(offset)) {
+		if c.InsertText != c.Label {
+			t.Errorf("%s: insert text %q", c.Label, c.InsertText)
+		}
+		out = append(out, fmt.Sprintf("%s %d %q", c.Label, c.Kind, src[c.Range.StartByte:c.Range.EndByte]))
+	}
+	return out
+}
+
+func TestAt(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "after the opening delimiter",
+			src:  doc + "#.|",
+			want: []string{`intro 1 ""`, `usage 1 ""`, `en 4 ""`, `ja 4 ""`},
+		},
+		{
+			name: "mid-label",
+			src:  doc + "#.in|tro.en",
+			want: []string{`intro 1 "intro"`},
+		},
+		{
+			name: "after an alias",
+			src:  doc + "#.intro.|",
+			want: []string{`hello 2 ""`, `en 4 ""`, `ja 4 ""`},
+		},
+		{
+			name: "by index",
+			src:  doc + "#.1.i|",
+			want: []string{`install 2 "i"`},
+		},
+		{
+			name: "local",
+			src:  "#(en)\n\n#a# A\n#s[x] #./|\n#b# B\n#t[y]\n",
+			want: []string{`s 2 ""`, `en 4 ""`},
+		},
+		{
+			name: "into a sentence",
+			src:  doc + "#.intro.hello.|",
+			want: []string{`en 4 ""`, `ja 4 ""`},
+		},
+		{
+			name: "apply-all targets",
+			src:  doc + "#{[en, |], {x}}",
+			want: []string{`ja 4 ""`},
+		},
+		{
+			name: "apply-all target mid-name",
+			src:  doc + "#{[e|n], {x}}",
+			want: []string{`en 4 "en"`},
+		},
+		{name: "prose", src: doc + "some |prose"},
+		{name: "sentence content", src: doc + "#t[te|xt]"},
+		{name: "apply-all content", src: doc + "#{[en], {te|xt}}"},
+		{name: "before the selector", src: doc + "|#.intro.en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := at(t, tt.src); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAtOutOfRange(t *testing.T) {
+	for _, offset := range []uint{0, uint(len(doc)), uint(len(doc)) + 10} {
+		if got := complete.At([]byte(doc), offset); len(got) != 0 {
+			t.Errorf("offset %d: got %v", offset, got)
+		}
+	}
+	if got := complete.At(nil, 0); len(got) != 0 {
+		t.Errorf("empty document: got %v", got)
+	}
+}
+
+func TestAtRangePositions(t *testing.T) {
+	src := "#(en)\n\n#intro# 導入\n#s[日本語] #.in"
+	got := complete.At([]byte(src), uint(len(src)))
+	if len(got) != 1 || got[0].Label != "intro" {
+		t.Fatalf("got %v", got)
+	}
+	// Columns count bytes, like tree-sitter points.
+	r := got[0].Range
+	if r.StartPoint.Row != 3 || r.StartPoint.Column != uint(len("#s[日本語] #.")) || r.EndPoint.Column != uint(len("#s[日本語] #.in")) {
+		t.Errorf("replaces %+v to %+v", r.StartPoint, r.EndPoint)
+	}
+	if got[0].Detail != "導入" {
+		t.Errorf("detail = %q", got[0].Detail)
+	}
+}
@@ -0,0 +1,255 @@
+// Package semtok encodes Sand highlights as LSP semantic tokens.
+//
+// [Encode] runs the highlight query of the grammar over a document and
+// returns the data of a textDocument/semanticTokens/full response: five
+// uint32 per token holding the line and start character relative to the
+// previous token, the length, the token type and the modifier bits.
+// Characters are counted in UTF-16 code units and tokens spanning several
+// lines are split, as the spec requires for clients without
+// multilineTokenSupport.
+package semtok
+
+import (
+	"bytes"
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/query"
+)
+
+// Legend maps highlight captures to semantic token types and modifiers. Send
+// TokenTypes and TokenModifiers to the client as the legend of the server
+// capabilities.
+type Legend struct {
+	TokenTypes     []string
+	TokenModifiers []string
+	// Captures maps capture names to tokens. A name that is not in the map
+	// falls back to its parent, so "punctuation.special" uses
+	// "punctuation" if only that is present. Captures with no entry, or
+	// whose type is not in TokenTypes, produce no token.
+	Captures map[string]Token
+}
+
+// Token is the semantic token a capture is reported as.
+type Token struct {
+	Type      string
+	Modifiers []string
+}
+
+// DefaultLegend returns a legend using the standard LSP token types.
+func DefaultLegend() Legend {
+	return Legend{
+		TokenTypes: []string{"keyword", "operator", "variable", "string"},
+		Captures: map[string]Token{
+			"punctuation.special": {Type: "keyword"},
+			"punctuation":         {Type: "operator"},
+			"variable":            {Type: "variable"},
+			"string":              {Type: "string"},
+		},
+	}
+}
+
+// token returns the type index and modifier bits of capture, and false if it
+// is not reported.
+func (l *Legend) token(capture string) (uint32, uint32, bool) {
+	for name := capture; ; {
+		if tok, ok := l.Captures[name]; ok {
+			typ := slices.Index(l.TokenTypes, tok.Type)
+			if typ < 0 {
+				return 0, 0, false
+			}
+			var mods uint32
+			for _, m := range tok.Modifiers {
+				if i := slices.Index(l.TokenModifiers, m); i >= 0 {
+					mods |= 1 << i
+				}
+			}
+			return uint32(typ), mods, true
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			return 0, 0, false
+		}
+		name = name[:i]
+	}
+}
+
+// Position is an LSP position: a zero-based line and a character offset in
+// UTF-16 code units.
+type Position struct {
+	Line      uint32
+	Character uint32
+}
+
+var highlights = sync.OnceValue(func() *query.Query {
+	q, err := query.Compile(tree_sitter_sand.HighlightsQuery())
+	if err != nil {
+		panic("semtok: invalid highlight query: " + err.Error())
+	}
+	return q
+})
+
+// Encode returns the semantic tokens of src. Where captures overlap, the
+// innermost wins, as in editors applying the highlight query: the capture of
+// a whole sentence definition only covers what its children's captures
+// leave.
+func Encode(src []byte, legend Legend) []uint32 {
+	return encode(tokens(src, &legend))
+}
+
+// EncodeRange is like [Encode] for a textDocument/semanticTokens/range
+// request: it returns the tokens that overlap the range from start to end.
+// The first token is relative to the start of the document, as the spec
+// requires.
+func EncodeRange(src []byte, legend Legend, start, end Position) []uint32 {
+	var in []token
+	for _, t := range tokens(src, &legend) {
+		tokEnd := Position{t.line, t.char + t.length}
+		if before(Position{t.line, t.char}, end) && before(start, tokEnd) {
+			in = append(in, t)
+		}
+	}
+	return encode(in)
+}
+
+func before(a, b Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+}
+
+// token is a single-line token at an absolute position.
+type token struct {
+	line, char, length uint32
+	typ, mods          uint32
+}
+
+// joins reports whether next continues t.
+func (t token) joins(next token) bool {
+	return t.line == next.line && t.char+t.length == next.char && t.typ == next.typ && t.mods == next.mods
+}
+
+// tokens returns the tokens of src in document order.
+func tokens(src []byte, legend *Legend) []token {
+	type span struct {
+		start, end uint
+		typ, mods  uint32
+	}
+	var spans []span
+	for m := range highlights().Matches(src) {
+		for _, c := range m.Captures {
+			if typ, mods, ok := legend.token(c.Name); ok && c.Range.EndByte > c.Range.StartByte {
+				spans = append(spans, span{c.Range.StartByte, c.Range.EndByte, typ, mods})
+			}
+		}
+	}
+	// Captures are nodes, so they nest. Outer ones sort first and a later
+	// capture of the same node overrides an earlier one.
+	slices.SortStableFunc(spans, func(a, b span) int {
+		return cmp.Or(cmp.Compare(a.start, b.start), cmp.Compare(b.end, a.end))
+	})
+
+	l := &lines{src: src}
+	var stack []span
+	var pos uint
+	// flush emits the innermost open captures up to offset upto.
+	flush := func(upto uint) {
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if end := min(top.end, upto); end > pos {
+				l.emit(pos, end, top.typ, top.mods)
+				pos = end
+			}
+			if top.end > upto {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+		pos = max(pos, upto)
+	}
+	for _, s := range spans {
+		flush(s.start)
+		stack = append(stack, s)
+	}
+	flush(uint(len(src)))
+	return l.out
+}
+
+// lines splits captures into single-line tokens. Captures must be emitted in
+// document order.
+type lines struct {
+	src       []byte
+	line      uint // the current line
+	lineStart uint // the offset it starts at
+	out       []token
+}
+
+func (l *lines) emit(from, to uint, typ, mods uint32) {
+	for {
+		i := bytes.IndexByte(l.src[l.lineStart:from], '\n')
+		if i < 0 {
+			break
+		}
+		l.line++
+		l.lineStart += uint(i) + 1
+	}
+	// Split at line breaks, leaving them out of the tokens.
+	for from < to {
+		end := to
+		if i := bytes.IndexByte(l.src[from:to], '\n'); i >= 0 {
+			end = from + uint(i)
+		}
+		if text := bytes.TrimSuffix(l.src[from:end], []byte("\r")); len(text) > 0 {
+			t := token{
+				line:   uint32(l.line),
+				char:   utf16Len(l.src[l.lineStart:from]),
+				length: utf16Len(text),
+				typ:    typ,
+				mods:   mods,
+			}
+			// Join the pieces of a capture that was split around a child.
+			if n := len(l.out); n > 0 && l.out[n-1].joins(t) {
+				l.out[n-1].length += t.length
+			} else {
+				l.out = append(l.out, t)
+			}
+		}
+		if end == to {
+			break
+		}
+		l.line++
+		l.lineStart = end + 1
+		from = end + 1
+	}
+}
+
+// encode delta-encodes toks.
+func encode(toks []token) []uint32 {
+	data := make([]uint32, 0, 5*len(toks))
+	var line, char uint32
+	for _, t := range toks {
+		if t.line != line {
+			char = 0
+		}
+		data = append(data, t.line-line, t.char-char, t.length, t.typ, t.mods)
+		line, char = t.line, t.char
+	}
+	return data
+}
+
+// utf16Len returns the length of b in UTF-16 code units.
+func utf16Len(b []byte) uint32 {
+	var n uint32
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
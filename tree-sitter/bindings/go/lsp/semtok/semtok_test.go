@@ -0,0 +1,141 @@
+package semtok_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/satler-git/sand-markup/bindings/go/lsp/semtok"
+)
+
+const doc = "#(en, ja)\n\n#s[😀日本]\n#a# 見出し\n#t[x\r\ny]\n"
+
+// decode turns semantic token data back into "line:char type text" using
+// UTF-16 offsets into the lines of src.
+func decode(t *testing.T, src string, legend semtok.Legend, data []uint32) []string {
+	t.Helper()
+	if len(data)%5 != 0 {
+		t.Fatalf("data has %d values", len(data))
+	}
+	lines := strings.Split(src, "\n")
+	var out []string
+	var line, char uint32
+	for i := 0; i < len(data); i += 5 {
+		if data[i] > 0 {
+			char = 0
+		}
+		line += data[i]
+		char += data[i+1]
+		units := utf16.Encode([]rune(lines[line]))
+		text := string(utf16.Decode(units[char : char+data[i+2]]))
+		tok := legend.TokenTypes[data[i+3]]
+		for bit, m := range legend.TokenModifiers {
+			if data[i+4]&(1<<bit) != 0 {
+				tok += "." + m
+			}
+		}
+		out = append(out, fmt.Sprintf("%d:%d %s %s", line, char, tok, text))
+	}
+	return out
+}
+
+func TestEncode(t *testing.T) {
+	legend := semtok.DefaultLegend()
+	got := decode(t, doc, legend, semtok.Encode([]byte(doc), legend))
+	want := []string{
+		"0:0 keyword #(",
+		"0:2 variable en, ja",
+		"0:8 operator )",
+		"2:0 keyword #",
+		"2:1 variable s",
+		"2:2 operator [",
+		"2:3 string 😀日本",
+		"2:7 operator ]",
+		"3:0 keyword #",
+		"3:1 variable a",
+		"3:2 keyword #",
+		"4:0 keyword #",
+		"4:1 variable t",
+		"4:2 operator [",
+		"4:3 string x",
+		"5:0 string y",
+		"5:1 operator ]",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEncodeDeltas(t *testing.T) {
+	legend := semtok.DefaultLegend()
+	got := semtok.Encode([]byte("#s[😀]\n\n#t[€]"), legend)
+	want := []uint32{
+		0, 0, 1, 0, 0,
+		0, 1, 1, 2, 0,
+		0, 1, 1, 1, 0,
+		0, 1, 2, 3, 0, // the emoji is a surrogate pair
+		0, 2, 1, 1, 0,
+		2, 0, 1, 0, 0,
+		0, 1, 1, 2, 0,
+		0, 1, 1, 1, 0,
+		0, 1, 1, 3, 0,
+		0, 1, 1, 1, 0,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got  %v\nwant %v", got, want)
+	}
+}
+
+func TestLegend(t *testing.T) {
+	legend := semtok.Legend{
+		TokenTypes:     []string{"string", "variable"},
+		TokenModifiers: []string{"declaration", "readonly"},
+		Captures: map[string]semtok.Token{
+			"variable": {Type: "variable", Modifiers: []string{"readonly", "unknown"}},
+			"string":   {Type: "string"},
+			// Types outside the legend are dropped.
+			"punctuation": {Type: "operator"},
+		},
+	}
+	got := decode(t, doc, legend, semtok.Encode([]byte(doc), legend))
+	want := []string{
+		"0:2 variable.readonly en, ja",
+		"2:1 variable.readonly s",
+		"2:3 string 😀日本",
+		"3:1 variable.readonly a",
+		"4:1 variable.readonly t",
+		"4:3 string x",
+		"5:0 string y",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEncodeRange(t *testing.T) {
+	legend := semtok.DefaultLegend()
+	// From inside the emoji token to the start of line 4: tokens touching
+	// the range are kept whole and the first is relative to the document.
+	data := semtok.EncodeRange([]byte(doc), legend, semtok.Position{Line: 2, Character: 4}, semtok.Position{Line: 4, Character: 1})
+	got := decode(t, doc, legend, data)
+	want := []string{
+		"2:3 string 😀日本",
+		"2:7 operator ]",
+		"3:0 keyword #",
+		"3:1 variable a",
+		"3:2 keyword #",
+		"4:0 keyword #",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if data[0] != 2 || data[1] != 3 {
+		t.Errorf("first token at %d:%d, want it relative to 0:0", data[0], data[1])
+	}
+
+	if data := semtok.EncodeRange([]byte(doc), legend, semtok.Position{Line: 9}, semtok.Position{Line: 10}); len(data) != 0 {
+		t.Errorf("range past the end: %v", data)
+	}
+}
@@ -0,0 +1,110 @@
+package tree_sitter_sand
+
+import (
+	"github.com/satler-git/sand-markup/bindings/go/position"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// NodePath is what covers an offset in a tree, as returned by [NodeAt].
+type NodePath struct {
+	// Nodes are the named nodes covering the offset, from the source_file
+	// node down to the smallest one.
+	Nodes []*tree_sitter.Node
+	// Sections are the headings of the sections the offset is in, outermost
+	// first. A section node in the tree is only its heading line; the section
+	// runs on to the next heading of the same or a lower level, so these are
+	// usually not in Nodes.
+	Sections []*tree_sitter.Node
+}
+
+// NodeAt returns the path to the smallest named node covering offset.
+//
+// Nodes are end-exclusive: a node covers the offsets from its start byte up
+// to, but not including, its end byte. An offset between two siblings
+// belongs to the second one, and an offset at or past the end of the
+// document, or before its start, gives a path holding only the source_file
+// node.
+func NodeAt(tree *tree_sitter.Tree, offset uint) NodePath {
+	root := tree.RootNode()
+	path := NodePath{Nodes: []*tree_sitter.Node{root}}
+	if offset < root.StartByte() || offset >= root.EndByte() {
+		return path
+	}
+
+	cursor := tree.Walk()
+	defer cursor.Close()
+	for cursor.GotoFirstChildForByte(uint32(offset)) != nil {
+		node := cursor.Node()
+		if node.StartByte() > offset {
+			break
+		}
+		if node.IsNamed() {
+			path.Nodes = append(path.Nodes, node)
+		}
+	}
+
+	var levels []uint
+	for i := range root.NamedChildCount() {
+		heading := root.NamedChild(i)
+		if heading.StartByte() > offset {
+			break
+		}
+		if NodeKind(heading) != KindSection {
+			continue
+		}
+		hashes := heading.ChildByFieldName("hashes")
+		if hashes == nil {
+			continue
+		}
+		level := hashes.EndByte() - hashes.StartByte()
+		for len(levels) > 0 && levels[len(levels)-1] >= level {
+			levels = levels[:len(levels)-1]
+			path.Sections = path.Sections[:len(path.Sections)-1]
+		}
+		levels = append(levels, level)
+		path.Sections = append(path.Sections, heading)
+	}
+	return path
+}
+
+// NodeAtPosition is [NodeAt] for a line and column, counted in enc, of the
+// document x indexes.
+func NodeAtPosition(tree *tree_sitter.Tree, x *position.Index, line, col int, enc position.Encoding) NodePath {
+	return NodeAt(tree, uint(x.Offset(line, col, enc)))
+}
+
+// Leaf returns the smallest node of the path.
+func (p NodePath) Leaf() *tree_sitter.Node {
+	return p.Nodes[len(p.Nodes)-1]
+}
+
+// Kinds returns the kinds of p.Nodes, such as a breadcrumb shows.
+func (p NodePath) Kinds() []Kind {
+	kinds := make([]Kind, len(p.Nodes))
+	for i, node := range p.Nodes {
+		kinds[i] = NodeKind(node)
+	}
+	return kinds
+}
+
+// EnclosingSection returns the heading of the innermost section the offset
+// is in, or nil before the first heading.
+func (p NodePath) EnclosingSection() *tree_sitter.Node {
+	if len(p.Sections) == 0 {
+		return nil
+	}
+	return p.Sections[len(p.Sections)-1]
+}
+
+// EnclosingSpan returns the sentence definition, apply-all block or selector
+// covering the offset, or nil if it is in prose, a heading or a name
+// definition.
+func (p NodePath) EnclosingSpan() *tree_sitter.Node {
+	for _, node := range p.Nodes {
+		switch NodeKind(node) {
+		case KindSentenceDefinition, KindApplyAll, KindSelector:
+			return node
+		}
+	}
+	return nil
+}
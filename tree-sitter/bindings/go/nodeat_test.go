@@ -0,0 +1,81 @@
+package tree_sitter_sand_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/position"
+)
+
+func TestNodeAt(t *testing.T) {
+	src := []byte("#(en)\n\n#intro# A\n\n#s[hi]#t[yo]\n\n### B\n\ntext\n\n## C\n")
+	tree := cst(t, src)
+	defer tree.Close()
+	at := func(s string) uint { return uint(bytes.Index(src, []byte(s))) }
+
+	for _, test := range []struct {
+		name     string
+		offset   uint
+		kinds    []tree_sitter_sand.Kind
+		sections []string
+	}{
+		{"first byte", 0, []tree_sitter_sand.Kind{"source_file", "name_definition"}, nil},
+		{"identifier", at("en"), []tree_sitter_sand.Kind{"source_file", "name_definition", "identifier_list", "identifier"}, nil},
+		{"heading", at("A"), []tree_sitter_sand.Kind{"source_file", "section", "one_line_str"}, []string{"A"}},
+		{"content", at("hi"), []tree_sitter_sand.Kind{"source_file", "sentence_definition", "string"}, []string{"A"}},
+		{"last byte of sibling", at("#t") - 1, []tree_sitter_sand.Kind{"source_file", "sentence_definition"}, []string{"A"}},
+		{"between siblings", at("#t"), []tree_sitter_sand.Kind{"source_file", "sentence_definition"}, []string{"A"}},
+		{"nested section", at("text"), []tree_sitter_sand.Kind{"source_file", "non_escaped_string"}, []string{"A", "B"}},
+		{"sibling section", at("C"), []tree_sitter_sand.Kind{"source_file", "section", "one_line_str"}, []string{"C"}},
+		{"last byte", uint(len(src)) - 1, []tree_sitter_sand.Kind{"source_file", "section"}, []string{"C"}},
+		{"end of document", uint(len(src)), []tree_sitter_sand.Kind{"source_file"}, nil},
+		{"past end", uint(len(src)) + 10, []tree_sitter_sand.Kind{"source_file"}, nil},
+	} {
+		path := tree_sitter_sand.NodeAt(tree, test.offset)
+		if kinds := path.Kinds(); !slices.Equal(kinds, test.kinds) {
+			t.Errorf("%s: kinds = %v, want %v", test.name, kinds, test.kinds)
+		}
+		var sections []string
+		for _, s := range path.Sections {
+			title := s.ChildByFieldName("title")
+			sections = append(sections, string(bytes.TrimSpace(src[title.StartByte():title.EndByte()])))
+		}
+		if !slices.Equal(sections, test.sections) {
+			t.Errorf("%s: sections = %q, want %q", test.name, sections, test.sections)
+		}
+		if leaf := path.Leaf(); leaf.StartByte() > test.offset || (leaf.EndByte() <= test.offset && len(path.Nodes) > 1) {
+			t.Errorf("%s: leaf %s [%d, %d) does not cover %d", test.name, leaf.Kind(), leaf.StartByte(), leaf.EndByte(), test.offset)
+		}
+	}
+
+	if s := tree_sitter_sand.NodeAt(tree, at("#t")).EnclosingSpan(); s == nil || s.StartByte() != at("#t") {
+		t.Errorf("EnclosingSpan between siblings = %v, want the second sentence", s)
+	}
+	if s := tree_sitter_sand.NodeAt(tree, at("text")).EnclosingSpan(); s != nil {
+		t.Errorf("EnclosingSpan in prose = %s, want nil", s.Kind())
+	}
+	if s := tree_sitter_sand.NodeAt(tree, at("text")).EnclosingSection(); s == nil || s.StartByte() != at("### B") {
+		t.Errorf("EnclosingSection = %v, want ### B", s)
+	}
+	if s := tree_sitter_sand.NodeAt(tree, 0).EnclosingSection(); s != nil {
+		t.Errorf("EnclosingSection before the first heading = %s, want nil", s.Kind())
+	}
+}
+
+func TestNodeAtPosition(t *testing.T) {
+	src := []byte("#(en)\n\n#s[日本]#t[語]\n")
+	tree := cst(t, src)
+	defer tree.Close()
+	x := position.NewIndex(src)
+
+	for _, enc := range []position.Encoding{position.Bytes, position.Runes, position.UTF16} {
+		line, col := x.Position(bytes.Index(src, []byte("語")), enc)
+		path := tree_sitter_sand.NodeAtPosition(tree, x, line, col, enc)
+		leaf := path.Leaf()
+		if string(src[leaf.StartByte():leaf.EndByte()]) != "語" {
+			t.Errorf("encoding %d: leaf = %s %q", enc, leaf.Kind(), src[leaf.StartByte():leaf.EndByte()])
+		}
+	}
+}
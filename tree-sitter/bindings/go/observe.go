@@ -0,0 +1,134 @@
+package tree_sitter_sand
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/satler-git/sand-markup/bindings/go/internal/observe"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Observer is told when the stages of the pipeline start and end, to time
+// them and collect metrics per request. It is set in the options of
+// [ParseWithOptions], of the formatter and of the renderers, and on a
+// [Session] with [Session.SetObserver]. Without one, the stages cost nothing
+// extra.
+//
+// Every stage that starts ends exactly once, also when it fails or its
+// context is done. A stage may run inside another: formatting reports
+// [StageFormat] around the [StageParse] of the source. The methods are
+// called on the goroutine running the stage.
+type Observer interface {
+	// OnStageStart is called when a stage starts on a source of docBytes
+	// bytes.
+	OnStageStart(stage string, docBytes int)
+	// OnStageEnd is called when a stage ends, d after it started. The keys
+	// of meta are described by the Meta constants. The observer may keep
+	// meta.
+	OnStageEnd(stage string, d time.Duration, meta map[string]any)
+}
+
+// The stages reported to an [Observer].
+const (
+	// StageParse is parsing a source, and building the [Document] for the
+	// functions that return one.
+	StageParse = "parse"
+	// StageFormat is formatting a source, including its StageParse.
+	StageFormat = "format"
+	// StageRender is rendering a source, including its StageParse.
+	StageRender = "render"
+)
+
+// The keys of the metadata passed to [Observer.OnStageEnd].
+const (
+	// MetaError is the error the stage failed with. It is absent on
+	// success.
+	MetaError = observe.ErrorKey
+	// MetaNodes is the number of nodes in the tree, named or not, as an int.
+	// Set by StageParse.
+	MetaNodes = "nodes"
+	// MetaErrorNodes is the number of ERROR and MISSING nodes in the tree,
+	// as an int. Set by StageParse.
+	MetaErrorNodes = "error_nodes"
+	// MetaDepth is the depth of the tree, 1 for a lone root, as an int. Set
+	// by StageParse.
+	MetaDepth = "depth"
+	// MetaIncremental reports, as a bool, whether the parse reused a
+	// previous tree. Set by StageParse.
+	MetaIncremental = "incremental"
+	// MetaOutputBytes is the size of the output, as an int, when a
+	// StageFormat or StageRender produces one.
+	MetaOutputBytes = "output_bytes"
+)
+
+// NopObserver is an [Observer] that does nothing. It is not needed as a
+// default, since a nil Observer costs nothing, but can be embedded in an
+// observer that only implements one of the methods, or that forwards to a
+// tracing library.
+type NopObserver struct{}
+
+func (NopObserver) OnStageStart(string, int)                         {}
+func (NopObserver) OnStageEnd(string, time.Duration, map[string]any) {}
+
+// SlogObserver is an [Observer] that logs the stages to Logger: a debug
+// record when a stage starts, with its size, and a record at Level when it
+// ends, with its duration and metadata. A stage that fails ends at
+// [slog.LevelError] instead, unless Level is higher.
+type SlogObserver struct {
+	// Logger is where the records go, [slog.Default] if it is nil.
+	Logger *slog.Logger
+	// Level is the level of the records of stages that succeed.
+	Level slog.Level
+}
+
+func (o SlogObserver) logger() *slog.Logger {
+	if o.Logger == nil {
+		return slog.Default()
+	}
+	return o.Logger
+}
+
+func (o SlogObserver) OnStageStart(stage string, docBytes int) {
+	o.logger().LogAttrs(context.Background(), slog.LevelDebug, "sand: "+stage+" started",
+		slog.String("stage", stage), slog.Int("bytes", docBytes))
+}
+
+func (o SlogObserver) OnStageEnd(stage string, d time.Duration, meta map[string]any) {
+	level := o.Level
+	attrs := []slog.Attr{slog.String("stage", stage), slog.Duration("duration", d)}
+	for _, k := range slices.Sorted(maps.Keys(meta)) {
+		if k == MetaError {
+			level = max(level, slog.LevelError)
+		}
+		attrs = append(attrs, slog.Any(k, meta[k]))
+	}
+	o.logger().LogAttrs(context.Background(), level, "sand: "+stage+" done", attrs...)
+}
+
+// treeMeta adds the MetaNodes, MetaErrorNodes and MetaDepth of tree to m.
+func treeMeta(m map[string]any, tree *tree_sitter.Tree) {
+	nodes, errs, depth := 0, 0, 0
+	cursor := tree.Walk()
+	defer cursor.Close()
+	for d := 1; ; {
+		nodes++
+		depth = max(depth, d)
+		if node := cursor.Node(); node.IsError() || node.IsMissing() {
+			errs++
+		}
+		if cursor.GotoFirstChild() {
+			d++
+			continue
+		}
+		for !cursor.GotoNextSibling() {
+			if !cursor.GotoParent() {
+				m[MetaNodes], m[MetaErrorNodes], m[MetaDepth] = nodes, errs, depth
+				return
+			}
+			d--
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package tree_sitter_sand_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+// counter is an Observer that records the stages it is told about.
+type counter struct {
+	events []string
+	meta   []map[string]any
+}
+
+func (c *counter) OnStageStart(stage string, docBytes int) {
+	c.events = append(c.events, fmt.Sprintf("start %s %d", stage, docBytes))
+}
+
+func (c *counter) OnStageEnd(stage string, d time.Duration, meta map[string]any) {
+	c.events = append(c.events, "end "+stage)
+	c.meta = append(c.meta, meta)
+}
+
+func (c *counter) check(t *testing.T, want ...string) {
+	t.Helper()
+	if got := strings.Join(c.events, ", "); got != strings.Join(want, ", ") {
+		t.Errorf("got events %s, want %s", got, strings.Join(want, ", "))
+	}
+}
+
+func TestObserverParse(t *testing.T) {
+	for _, tt := range []struct {
+		src    string
+		errors bool
+	}{
+		{"#(en)\n\n## Title\n\n#s[a]\n", false},
+		{"#(en)\n\n#s[a\n", true},
+	} {
+		c := &counter{}
+		if _, err := tree_sitter_sand.ParseWithOptions([]byte(tt.src), tree_sitter_sand.ParseOptions{Observer: c}); err != nil {
+			t.Fatal(err)
+		}
+		c.check(t, fmt.Sprintf("start parse %d", len(tt.src)), "end parse")
+		meta := c.meta[0]
+		if nodes, _ := meta[tree_sitter_sand.MetaNodes].(int); nodes < 5 {
+			t.Errorf("%q: %d nodes", tt.src, nodes)
+		}
+		if depth, _ := meta[tree_sitter_sand.MetaDepth].(int); depth < 3 {
+			t.Errorf("%q: depth %d", tt.src, depth)
+		}
+		if errs, _ := meta[tree_sitter_sand.MetaErrorNodes].(int); (errs > 0) != tt.errors {
+			t.Errorf("%q: %d error nodes", tt.src, errs)
+		}
+		if meta[tree_sitter_sand.MetaIncremental] != false {
+			t.Errorf("%q: incremental is %v", tt.src, meta[tree_sitter_sand.MetaIncremental])
+		}
+		if err, ok := meta[tree_sitter_sand.MetaError]; ok {
+			t.Errorf("%q: error %v", tt.src, err)
+		}
+	}
+}
+
+func TestObserverParseFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		src  string
+		want error
+	}{
+		{"invalid UTF-8", context.Background(), "#(en)\n\xff\n", tree_sitter_sand.ErrInvalidUTF8},
+		{"canceled", ctx, "#(en)\n", context.Canceled},
+	} {
+		c := &counter{}
+		_, err := tree_sitter_sand.ParseContextWithOptions(tt.ctx, []byte(tt.src), tree_sitter_sand.ParseOptions{Observer: c})
+		if !errors.Is(err, tt.want) {
+			t.Fatalf("%s: got error %v", tt.name, err)
+		}
+		c.check(t, fmt.Sprintf("start parse %d", len(tt.src)), "end parse")
+		if got := c.meta[0][tree_sitter_sand.MetaError]; got != err {
+			t.Errorf("%s: observed error %v, want %v", tt.name, got, err)
+		}
+		if _, ok := c.meta[0][tree_sitter_sand.MetaNodes]; ok {
+			t.Errorf("%s: a failed parse reports nodes", tt.name)
+		}
+	}
+}
+
+func TestObserverSession(t *testing.T) {
+	s := newSession(t)
+	c := &counter{}
+	s.SetObserver(c)
+
+	src := []byte("#(en)\n\n#s[a]\n")
+	s.Parse(src)
+	edited, edit := insert(src, 10, "b")
+	s.ApplyEdit(edit, edited)
+	c.check(t, "start parse 13", "end parse", "start parse 14", "end parse")
+	for i, want := range []bool{false, true} {
+		if got := c.meta[i][tree_sitter_sand.MetaIncremental]; got != want {
+			t.Errorf("parse %d: incremental is %v, want %v", i, got, want)
+		}
+	}
+
+	s.SetObserver(nil)
+	s.Parse(src)
+	if len(c.events) != 4 {
+		t.Errorf("the session reported to a removed observer: %v", c.events)
+	}
+}
+
+func TestSlogObserver(t *testing.T) {
+	var out bytes.Buffer
+	o := tree_sitter_sand.SlogObserver{
+		Logger: slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+	tree_sitter_sand.ParseWithOptions([]byte("#(en)\n"), tree_sitter_sand.ParseOptions{Observer: o})
+	tree_sitter_sand.ParseWithOptions([]byte("\xff"), tree_sitter_sand.ParseOptions{Observer: o})
+
+	var records []string
+	for dec := json.NewDecoder(&out); dec.More(); {
+		var r map[string]any
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		delete(r, "time")
+		delete(r, "duration")
+		records = append(records, fmt.Sprint(r))
+	}
+	want := []string{
+		"map[bytes:6 level:DEBUG msg:sand: parse started stage:parse]",
+		"map[depth:4 error_nodes:0 incremental:false level:INFO msg:sand: parse done nodes:6 stage:parse]",
+		"map[bytes:1 level:DEBUG msg:sand: parse started stage:parse]",
+		"map[error:sand: invalid UTF-8 at byte 0 incremental:false level:ERROR msg:sand: parse done stage:parse]",
+	}
+	if !slices.Equal(records, want) {
+		t.Errorf("got records\n%s\nwant\n%s", strings.Join(records, "\n"), strings.Join(want, "\n"))
+	}
+}
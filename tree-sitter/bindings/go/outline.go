@@ -0,0 +1,48 @@
+package tree_sitter_sand
+
+// OutlineItem is a heading in the outline of a document.
+type OutlineItem struct {
+	Title string
+	Alias string
+	Level int
+	// Anchor is the id of the heading in HTML, as [SectionAnchors] gives
+	// it.
+	Anchor string
+	// Range spans the whole section and Heading only its heading line.
+	Range   Range
+	Heading Range
+	// Children are the headings nested in this section. A child's level
+	// may be more than one deeper, as with `###` directly under `##`.
+	Children []OutlineItem
+}
+
+// Outline returns the headings of src, nested the same way as the sections
+// of [Parse]. Titles are plain text with escapes resolved. Outline returns
+// nil if the document has no headings or cannot be parsed.
+func Outline(src []byte) []OutlineItem {
+	doc, err := parseLenient(src)
+	if err != nil {
+		return nil
+	}
+	return outline(doc.Children, SectionAnchors(doc))
+}
+
+func outline(nodes []Node, anchors map[*Section]string) []OutlineItem {
+	var items []OutlineItem
+	for _, node := range nodes {
+		s, ok := node.(*Section)
+		if !ok {
+			continue
+		}
+		items = append(items, OutlineItem{
+			Title:    s.Title,
+			Alias:    s.Alias,
+			Level:    s.Level,
+			Anchor:   anchors[s],
+			Range:    s.Range,
+			Heading:  s.Heading,
+			Children: outline(s.Children, anchors),
+		})
+	}
+	return items
+}
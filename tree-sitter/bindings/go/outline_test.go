@@ -0,0 +1,76 @@
+package tree_sitter_sand_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+// describe renders items as indented "level title" lines.
+func describe(items []tree_sitter_sand.OutlineItem, depth int) []string {
+	var lines []string
+	for _, item := range items {
+		lines = append(lines, fmt.Sprintf("%s%d %s", strings.Repeat("  ", depth), item.Level, item.Title))
+		lines = append(lines, describe(item.Children, depth+1)...)
+	}
+	return lines
+}
+
+func TestOutline(t *testing.T) {
+	src := `#(en)
+
+Prose before any heading.
+
+## One
+#a### Skipped to three
+### Two under one
+#[text]
+## Escaped \# title
+#b## Deep
+`
+	items := tree_sitter_sand.Outline([]byte(src))
+	got := describe(items, 0)
+	want := []string{
+		"1 One",
+		"  3 Skipped to three",
+		"  2 Two under one",
+		"1 Escaped # title",
+		"  2 Deep",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("outline:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	one := items[0]
+	if one.Children[0].Alias != "a" {
+		t.Errorf("alias = %q", one.Children[0].Alias)
+	}
+	if one.Anchor != "one" || one.Children[0].Anchor != "a" || items[1].Anchor != "escaped-title" {
+		t.Errorf("anchors = %q, %q, %q", one.Anchor, one.Children[0].Anchor, items[1].Anchor)
+	}
+	if got := src[one.Heading.StartByte:one.Heading.EndByte]; got != "## One" {
+		t.Errorf("heading = %q", got)
+	}
+	if got := src[one.Range.StartByte:one.Range.EndByte]; !strings.HasSuffix(got, "#[text]") {
+		t.Errorf("section One = %q, want it to end with its last paragraph", got)
+	}
+}
+
+func TestOutlineEmpty(t *testing.T) {
+	if items := tree_sitter_sand.Outline([]byte("#(en)\n\n#[no headings]\n")); items != nil {
+		t.Errorf("outline = %v, want nil", items)
+	}
+}
+
+func TestOutlineInvalidUTF8(t *testing.T) {
+	items := tree_sitter_sand.Outline([]byte(invalidDoc))
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+	if got := items[0]; got.Title != "Title \ufffd" || got.Heading.StartByte != 7 || got.Heading.EndByte != 17 {
+		t.Errorf("got %q at %d-%d", got.Title, got.Heading.StartByte, got.Heading.EndByte)
+	}
+}
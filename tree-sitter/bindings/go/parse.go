@@ -0,0 +1,680 @@
+package tree_sitter_sand
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/satler-git/sand-markup/bindings/go/internal/observe"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+var language = sync.OnceValue(func() *tree_sitter.Language {
+	return tree_sitter.NewLanguage(Language())
+})
+
+func newParser() (*tree_sitter.Parser, error) {
+	// SetLanguage would fail too, without saying which versions are
+	// supported.
+	if err := CheckCompatibility(language()); err != nil {
+		return nil, err
+	}
+	parser := tree_sitter.NewParser()
+	if err := parser.SetLanguage(language()); err != nil {
+		parser.Close()
+		return nil, err
+	}
+	return parser, nil
+}
+
+// readChunk bounds how much of the source is handed to the parser per read.
+// [tree_sitter.Parser.Parse] returns everything after the requested offset
+// and the binding copies it into C memory on every read, which is quadratic
+// in the document size.
+const readChunk = 16 << 10
+
+// parse is [tree_sitter.Parser.Parse] with bounded reads.
+func parse(parser *tree_sitter.Parser, src []byte, old *tree_sitter.Tree) *tree_sitter.Tree {
+	return parseWithOptions(parser, src, old, nil)
+}
+
+func parseWithOptions(parser *tree_sitter.Parser, src []byte, old *tree_sitter.Tree, opts *tree_sitter.ParseOptions) *tree_sitter.Tree {
+	return parser.ParseWithOptions(func(offset int, _ tree_sitter.Point) []byte {
+		if offset >= len(src) {
+			return nil
+		}
+		return src[offset:min(offset+readChunk, len(src))]
+	}, old, opts)
+}
+
+// ErrNoTree is returned when the parser gives no tree for a source without
+// having been cancelled or timed out, which only happens if it cannot be
+// used.
+var ErrNoTree = errors.New("sand: parser returned no tree")
+
+// ParseTree parses src into a tree-sitter tree. The caller must close it.
+func ParseTree(src []byte) (*tree_sitter.Tree, error) {
+	return ParseTreeContext(context.Background(), src)
+}
+
+// ParseTreeContext is like [ParseTree] but stops parsing when ctx is done.
+// The error then wraps ctx.Err(), and the partial tree has already been
+// freed.
+func ParseTreeContext(ctx context.Context, src []byte) (*tree_sitter.Tree, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("sand: parse: %w", err)
+	}
+	parser, err := newParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+
+	var opts *tree_sitter.ParseOptions
+	if ctx.Done() != nil {
+		opts = &tree_sitter.ParseOptions{
+			ProgressCallback: func(tree_sitter.ParseState) bool {
+				return ctx.Err() != nil
+			},
+		}
+	}
+	tree := parseWithOptions(parser, src, nil, opts)
+	if tree == nil {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("sand: parse: %w", err)
+		}
+		return nil, ErrNoTree
+	}
+	return tree, nil
+}
+
+// Parse parses src into a typed document tree.
+//
+// Syntax errors do not make Parse fail: they are reported in
+// [Document.Errors] and the rest of the document is still built. The error
+// result is only non-nil when src is not valid UTF-8, with an
+// [*InvalidUTF8Error], or when the parser itself cannot be used.
+func Parse(src []byte) (*Document, error) {
+	return ParseContext(context.Background(), src)
+}
+
+// ParseContext is like [Parse] but gives up when ctx is done, with an error
+// that wraps ctx.Err(). Only parsing is interrupted: building the document
+// afterwards is linear in the size of the tree.
+func ParseContext(ctx context.Context, src []byte) (*Document, error) {
+	return parseDocument(ctx, src, ParseOptions{})
+}
+
+// ParseOptions controls [ParseWithOptions].
+type ParseOptions struct {
+	// InvalidUTF8 is what to do with a source that is not valid UTF-8.
+	InvalidUTF8 InvalidUTF8Mode
+	// Observer, if not nil, is told about the [StageParse] of the source.
+	Observer Observer
+}
+
+// ParseWithOptions is like [Parse] with the handling of invalid UTF-8 set by
+// opts.
+//
+// Under [InvalidUTF8Replace], the ranges of the document are offsets into
+// the source after replacement, which [CheckUTF8] returns.
+// [Document.Replaced] lists the replacements, and [OriginalOffset] maps
+// offsets back to src.
+func ParseWithOptions(src []byte, opts ParseOptions) (*Document, error) {
+	return parseDocument(context.Background(), src, opts)
+}
+
+// ParseContextWithOptions is like [ParseWithOptions] but gives up when ctx
+// is done, as [ParseContext] does.
+func ParseContextWithOptions(ctx context.Context, src []byte, opts ParseOptions) (*Document, error) {
+	return parseDocument(ctx, src, opts)
+}
+
+func parseDocument(ctx context.Context, src []byte, opts ParseOptions) (doc *Document, err error) {
+	stage := observe.Start(opts.Observer, StageParse, len(src))
+	var tree *tree_sitter.Tree
+	defer func() {
+		stage.End(err, func(m map[string]any) {
+			if tree != nil {
+				treeMeta(m, tree)
+			}
+			m[MetaIncremental] = false
+		})
+		if tree != nil {
+			tree.Close()
+		}
+	}()
+
+	src, replaced, err := CheckUTF8(src, opts.InvalidUTF8)
+	if err != nil {
+		return nil, err
+	}
+	tree, err = ParseTreeContext(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	doc = buildDocument(tree.RootNode(), src)
+	doc.Replaced = replaced
+	return doc, nil
+}
+
+// ErrTimeout is returned by [ParseWithTimeout] when parsing takes too long.
+var ErrTimeout = errors.New("sand: parsing timed out")
+
+// ParseWithTimeout is like [Parse] but gives up with [ErrTimeout] if parsing
+// takes longer than d. A d of zero or less means no limit.
+//
+// Only parsing is bounded: building the document afterwards is linear in
+// the size of the tree.
+func ParseWithTimeout(src []byte, d time.Duration) (*Document, error) {
+	if d <= 0 {
+		return Parse(src)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	doc, err := ParseContext(ctx, src)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, ErrTimeout
+	}
+	return doc, err
+}
+
+// lineIndex maps byte offsets to tree-sitter points.
+type lineIndex []uint
+
+func newLineIndex(src []byte) lineIndex {
+	lines := lineIndex{0}
+	for i, c := range src {
+		if c == '\n' {
+			lines = append(lines, uint(i+1))
+		}
+	}
+	return lines
+}
+
+func (l lineIndex) point(offset uint) tree_sitter.Point {
+	row := sort.Search(len(l), func(i int) bool { return l[i] > offset }) - 1
+	return tree_sitter.Point{Row: uint(row), Column: offset - l[row]}
+}
+
+func (l lineIndex) rangeOf(start, end uint) Range {
+	return Range{StartByte: start, EndByte: end, StartPoint: l.point(start), EndPoint: l.point(end)}
+}
+
+type builder struct {
+	src   []byte
+	lines lineIndex
+	doc   *Document
+
+	sections []*Section
+	para     *Paragraph
+	// lastEnd is the end of the last inline span without trailing whitespace;
+	// a blank line between it and the next span starts a new paragraph.
+	lastEnd uint
+}
+
+func buildDocument(root *tree_sitter.Node, src []byte) *Document {
+	b := &builder{
+		src:   src,
+		lines: newLineIndex(src),
+		doc:   &Document{Range: root.Range()},
+	}
+
+	if root.HasError() {
+		b.collectErrors(root)
+	}
+
+	cursor := root.Walk()
+	children := root.NamedChildren(cursor)
+	cursor.Close()
+	// pos is the end of the source consumed so far. It lies inside the next
+	// child when recovering the previous construct (see recoverSentence), and
+	// before it when the whitespace after a construct is part of the construct's
+	// node or of no node at all; that whitespace still separates prose.
+	var pos uint
+	for i := range children {
+		node := &children[i]
+		var next *tree_sitter.Node
+		if i+1 < len(children) {
+			next = &children[i+1]
+		}
+
+		end := node.EndByte()
+		switch NodeKind(node) {
+		case KindNameDefinition:
+			b.flush()
+			if b.doc.Names == nil {
+				b.nameDefinition(node)
+			}
+		case KindSection:
+			b.flush()
+			b.section(node)
+		case KindSentenceDefinition:
+			var span *InlineSpan
+			span, end = b.sentence(node, next)
+			b.inline(span)
+		case KindApplyAll:
+			span := b.applyAll(node)
+			b.inline(span)
+			end = span.Range.EndByte
+		case KindSelector:
+			var span *InlineSpan
+			span, end = b.selector(node, next)
+			b.inline(span)
+		case KindNonEscapedString:
+			b.text(pos, end)
+		default:
+			b.flush()
+		}
+		pos = end
+	}
+	b.flush()
+
+	for _, child := range b.doc.Children {
+		if s, ok := child.(*Section); ok {
+			b.closeSection(s)
+		}
+	}
+
+	return b.doc
+}
+
+func (b *builder) collectErrors(root *tree_sitter.Node) {
+	walkErrors(root, func(node *tree_sitter.Node) {
+		if node.IsMissing() {
+			b.doc.Errors = append(b.doc.Errors, ParseError{Range: node.Range(), Missing: true, Expected: node.Kind()})
+		} else {
+			b.doc.Errors = append(b.doc.Errors, ParseError{Range: node.Range()})
+		}
+	})
+}
+
+// walkErrors calls fn for every MISSING node and every outermost ERROR node
+// below root, in document order.
+func walkErrors(root *tree_sitter.Node, fn func(node *tree_sitter.Node)) {
+	cursor := root.Walk()
+	defer cursor.Close()
+
+	for {
+		node := cursor.Node()
+		descend := true
+		switch {
+		case node.IsMissing(), node.IsError():
+			fn(node)
+			descend = false
+		case !node.HasError():
+			descend = false
+		}
+
+		if descend && cursor.GotoFirstChild() {
+			continue
+		}
+		for !cursor.GotoNextSibling() {
+			if !cursor.GotoParent() {
+				return
+			}
+		}
+	}
+}
+
+// children returns the node list the next block belongs to.
+func (b *builder) children() *[]Node {
+	if len(b.sections) == 0 {
+		return &b.doc.Children
+	}
+	return &b.sections[len(b.sections)-1].Children
+}
+
+func (b *builder) nameDefinition(node *tree_sitter.Node) {
+	b.doc.Names = []string{}
+	if list := node.ChildByFieldName("languages"); list != nil {
+		b.doc.Names = b.identifiers(list)
+	}
+	b.doc.NamesRange = b.trimmed(node.StartByte(), node.EndByte())
+}
+
+func (b *builder) section(node *tree_sitter.Node) {
+	s := &Section{}
+	if alias := node.ChildByFieldName("alias"); alias != nil {
+		s.Alias = b.textOf(alias)
+	}
+	if hashes := node.ChildByFieldName("hashes"); hashes != nil {
+		s.Level = int(hashes.EndByte() - hashes.StartByte())
+	}
+	end := node.EndByte()
+	if title := node.ChildByFieldName("title"); title != nil {
+		s.TitleRange = b.trimmed(title.StartByte(), title.EndByte())
+		s.Title = unescape(b.src[s.TitleRange.StartByte:s.TitleRange.EndByte])
+		end = title.EndByte()
+	}
+	s.Heading = b.trimmed(node.StartByte(), end)
+
+	for len(b.sections) > 0 && b.sections[len(b.sections)-1].Level >= s.Level {
+		b.sections = b.sections[:len(b.sections)-1]
+	}
+	children := b.children()
+	*children = append(*children, s)
+	b.sections = append(b.sections, s)
+}
+
+// closeSection fills in the ranges of s and its descendants once all of
+// their children are known.
+func (b *builder) closeSection(s *Section) {
+	end := s.Heading.EndByte
+	for _, child := range s.Children {
+		if sub, ok := child.(*Section); ok {
+			b.closeSection(sub)
+		}
+		end = max(end, child.Span().EndByte)
+	}
+	s.Range = b.lines.rangeOf(s.Heading.StartByte, end)
+}
+
+func (b *builder) sentence(node, next *tree_sitter.Node) (*InlineSpan, uint) {
+	span := &InlineSpan{Kind: SpanSentence}
+	if alias := node.ChildByFieldName("alias"); alias != nil {
+		span.Alias = b.textOf(alias)
+	}
+
+	end := node.StartByte()
+	for i := uint(0); i < node.ChildCount(); i++ {
+		child := node.Child(i)
+		switch {
+		case node.FieldNameForChild(uint32(i)) == "content":
+			span.Contents = append(span.Contents, b.content(child.StartByte(), child.EndByte()))
+		case NodeKind(child) == "]" && !child.IsMissing():
+			end = child.EndByte()
+		}
+	}
+	end = b.recoverSentence(span, end, next)
+
+	span.Range = b.lines.rangeOf(node.StartByte(), end)
+	return span, end
+}
+
+// recoverSentence parses the `[...]` groups following the first one.
+//
+// The generated parser ends sentence_definition at its first `]` because the
+// trailing /\s*/ token also matches the empty string, and the remaining groups
+// come out as prose. Until the parser is regenerated, they are read back from
+// the source following the pest grammar (src/sand.pest at the repository
+// root): groups may be separated by spaces or tabs, and each content runs to
+// the first unescaped `]`.
+func (b *builder) recoverSentence(span *InlineSpan, end uint, next *tree_sitter.Node) uint {
+	if next == nil || NodeKind(next) != KindNonEscapedString {
+		return end
+	}
+	limit := next.EndByte()
+	for {
+		p := end
+		for p < limit && (b.src[p] == ' ' || b.src[p] == '\t') {
+			p++
+		}
+		if p >= limit || b.src[p] != '[' {
+			return end
+		}
+		q := scanString(b.src[:limit], p+1)
+		if q == p+1 || q >= limit || b.src[q] != ']' {
+			return end
+		}
+		span.Contents = append(span.Contents, b.content(p+1, q))
+		end = q + 1
+	}
+}
+
+// scanString returns the end of the string content starting at start.
+func scanString(src []byte, start uint) uint {
+	p := start
+	for p < uint(len(src)) {
+		switch src[p] {
+		case ']', '}':
+			return p
+		case '\\':
+			if p+1 >= uint(len(src)) || !strings.ContainsRune(`]\}n/`, rune(src[p+1])) {
+				return p
+			}
+			p += 2
+		default:
+			p++
+		}
+	}
+	return p
+}
+
+func (b *builder) applyAll(node *tree_sitter.Node) *InlineSpan {
+	span := &InlineSpan{Kind: SpanApplyAll}
+	if alias := node.ChildByFieldName("alias"); alias != nil {
+		span.Alias = b.textOf(alias)
+	}
+	for i := uint(0); i < node.NamedChildCount(); i++ {
+		if child := node.NamedChild(i); NodeKind(child) == KindIdentifierList {
+			span.Targets = b.identifiers(child)
+		}
+	}
+	end := node.EndByte()
+	if content := node.ChildByFieldName("content"); content != nil {
+		span.Contents = []Content{b.content(content.StartByte(), content.EndByte())}
+	}
+	for i := uint(0); i < node.ChildCount(); i++ {
+		if child := node.Child(i); NodeKind(child) == "}" && !child.IsMissing() {
+			end = child.EndByte()
+		}
+	}
+	span.Range = b.trimmed(node.StartByte(), end)
+	return span
+}
+
+func (b *builder) selector(node, next *tree_sitter.Node) (*InlineSpan, uint) {
+	span := &InlineSpan{Kind: SpanSelector}
+	end := node.StartByte() + uint(len("#."))
+	for i := uint(0); i < node.ChildCount(); i++ {
+		child := node.Child(i)
+		switch NodeKind(child) {
+		case "/":
+			span.Local = true
+		case "identifier":
+			span.Path = append(span.Path, b.textOf(child))
+		case ".":
+			span.TrailingDot = i+1 == node.ChildCount() || NodeKind(node.Child(i+1)) != "identifier"
+		default:
+			continue
+		}
+		end = child.EndByte()
+	}
+	if end == node.EndByte() {
+		end = b.recoverSelector(span, end, next)
+	}
+
+	span.Range = b.lines.rangeOf(node.StartByte(), end)
+	return span, end
+}
+
+// recoverSelector parses the path of a selector the generated parser ended
+// right after `#.`, for the same reason as in recoverSentence.
+func (b *builder) recoverSelector(span *InlineSpan, end uint, next *tree_sitter.Node) uint {
+	if next == nil || NodeKind(next) != KindNonEscapedString || next.StartByte() != end {
+		return end
+	}
+	src := b.src[:next.EndByte()]
+	p := end
+	if p < uint(len(src)) && src[p] == '/' {
+		span.Local = true
+		p++
+		end = p
+	}
+	for {
+		q := p
+		for q < uint(len(src)) && isIdentByte(src[q]) {
+			q++
+		}
+		if q == p {
+			return end
+		}
+		span.Path = append(span.Path, string(src[p:q]))
+		end = q
+		if q >= uint(len(src)) || src[q] != '.' {
+			return end
+		}
+		end = q + 1
+		if q+1 >= uint(len(src)) || !isIdentByte(src[q+1]) {
+			span.TrailingDot = true
+			return end
+		}
+		p = q + 1
+	}
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// text adds the prose in src[start:end], splitting it at blank lines.
+func (b *builder) text(start, end uint) {
+	for start < end {
+		chunkEnd := end
+		if i := blankLine(b.src[start:end]); i >= 0 {
+			chunkEnd = start + uint(i)
+		}
+		s := start
+		if b.para == nil || hasBlankLine(b.src[b.lastEnd:s]) {
+			s = skipSpace(b.src, s, chunkEnd)
+		}
+		// Whitespace on its own only matters between two spans.
+		if s < chunkEnd && (b.para != nil || trimSpace(b.src, s, chunkEnd) > s) {
+			b.inline(&InlineSpan{
+				Kind:  SpanText,
+				Range: b.lines.rangeOf(s, chunkEnd),
+				Text:  unescape(b.src[s:chunkEnd]),
+			})
+			b.lastEnd = trimSpace(b.src, s, chunkEnd)
+		}
+		start = skipSpace(b.src, chunkEnd, end)
+	}
+}
+
+// inline appends span to the current paragraph, starting a new one when a
+// blank line separates it from the previous span.
+func (b *builder) inline(span *InlineSpan) {
+	if b.para != nil && hasBlankLine(b.src[b.lastEnd:span.Range.StartByte]) {
+		b.flush()
+	}
+	if b.para == nil {
+		b.para = &Paragraph{}
+		children := b.children()
+		*children = append(*children, b.para)
+	}
+	b.para.Spans = append(b.para.Spans, span)
+	b.lastEnd = span.Range.EndByte
+}
+
+// flush ends the current paragraph, trimming whitespace after its last span.
+func (b *builder) flush() {
+	if b.para == nil {
+		return
+	}
+	for {
+		last := b.para.Spans[len(b.para.Spans)-1]
+		if last.Kind != SpanText {
+			break
+		}
+		end := trimSpace(b.src, last.Range.StartByte, last.Range.EndByte)
+		if end > last.Range.StartByte {
+			last.Range = b.lines.rangeOf(last.Range.StartByte, end)
+			last.Text = unescape(b.src[last.Range.StartByte:end])
+			break
+		}
+		b.para.Spans = b.para.Spans[:len(b.para.Spans)-1]
+	}
+	last := b.para.Spans[len(b.para.Spans)-1]
+	b.para.Range = b.lines.rangeOf(b.para.Spans[0].Range.StartByte, last.Range.EndByte)
+	b.para = nil
+}
+
+func (b *builder) content(start, end uint) Content {
+	return Content{Range: b.lines.rangeOf(start, end), Text: unescape(b.src[start:end])}
+}
+
+func (b *builder) identifiers(list *tree_sitter.Node) []string {
+	var names []string
+	for i := uint(0); i < list.NamedChildCount(); i++ {
+		names = append(names, b.textOf(list.NamedChild(i)))
+	}
+	return names
+}
+
+func (b *builder) textOf(node *tree_sitter.Node) string {
+	return string(NoCopyText(node, b.src))
+}
+
+// trimmed returns the range of src[start:end] without surrounding whitespace.
+func (b *builder) trimmed(start, end uint) Range {
+	start = skipSpace(b.src, start, end)
+	return b.lines.rangeOf(start, trimSpace(b.src, start, end))
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func skipSpace(src []byte, start, end uint) uint {
+	for start < end && isSpace(src[start]) {
+		start++
+	}
+	return start
+}
+
+func trimSpace(src []byte, start, end uint) uint {
+	for end > start && isSpace(src[end-1]) {
+		end--
+	}
+	return end
+}
+
+// blankLine returns the offset of the first line break that is followed by a
+// blank line in s, or -1.
+func blankLine(s []byte) int {
+	for i, c := range s {
+		if c != '\n' {
+			continue
+		}
+		for j := i + 1; j < len(s) && isSpace(s[j]); j++ {
+			if s[j] == '\n' {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func hasBlankLine(s []byte) bool {
+	return blankLine(s) >= 0
+}
+
+// unescape resolves the escape sequences of prose and string contents.
+func unescape(s []byte) string {
+	if !strings.ContainsRune(string(s), '\\') {
+		return string(s)
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch c := s[i+1]; c {
+			case 'n':
+				sb.WriteByte('\n')
+				i++
+				continue
+			case '#', '\\', '/', ']', '}':
+				sb.WriteByte(c)
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,268 @@
+package tree_sitter_sand_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+func corpus(t testing.TB) map[string][]byte {
+	t.Helper()
+
+	paths, err := filepath.Glob("testdata/*.sand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths = append(paths, "../../../README.sand")
+
+	files := map[string][]byte{}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[filepath.Base(path)] = src
+	}
+	return files
+}
+
+func mustParse(t testing.TB, src string) *tree_sitter_sand.Document {
+	t.Helper()
+
+	doc, err := tree_sitter_sand.Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func cst(t testing.TB, src []byte) *tree_sitter.Tree {
+	t.Helper()
+
+	parser := tree_sitter.NewParser()
+	defer parser.Close()
+	if err := parser.SetLanguage(tree_sitter.NewLanguage(tree_sitter_sand.Language())); err != nil {
+		t.Fatal(err)
+	}
+	return parser.Parse(src, nil)
+}
+
+// flatten returns the sections and spans of doc in document order.
+func flatten(doc *tree_sitter_sand.Document) (sections []*tree_sitter_sand.Section, spans []*tree_sitter_sand.InlineSpan) {
+	var walk func(nodes []tree_sitter_sand.Node)
+	walk = func(nodes []tree_sitter_sand.Node) {
+		for _, node := range nodes {
+			switch node := node.(type) {
+			case *tree_sitter_sand.Section:
+				sections = append(sections, node)
+				walk(node.Children)
+			case *tree_sitter_sand.Paragraph:
+				spans = append(spans, node.Spans...)
+			}
+		}
+	}
+	walk(doc.Children)
+	return sections, spans
+}
+
+func TestParseMatchesCST(t *testing.T) {
+	kinds := map[tree_sitter_sand.Kind]tree_sitter_sand.SpanKind{
+		tree_sitter_sand.KindSentenceDefinition: tree_sitter_sand.SpanSentence,
+		tree_sitter_sand.KindApplyAll:           tree_sitter_sand.SpanApplyAll,
+		tree_sitter_sand.KindSelector:           tree_sitter_sand.SpanSelector,
+	}
+
+	for name, src := range corpus(t) {
+		t.Run(name, func(t *testing.T) {
+			doc, err := tree_sitter_sand.Parse(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sections, spans := flatten(doc)
+
+			tree := cst(t, src)
+			defer tree.Close()
+			root := tree.RootNode()
+
+			var headings []uint
+			for i := uint(0); i < root.NamedChildCount(); i++ {
+				node := root.NamedChild(i)
+				switch kind := tree_sitter_sand.NodeKind(node); kind {
+				case tree_sitter_sand.KindSection:
+					headings = append(headings, node.StartByte())
+				case tree_sitter_sand.KindSentenceDefinition, tree_sitter_sand.KindApplyAll, tree_sitter_sand.KindSelector:
+					if !slices.ContainsFunc(spans, func(s *tree_sitter_sand.InlineSpan) bool {
+						return s.Kind == kinds[kind] && s.Range.StartByte == node.StartByte()
+					}) {
+						t.Errorf("%s at %d has no %v span", kind, node.StartByte(), kinds[kind])
+					}
+				}
+			}
+
+			if len(sections) != len(headings) {
+				t.Fatalf("got %d sections, want %d", len(sections), len(headings))
+			}
+			for i, s := range sections {
+				if s.Heading.StartByte != headings[i] {
+					t.Errorf("section %d starts at %d, want %d", i, s.Heading.StartByte, headings[i])
+				}
+			}
+
+			// Spans are ordered and every non-blank byte outside headings and
+			// the name definition belongs to one of them.
+			covered := make([]bool, len(src))
+			for i, s := range spans {
+				if i > 0 && s.Range.StartByte < spans[i-1].Range.EndByte {
+					t.Errorf("span %d at %d overlaps the previous one", i, s.Range.StartByte)
+				}
+				for p := s.Range.StartByte; p < s.Range.EndByte; p++ {
+					covered[p] = true
+				}
+			}
+			for _, s := range sections {
+				for p := s.Heading.StartByte; p < s.Heading.EndByte; p++ {
+					covered[p] = true
+				}
+			}
+			for p := doc.NamesRange.StartByte; p < doc.NamesRange.EndByte; p++ {
+				covered[p] = true
+			}
+			for _, e := range doc.Errors {
+				for p := e.Range.StartByte; p < e.Range.EndByte; p++ {
+					covered[p] = true
+				}
+			}
+			for p, c := range src {
+				if !covered[p] && c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+					t.Errorf("byte %d (%q) is not part of the typed tree", p, c)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestParseSections(t *testing.T) {
+	doc := mustParse(t, `#(en, ja)
+
+Before.
+
+#intro# Intro
+
+#a## A
+#b### B
+#c## C
+## Next
+`)
+
+	if !slices.Equal(doc.Names, []string{"en", "ja"}) {
+		t.Errorf("names = %v", doc.Names)
+	}
+	if len(doc.Children) != 3 {
+		t.Fatalf("got %d top-level nodes, want 3", len(doc.Children))
+	}
+	if _, ok := doc.Children[0].(*tree_sitter_sand.Paragraph); !ok {
+		t.Errorf("first node is %T, want a paragraph", doc.Children[0])
+	}
+
+	intro := doc.Children[1].(*tree_sitter_sand.Section)
+	if intro.Alias != "intro" || intro.Level != 1 || intro.Title != "Intro" {
+		t.Errorf("intro = %q level %d %q", intro.Alias, intro.Level, intro.Title)
+	}
+	if len(intro.Children) != 2 {
+		t.Fatalf("intro has %d children, want 2", len(intro.Children))
+	}
+	a := intro.Children[0].(*tree_sitter_sand.Section)
+	if a.Alias != "a" || a.Level != 2 || len(a.Children) != 1 {
+		t.Errorf("a = %q level %d with %d children", a.Alias, a.Level, len(a.Children))
+	}
+	if b := a.Children[0].(*tree_sitter_sand.Section); b.Title != "B" || b.Level != 3 {
+		t.Errorf("b = %q level %d", b.Title, b.Level)
+	}
+	if c := intro.Children[1].(*tree_sitter_sand.Section); c.Alias != "c" {
+		t.Errorf("second child of intro is %q, want c", c.Alias)
+	}
+	// `## Next` is the leading `#` followed by a single hash.
+	if next := doc.Children[2].(*tree_sitter_sand.Section); next.Title != "Next" || next.Level != 1 {
+		t.Errorf("next = %q level %d", next.Title, next.Level)
+	}
+	if intro.Range.EndByte != intro.Children[1].Span().EndByte {
+		t.Errorf("intro ends at %d, want the end of c", intro.Range.EndByte)
+	}
+}
+
+func TestParseInlineSpans(t *testing.T) {
+	doc := mustParse(t, "#(en, ja)\n#s1[Hello\\]][ja] and #{[ja], { x }} see #./s1.en or #.s1.\n")
+	para := doc.Children[0].(*tree_sitter_sand.Paragraph)
+
+	var kinds []tree_sitter_sand.SpanKind
+	for _, s := range para.Spans {
+		kinds = append(kinds, s.Kind)
+	}
+	want := []tree_sitter_sand.SpanKind{
+		tree_sitter_sand.SpanSentence, tree_sitter_sand.SpanText,
+		tree_sitter_sand.SpanApplyAll, tree_sitter_sand.SpanText,
+		tree_sitter_sand.SpanSelector, tree_sitter_sand.SpanText,
+		tree_sitter_sand.SpanSelector,
+	}
+	if !slices.Equal(kinds, want) {
+		t.Fatalf("kinds = %v, want %v", kinds, want)
+	}
+
+	sentence := para.Spans[0]
+	if sentence.Alias != "s1" || len(sentence.Contents) != 2 ||
+		sentence.Contents[0].Text != "Hello]" || sentence.Contents[1].Text != "ja" {
+		t.Errorf("sentence = %q %+v", sentence.Alias, sentence.Contents)
+	}
+	if para.Spans[1].Text != " and " {
+		t.Errorf("text = %q", para.Spans[1].Text)
+	}
+	if all := para.Spans[2]; !slices.Equal(all.Targets, []string{"ja"}) || all.Contents[0].Text != " x " {
+		t.Errorf("apply all = %v %+v", all.Targets, all.Contents)
+	}
+	if sel := para.Spans[4]; !sel.Local || !slices.Equal(sel.Path, []string{"s1", "en"}) || sel.TrailingDot {
+		t.Errorf("local selector = %v %v %v", sel.Local, sel.Path, sel.TrailingDot)
+	}
+	if sel := para.Spans[6]; sel.Local || !slices.Equal(sel.Path, []string{"s1"}) || !sel.TrailingDot {
+		t.Errorf("global selector = %v %v %v", sel.Local, sel.Path, sel.TrailingDot)
+	}
+}
+
+func TestParseParagraphs(t *testing.T) {
+	doc := mustParse(t, "one\ntwo\n\n  three \\# \n\n\n#[x]\nfour\n")
+	if len(doc.Children) != 3 {
+		t.Fatalf("got %d paragraphs, want 3", len(doc.Children))
+	}
+	texts := []string{"one\ntwo", "three #"}
+	for i, want := range texts {
+		para := doc.Children[i].(*tree_sitter_sand.Paragraph)
+		if got := para.Spans[0].Text; got != want {
+			t.Errorf("paragraph %d = %q, want %q", i, got, want)
+		}
+	}
+	last := doc.Children[2].(*tree_sitter_sand.Paragraph)
+	if len(last.Spans) != 2 || last.Spans[1].Text != "\nfour" {
+		t.Errorf("last paragraph = %+v", last.Spans)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	doc := mustParse(t, "#(en)\n\n## Broken\n\n#s[unterminated\n")
+	if len(doc.Errors) != 1 {
+		t.Fatalf("got %d errors, want 1", len(doc.Errors))
+	}
+	err := doc.Errors[0]
+	if err.Range.StartByte != 18 || err.Range.StartPoint.Row != 4 || err.Range.StartPoint.Column != 0 {
+		t.Errorf("error at %d (%v)", err.Range.StartByte, err.Range.StartPoint)
+	}
+	if got := err.Error(); got != "sand: 5:1: syntax error" {
+		t.Errorf("message = %q", got)
+	}
+	if len(doc.Children) != 1 {
+		t.Errorf("got %d children, want the section before the error", len(doc.Children))
+	}
+}
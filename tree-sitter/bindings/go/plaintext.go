@@ -0,0 +1,197 @@
+package tree_sitter_sand
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// TextOptions controls [PlainText].
+type TextOptions struct {
+	// Name selects the content of sentences and apply-all blocks to
+	// include. When it is empty, every content of a sentence is included.
+	Name string
+	// Prose includes the prose around sentences, which `sand out` leaves
+	// out.
+	Prose bool
+	// HeadingNewline starts every heading title on a new line. By default
+	// titles are separated from the surrounding text by a space like
+	// everything else.
+	HeadingNewline bool
+}
+
+// PlainText returns the text of src without markup: heading titles and the
+// contents of sentences and apply-all blocks, and prose if opts.Prose is set.
+// Escapes are resolved, runs of whitespace collapse to one space, and pieces
+// are separated by a space.
+func PlainText(src []byte, opts TextOptions) string {
+	text, _ := PlainTextWithMap(src, opts)
+	return text
+}
+
+// TextMap maps byte offsets in the output of [PlainTextWithMap] back to byte
+// offsets in the source.
+type TextMap struct {
+	segments []textSegment
+	srcEnd   int
+	// replaced lists the invalid UTF-8 the text was built without, which
+	// the offsets of segments do not count.
+	replaced []Replacement
+}
+
+// textSegment maps out[out:out+n]. If copied is set those bytes are
+// src[src:src+n]; otherwise they all stand for the source at src, as for an
+// escape sequence or a collapsed run of whitespace.
+type textSegment struct {
+	out, src, n int
+	copied      bool
+}
+
+// Source returns the source offset of the output byte at offset. Offsets
+// past the end of the output map to the end of the last piece of text.
+func (m *TextMap) Source(offset int) int {
+	return int(OriginalOffset(m.replaced, uint(m.source(offset))))
+}
+
+func (m *TextMap) source(offset int) int {
+	i := sort.Search(len(m.segments), func(i int) bool { return m.segments[i].out > offset }) - 1
+	if i < 0 {
+		if len(m.segments) == 0 {
+			return 0
+		}
+		return m.segments[0].src
+	}
+	seg := m.segments[i]
+	if offset >= seg.out+seg.n {
+		return m.srcEnd
+	}
+	if seg.copied {
+		return seg.src + offset - seg.out
+	}
+	return seg.src
+}
+
+// PlainTextWithMap is like [PlainText] and also returns the map from output
+// offsets to source offsets, for example to highlight search hits in the
+// original document. Invalid UTF-8 comes out as U+FFFD, and maps back to
+// the bytes it replaced.
+func PlainTextWithMap(src []byte, opts TextOptions) (string, *TextMap) {
+	src, replaced, err := CheckUTF8(src, InvalidUTF8Replace)
+	t := &textBuilder{src: src, m: &TextMap{replaced: replaced}}
+	if err != nil {
+		return "", t.m
+	}
+	doc, err := Parse(src)
+	if err != nil {
+		return "", t.m
+	}
+	sep := " "
+	if opts.HeadingNewline {
+		sep = "\n"
+	}
+	texts(doc, opts, func(r Range, _ *Section, heading bool) {
+		if heading {
+			t.piece(sep, r)
+		} else {
+			t.piece(" ", r)
+		}
+	})
+	return t.out.String(), t.m
+}
+
+// texts calls f with the range of every piece of text [PlainText] includes,
+// in document order, with the innermost section it is in. heading is set for
+// section titles.
+func texts(doc *Document, opts TextOptions, f func(r Range, section *Section, heading bool)) {
+	index := -1
+	if opts.Name != "" {
+		index = slices.Index(doc.Names, opts.Name)
+	}
+
+	var walk func(nodes []Node, section *Section)
+	walk = func(nodes []Node, section *Section) {
+		for _, node := range nodes {
+			switch node := node.(type) {
+			case *Section:
+				f(node.TitleRange, node, true)
+				walk(node.Children, node)
+			case *Paragraph:
+				for _, span := range node.Spans {
+					switch span.Kind {
+					case SpanText:
+						if opts.Prose {
+							f(span.Range, section, false)
+						}
+					case SpanSentence:
+						for i, c := range span.Contents {
+							if opts.Name == "" || i == index {
+								f(c.Range, section, false)
+							}
+						}
+					case SpanApplyAll:
+						if len(span.Contents) > 0 && (span.Targets == nil || opts.Name == "" || slices.Contains(span.Targets, opts.Name)) {
+							f(span.Contents[0].Range, section, false)
+						}
+					}
+				}
+			}
+		}
+	}
+	walk(doc.Children, nil)
+}
+
+type textBuilder struct {
+	src []byte
+	out strings.Builder
+	m   *TextMap
+}
+
+// add appends text, which stands for the srcLen source bytes at src.
+func (t *textBuilder) add(text string, src, srcLen int) {
+	copied := text == string(t.src[src:src+srcLen])
+	t.m.segments = append(t.m.segments, textSegment{out: t.out.Len(), src: src, n: len(text), copied: copied})
+	t.out.WriteString(text)
+	t.m.srcEnd = src + srcLen
+}
+
+// piece appends the text of r, preceded by sep unless it is the first piece.
+// Pieces that are only whitespace are skipped.
+func (t *textBuilder) piece(sep string, r Range) {
+	src := t.src[:r.EndByte]
+	i := int(r.StartByte)
+	for i < len(src) && isSpace(src[i]) {
+		i++
+	}
+	if i == len(src) {
+		return
+	}
+	if t.out.Len() > 0 {
+		t.add(sep, i, 0)
+	}
+
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case isSpace(c):
+			start := i
+			for i < len(src) && isSpace(src[i]) {
+				i++
+			}
+			if i < len(src) {
+				t.add(" ", start, i-start)
+			}
+		case c == '\\' && i+1 < len(src) && strings.IndexByte(`#\/]}n`, src[i+1]) >= 0:
+			if src[i+1] == 'n' {
+				t.add("\n", i, 2)
+			} else {
+				t.add(string(src[i+1]), i, 2)
+			}
+			i += 2
+		default:
+			start := i
+			for i++; i < len(src) && !isSpace(src[i]) && src[i] != '\\'; i++ {
+			}
+			t.add(string(src[start:i]), start, i-start)
+		}
+	}
+}
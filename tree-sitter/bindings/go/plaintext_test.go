@@ -0,0 +1,90 @@
+package tree_sitter_sand_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+const textDoc = `#(en, ja)
+
+#intro# はじめに \# 1
+
+Prose about #s[
+    Hello,   world!
+][
+    こんにちは、世界！
+] and #{[ja], {日本語\]だけ}} then #.intro.s.en.
+`
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		opts tree_sitter_sand.TextOptions
+		want string
+	}{
+		{tree_sitter_sand.TextOptions{}, "はじめに # 1 Hello, world! こんにちは、世界！ 日本語]だけ"},
+		{tree_sitter_sand.TextOptions{Name: "en"}, "はじめに # 1 Hello, world!"},
+		{tree_sitter_sand.TextOptions{Name: "ja", HeadingNewline: true}, "はじめに # 1 こんにちは、世界！ 日本語]だけ"},
+		{tree_sitter_sand.TextOptions{Name: "en", Prose: true}, "はじめに # 1 Prose about Hello, world! and then"},
+	}
+	for _, tt := range tests {
+		if got := tree_sitter_sand.PlainText([]byte(textDoc), tt.opts); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+
+	got := tree_sitter_sand.PlainText([]byte("## One\n#[x]\n## Two\n"), tree_sitter_sand.TextOptions{HeadingNewline: true})
+	if want := "One x\nTwo"; got != want {
+		t.Errorf("heading newline: got %q, want %q", got, want)
+	}
+}
+
+func TestPlainTextWithMap(t *testing.T) {
+	src := []byte(textDoc)
+	text, m := tree_sitter_sand.PlainTextWithMap(src, tree_sitter_sand.TextOptions{Prose: true})
+
+	// Every rune copied from the source maps back to the same rune.
+	for off, r := range text {
+		if r == ' ' || r == '#' || r == ']' {
+			continue
+		}
+		pos := m.Source(off)
+		got, _ := utf8.DecodeRune(src[pos:])
+		if got != r {
+			t.Errorf("output %d (%q) maps to source %d (%q)", off, r, pos, got)
+		}
+	}
+
+	// Escapes map to their backslash.
+	for _, tt := range []struct{ out, src string }{{"# 1", `\# 1`}, {"]だけ", `\]だけ`}} {
+		off := strings.Index(text, tt.out)
+		if pos := m.Source(off); !strings.HasPrefix(textDoc[pos:], tt.src) {
+			t.Errorf("%q maps to %q", tt.out, textDoc[pos:pos+len(tt.src)])
+		}
+	}
+
+	// A search hit maps to the original range, collapsed whitespace included.
+	hit := "Hello, world!"
+	start := strings.Index(text, hit)
+	from, to := m.Source(start), m.Source(start+len(hit)-1)+1
+	if got := textDoc[from:to]; got != "Hello,   world!" {
+		t.Errorf("hit maps to %q", got)
+	}
+
+	if end := m.Source(len(text)); textDoc[end-len("then"):end] != "then" {
+		t.Errorf("end of output maps to %d", end)
+	}
+}
+
+func TestPlainTextInvalidUTF8(t *testing.T) {
+	text, m := tree_sitter_sand.PlainTextWithMap([]byte(invalidDoc), tree_sitter_sand.TextOptions{Prose: true})
+	if want := "Title \ufffd Prose \ufffd. caf\ufffd Two x"; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+	// The "." after the replaced byte maps back past the single byte.
+	if got := m.Source(strings.Index(text, ".")); got != 26 {
+		t.Errorf("the period maps to %d, want 26", got)
+	}
+}
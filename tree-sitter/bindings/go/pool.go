@@ -0,0 +1,144 @@
+package tree_sitter_sand
+
+import tree_sitter "github.com/tree-sitter/go-tree-sitter"
+
+// PoolPolicy says what a [Pool] does when all of its parsers are in use.
+type PoolPolicy int
+
+const (
+	// PoolBlock makes [Pool.Get] wait until another goroutine puts a parser
+	// back.
+	PoolBlock PoolPolicy = iota
+	// PoolGrow makes [Pool.Get] create another parser. [Pool.Put] closes
+	// parsers that do not fit in the pool any more.
+	PoolGrow
+)
+
+// Pool shares sand parsers between goroutines. A tree-sitter parser must not
+// be used by two goroutines at once, and creating one for every document is
+// not free, so servers parsing many small documents should keep a pool.
+//
+// Parsers are created on demand and the pool keeps at most its size of them.
+type Pool struct {
+	policy PoolPolicy
+	idle   chan *tree_sitter.Parser
+	// slots holds a token for every open parser of a PoolBlock pool, so
+	// that it never creates more than its size.
+	slots chan struct{}
+}
+
+// NewPool returns a pool of at most size parsers that blocks when they are
+// all in use. A size below 1 is taken as 1.
+func NewPool(size int) *Pool {
+	return NewPoolWithPolicy(size, PoolBlock)
+}
+
+// NewPoolWithPolicy is like [NewPool] with the given policy for when the
+// parsers are all in use.
+func NewPoolWithPolicy(size int, policy PoolPolicy) *Pool {
+	size = max(size, 1)
+	return &Pool{
+		policy: policy,
+		idle:   make(chan *tree_sitter.Parser, size),
+		slots:  make(chan struct{}, size),
+	}
+}
+
+// Get checks out a parser. It must be given back with [Pool.Put] and must
+// not be used after that.
+func (p *Pool) Get() (*tree_sitter.Parser, error) {
+	select {
+	case parser := <-p.idle:
+		return parser, nil
+	default:
+	}
+
+	if p.policy == PoolGrow {
+		return newParser()
+	}
+	select {
+	case parser := <-p.idle:
+		return parser, nil
+	case p.slots <- struct{}{}:
+		parser, err := newParser()
+		if err != nil {
+			p.release()
+		}
+		return parser, err
+	}
+}
+
+// Put resets parser and gives it back to the pool.
+//
+// Everything a caller may have set on the parser is undone: the timeout,
+// the cancellation flag, the included ranges, the logger and any
+// half-finished parse left by a timeout or cancellation. A parser whose
+// language was changed is closed instead of reused.
+func (p *Pool) Put(parser *tree_sitter.Parser) {
+	if parser == nil {
+		return
+	}
+	if !resetParser(parser) {
+		parser.Close()
+		p.release()
+		return
+	}
+	select {
+	case p.idle <- parser:
+	default:
+		// Only PoolGrow gets here, with a parser created over the size.
+		parser.Close()
+	}
+}
+
+// release frees the slot of a parser that was closed.
+func (p *Pool) release() {
+	if p.policy == PoolBlock {
+		<-p.slots
+	}
+}
+
+// resetParser clears the per-use state of parser and reports whether it can
+// be used for sand again.
+func resetParser(parser *tree_sitter.Parser) bool {
+	if l := parser.Language(); l == nil || l.Inner != language().Inner {
+		return false
+	}
+	parser.Reset()
+	//lint:ignore SA1019 callers may still set these on a checked-out parser.
+	parser.SetTimeoutMicros(0)
+	//lint:ignore SA1019 callers may still set these on a checked-out parser.
+	parser.SetCancellationFlag(nil)
+	parser.SetLogger(nil)
+	return parser.SetIncludedRanges(nil) == nil
+}
+
+// Parse parses src with a parser from the pool. The caller must close the
+// returned tree.
+func (p *Pool) Parse(src []byte) (*tree_sitter.Tree, error) {
+	parser, err := p.Get()
+	if err != nil {
+		return nil, err
+	}
+	defer p.Put(parser)
+
+	tree := parse(parser, src, nil)
+	if tree == nil {
+		return nil, ErrNoTree
+	}
+	return tree, nil
+}
+
+// Close closes the parsers that are not checked out. The pool stays usable
+// and creates parsers again as needed.
+func (p *Pool) Close() {
+	for {
+		select {
+		case parser := <-p.idle:
+			parser.Close()
+			p.release()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package tree_sitter_sand_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestPoolConcurrent(t *testing.T) {
+	files := corpus(t)
+	want := map[string]string{}
+	for name, src := range files {
+		tree, err := tree_sitter_sand.ParseTree(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[name] = tree.RootNode().ToSexp()
+		tree.Close()
+	}
+
+	for _, policy := range []tree_sitter_sand.PoolPolicy{tree_sitter_sand.PoolBlock, tree_sitter_sand.PoolGrow} {
+		pool := tree_sitter_sand.NewPoolWithPolicy(4, policy)
+		var wg sync.WaitGroup
+		for range 100 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for name, src := range files {
+					tree, err := pool.Parse(src)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if got := tree.RootNode().ToSexp(); got != want[name] {
+						t.Errorf("policy %d, %s: got %s", policy, name, got)
+					}
+					tree.Close()
+				}
+			}()
+		}
+		wg.Wait()
+		pool.Close()
+	}
+}
+
+func TestPoolBlocks(t *testing.T) {
+	pool := tree_sitter_sand.NewPool(1)
+	defer pool.Close()
+
+	parser, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan *tree_sitter.Parser)
+	go func() {
+		p, _ := pool.Get()
+		got <- p
+	}()
+	select {
+	case <-got:
+		t.Fatal("Get did not block on an exhausted pool")
+	case <-time.After(50 * time.Millisecond):
+	}
+	pool.Put(parser)
+	if p := <-got; p != parser {
+		t.Error("Get did not return the parser put back")
+	}
+	pool.Put(parser)
+}
+
+func TestPoolResets(t *testing.T) {
+	pool := tree_sitter_sand.NewPool(1)
+	defer pool.Close()
+
+	parser, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flag := uintptr(1)
+	parser.SetCancellationFlag(&flag)
+	parser.SetTimeoutMicros(1)
+	if err := parser.SetIncludedRanges([]tree_sitter.Range{{StartByte: 0, EndByte: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(parser)
+
+	if parser, err = pool.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if parser.CancellationFlag() != nil || parser.TimeoutMicros() != 0 {
+		t.Error("cancellation flag or timeout survived Put")
+	}
+	if r := parser.IncludedRanges(); len(r) != 1 || r[0].StartByte != 0 || r[0].EndByte == 3 {
+		t.Errorf("included ranges survived Put: %+v", r)
+	}
+	pool.Put(parser)
+
+	src := []byte("#(en)\n\n#s[hello]\n")
+	tree, err := pool.Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	if end := tree.RootNode().EndByte(); end != uint(len(src)) || tree.RootNode().HasError() {
+		t.Errorf("tree ends at %d, want %d without errors: %s", end, len(src), tree.RootNode().ToSexp())
+	}
+}
+
+func TestPoolLanguageChanged(t *testing.T) {
+	pool := tree_sitter_sand.NewPool(1)
+	defer pool.Close()
+
+	parser, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser.Close()
+	pool.Put(tree_sitter.NewParser())
+
+	if parser, err = pool.Get(); err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Put(parser)
+	if parser.Language() == nil {
+		t.Error("pool reused a parser without the sand language")
+	}
+}
+
+func BenchmarkPool(b *testing.B) {
+	src := []byte("#(en, ja)\n\n#intro# Intro\n\n#s[Hello][こんにちは]\n")
+	b.Run("pool", func(b *testing.B) {
+		pool := tree_sitter_sand.NewPool(8)
+		defer pool.Close()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				tree, err := pool.Parse(src)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				tree.Close()
+			}
+		})
+	})
+	b.Run("new parser", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				tree, err := tree_sitter_sand.ParseTree(src)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				tree.Close()
+			}
+		})
+	})
+}
@@ -0,0 +1,155 @@
+// Package position converts between byte offsets in a document and line and
+// column positions.
+//
+// Lines are zero-based and end at "\n" or "\r\n"; a document ending with a
+// line break has an empty last line, as in tree-sitter and LSP. Columns are
+// zero-based and counted in one of three [Encoding]s: UTF-8 bytes, as in
+// [tree_sitter.Point], runes, or UTF-16 code units, as in LSP.
+//
+// All conversions clamp rather than fail: offsets before the start or past
+// the end of the document move to the start or end, lines past the last
+// line move to the end of the document, and columns past the end of a line
+// move to the end of it. An offset inside a "\r\n" or inside a multi-byte
+// rune (for runes and UTF-16) maps to where that line break or rune starts.
+package position
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Encoding is the unit columns are counted in.
+type Encoding int
+
+const (
+	// Bytes counts UTF-8 bytes, like tree-sitter.
+	Bytes Encoding = iota
+	// Runes counts Unicode code points. Bytes that are not valid UTF-8 count
+	// as one rune each.
+	Runes
+	// UTF16 counts UTF-16 code units, like LSP: runes outside the Basic
+	// Multilingual Plane count twice.
+	UTF16
+)
+
+// Index maps offsets in one document to positions. It keeps src, which must
+// not change while the index is used.
+type Index struct {
+	src   []byte
+	lines []int // offset of the start of every line
+}
+
+// NewIndex indexes the lines of src.
+func NewIndex(src []byte) *Index {
+	lines := []int{0}
+	for i, c := range src {
+		if c == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &Index{src: src, lines: lines}
+}
+
+// Lines returns the number of lines.
+func (x *Index) Lines() int {
+	return len(x.lines)
+}
+
+// lineEnd returns the offset where the text of line ends, before its line
+// break.
+func (x *Index) lineEnd(line int) int {
+	if line+1 == len(x.lines) {
+		return len(x.src)
+	}
+	end := x.lines[line+1] - 1
+	if end > x.lines[line] && x.src[end-1] == '\r' {
+		end--
+	}
+	return end
+}
+
+// Position returns the line and column of offset.
+func (x *Index) Position(offset int, enc Encoding) (line, col int) {
+	offset = min(max(offset, 0), len(x.src))
+	line = sort.SearchInts(x.lines, offset+1) - 1
+	start := x.lines[line]
+	offset = min(offset, x.lineEnd(line))
+	if enc == Bytes {
+		return line, offset - start
+	}
+	for i := start; i < offset; {
+		r, size := utf8.DecodeRune(x.src[i:])
+		if i+size > offset {
+			break
+		}
+		i += size
+		col += units(r, enc)
+	}
+	return line, col
+}
+
+// Offset returns the offset of the column col of line.
+func (x *Index) Offset(line, col int, enc Encoding) int {
+	if line < 0 {
+		return 0
+	}
+	if line >= len(x.lines) {
+		return len(x.src)
+	}
+	start, end := x.lines[line], x.lineEnd(line)
+	if col <= 0 {
+		return start
+	}
+	if enc == Bytes {
+		return min(start+col, end)
+	}
+	i := start
+	for i < end && col > 0 {
+		r, size := utf8.DecodeRune(x.src[i:end])
+		n := units(r, enc)
+		if n > col {
+			// A column in the middle of a surrogate pair.
+			break
+		}
+		col -= n
+		i += size
+	}
+	return i
+}
+
+func units(r rune, enc Encoding) int {
+	if enc == UTF16 && r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// Point returns the tree-sitter point of offset.
+func (x *Index) Point(offset int) tree_sitter.Point {
+	line, col := x.Position(offset, Bytes)
+	return tree_sitter.Point{Row: uint(line), Column: uint(col)}
+}
+
+// PointOffset returns the offset of a tree-sitter point.
+func (x *Index) PointOffset(p tree_sitter.Point) int {
+	return x.Offset(int(p.Row), int(p.Column), Bytes)
+}
+
+// Range returns the tree-sitter range from offset start to offset end.
+func (x *Index) Range(start, end int) tree_sitter.Range {
+	start = min(max(start, 0), len(x.src))
+	end = min(max(end, start), len(x.src))
+	return tree_sitter.Range{
+		StartByte:  uint(start),
+		EndByte:    uint(end),
+		StartPoint: x.Point(start),
+		EndPoint:   x.Point(end),
+	}
+}
+
+// Convert converts the column col of line from one encoding to another.
+func (x *Index) Convert(line, col int, from, to Encoding) (int, int) {
+	return x.Position(x.Offset(line, col, from), to)
+}
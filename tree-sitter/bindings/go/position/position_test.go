@@ -0,0 +1,142 @@
+package position_test
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/position"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+const doc = "#(en)\r\n\r\n#s[😀日本]\n\nend"
+
+func TestPosition(t *testing.T) {
+	x := position.NewIndex([]byte(doc))
+	if x.Lines() != 5 {
+		t.Errorf("Lines() = %d, want 5", x.Lines())
+	}
+	emoji := len("#(en)\r\n\r\n#s[")
+	after := emoji + len("😀日本")
+	tests := []struct {
+		offset int
+		enc    position.Encoding
+		line   int
+		col    int
+	}{
+		{0, position.Bytes, 0, 0},
+		{5, position.UTF16, 0, 5},
+		{6, position.Bytes, 0, 5}, // between "\r" and "\n"
+		{7, position.Bytes, 1, 0},
+		{emoji, position.UTF16, 2, 3},
+		{emoji + 1, position.UTF16, 2, 3}, // inside the emoji
+		{emoji + 4, position.Runes, 2, 4},
+		{emoji + 4, position.UTF16, 2, 5},
+		{after, position.Bytes, 2, 3 + len("😀日本")},
+		{after, position.Runes, 2, 6},
+		{after, position.UTF16, 2, 7},
+		{len(doc), position.UTF16, 4, 3},
+		{len(doc) + 10, position.Bytes, 4, 3},
+		{-1, position.Bytes, 0, 0},
+	}
+	for _, tt := range tests {
+		if line, col := x.Position(tt.offset, tt.enc); line != tt.line || col != tt.col {
+			t.Errorf("Position(%d, %d) = %d:%d, want %d:%d", tt.offset, tt.enc, line, col, tt.line, tt.col)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	x := position.NewIndex([]byte(doc))
+	emoji := len("#(en)\r\n\r\n#s[")
+	tests := []struct {
+		line, col int
+		enc       position.Encoding
+		offset    int
+	}{
+		{0, 99, position.UTF16, 5}, // clamped before "\r\n"
+		{1, 0, position.Bytes, 7},
+		{1, 3, position.Runes, 7},
+		{2, 4, position.UTF16, emoji}, // inside the surrogate pair
+		{2, 5, position.UTF16, emoji + 4},
+		{2, 4, position.Runes, emoji + 4},
+		{4, 3, position.Bytes, len(doc)},
+		{9, 0, position.Bytes, len(doc)},
+		{-1, 0, position.Bytes, 0},
+	}
+	for _, tt := range tests {
+		if got := x.Offset(tt.line, tt.col, tt.enc); got != tt.offset {
+			t.Errorf("Offset(%d, %d, %d) = %d, want %d", tt.line, tt.col, tt.enc, got, tt.offset)
+		}
+	}
+	if line, col := x.Convert(2, 5, position.UTF16, position.Runes); line != 2 || col != 4 {
+		t.Errorf("Convert = %d:%d, want 2:4", line, col)
+	}
+}
+
+// The points of the index agree with those of tree-sitter for every node.
+func TestPointMatchesTreeSitter(t *testing.T) {
+	src := []byte("#(en, ja)\n\n#a# 見出し\n#s[😀][x\ny]\nprose #.a.s.en\n")
+	tree, err := tree_sitter_sand.ParseTree(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	x := position.NewIndex(src)
+
+	var walk func(n *tree_sitter.Node)
+	walk = func(n *tree_sitter.Node) {
+		r := x.Range(int(n.StartByte()), int(n.EndByte()))
+		if r != n.Range() {
+			t.Errorf("%s: got %+v, want %+v", n.Kind(), r, n.Range())
+		}
+		if got := x.PointOffset(n.StartPosition()); got != int(n.StartByte()) {
+			t.Errorf("%s: PointOffset = %d, want %d", n.Kind(), got, n.StartByte())
+		}
+		for i := range n.ChildCount() {
+			walk(n.Child(i))
+		}
+	}
+	walk(tree.RootNode())
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add(doc)
+	f.Add("")
+	f.Add("\r\n\n\r")
+	f.Add("a\xffb\xe6\x97")
+	f.Add("𝄞x\r\n𝄞")
+	f.Fuzz(func(t *testing.T, src string) {
+		x := position.NewIndex([]byte(src))
+		for offset := 0; offset <= len(src); offset++ {
+			for _, enc := range []position.Encoding{position.Bytes, position.Runes, position.UTF16} {
+				line, col := x.Position(offset, enc)
+				got := x.Offset(line, col, enc)
+
+				want := offset
+				if offset > 0 && offset < len(src) && src[offset] == '\n' && src[offset-1] == '\r' {
+					want = offset - 1
+				} else if enc != position.Bytes {
+					for want > 0 && want < len(src) && !utf8.RuneStart(src[want]) && !isWhole(src, want) {
+						want--
+					}
+				}
+				if got != want {
+					t.Fatalf("%q: offset %d (enc %d) -> %d:%d -> %d, want %d", src, offset, enc, line, col, got, want)
+				}
+			}
+		}
+	})
+}
+
+// isWhole reports whether offset follows a complete rune (or an invalid byte,
+// which counts as one rune).
+func isWhole(src string, offset int) bool {
+	for start := offset - 1; start >= 0 && start >= offset-utf8.UTFMax; start-- {
+		if utf8.RuneStart(src[start]) {
+			_, size := utf8.DecodeRuneInString(src[start:])
+			return start+size <= offset
+		}
+	}
+	return true
+}
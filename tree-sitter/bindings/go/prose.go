@@ -0,0 +1,105 @@
+package tree_sitter_sand
+
+import "strings"
+
+// Context is the construct a prose [Region] was found in.
+type Context int
+
+const (
+	// ContextParagraph is prose around sentences, which `sand out` leaves
+	// out.
+	ContextParagraph Context = iota + 1
+	// ContextHeading is a section title.
+	ContextHeading
+	// ContextSentence is a content of a sentence definition.
+	ContextSentence
+	// ContextApplyAll is the content of an apply-all block.
+	ContextApplyAll
+)
+
+func (c Context) String() string {
+	switch c {
+	case ContextParagraph:
+		return "paragraph"
+	case ContextHeading:
+		return "heading"
+	case ContextSentence:
+		return "sentence"
+	case ContextApplyAll:
+		return "apply_all"
+	}
+	return "unknown"
+}
+
+// Region is a run of human prose in a document, as opposed to markup.
+type Region struct {
+	Range   Range
+	Context Context
+	// Name is the name a sentence content is written in, from the name
+	// definition. It is empty for other contexts and for contents past the
+	// defined names.
+	Name string
+}
+
+// ProseRegions returns the regions of src a spell checker should read, in
+// document order: heading titles, prose and the contents of sentences and
+// apply-all blocks. Markup is left out, such as name definitions, aliases,
+// selectors and brackets, and so are escape sequences, which split the text
+// around them. Regions don't start or end with whitespace.
+func ProseRegions(src []byte) []Region {
+	doc, err := parseLenient(src)
+	if err != nil {
+		return nil
+	}
+	lines := newLineIndex(src)
+	var regions []Region
+	add := func(r Range, ctx Context, name string) {
+		start := r.StartByte
+		emit := func(end uint) {
+			s := skipSpace(src, start, end)
+			if e := trimSpace(src, s, end); s < e {
+				regions = append(regions, Region{Range: lines.rangeOf(s, e), Context: ctx, Name: name})
+			}
+		}
+		for i := r.StartByte; i+1 < r.EndByte; i++ {
+			if src[i] == '\\' && strings.IndexByte(`n#\/]}`, src[i+1]) >= 0 {
+				emit(i)
+				i++
+				start = i + 1
+			}
+		}
+		emit(r.EndByte)
+	}
+
+	var walk func(nodes []Node)
+	walk = func(nodes []Node) {
+		for _, node := range nodes {
+			switch node := node.(type) {
+			case *Section:
+				add(node.TitleRange, ContextHeading, "")
+				walk(node.Children)
+			case *Paragraph:
+				for _, span := range node.Spans {
+					switch span.Kind {
+					case SpanText:
+						add(span.Range, ContextParagraph, "")
+					case SpanSentence:
+						for i, c := range span.Contents {
+							name := ""
+							if i < len(doc.Names) {
+								name = doc.Names[i]
+							}
+							add(c.Range, ContextSentence, name)
+						}
+					case SpanApplyAll:
+						for _, c := range span.Contents {
+							add(c.Range, ContextApplyAll, "")
+						}
+					}
+				}
+			}
+		}
+	}
+	walk(doc.Children)
+	return regions
+}
@@ -0,0 +1,61 @@
+package tree_sitter_sand_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+func TestProseRegions(t *testing.T) {
+	src := []byte("#(en, ja)\n\n#intro# Intro \\#1\n\nSee#.intro.s1.en,#s1[Hello][ こんにちは\n]and#{[ja], {\\\\x}}done\\n\n\n#[a][b][c]\n")
+	want := []string{
+		"19-24 heading  Intro",
+		"27-28 heading  1",
+		// Prose touching a selector and a sentence on both sides.
+		"30-33 paragraph  See",
+		"46-47 paragraph  ,",
+		"51-56 sentence en Hello",
+		"59-74 sentence ja こんにちは",
+		"76-79 paragraph  and",
+		"90-91 apply_all  x",
+		"93-97 paragraph  done",
+		"103-104 sentence en a",
+		"106-107 sentence ja b",
+		"109-110 sentence  c",
+	}
+	var got []string
+	for _, r := range tree_sitter_sand.ProseRegions(src) {
+		got = append(got, fmt.Sprintf("%d-%d %s %s %s", r.Range.StartByte, r.Range.EndByte, r.Context, r.Name, src[r.Range.StartByte:r.Range.EndByte]))
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestProseRegionsCorpus(t *testing.T) {
+	for name, src := range corpus(t) {
+		end := uint(0)
+		for _, r := range tree_sitter_sand.ProseRegions(src) {
+			text := src[r.Range.StartByte:r.Range.EndByte]
+			switch {
+			case r.Range.StartByte < end:
+				t.Errorf("%s: region at %d overlaps the one before", name, r.Range.StartByte)
+			case len(bytes.TrimSpace(text)) != len(text) || len(text) == 0:
+				t.Errorf("%s: region %q is not trimmed", name, text)
+			case bytes.IndexByte(text, '\\') >= 0,
+				r.Context == tree_sitter_sand.ContextParagraph && bytes.IndexByte(text, '#') >= 0:
+				t.Errorf("%s: region %q holds markup", name, text)
+			}
+			end = r.Range.EndByte
+		}
+	}
+}
+
+func TestProseRegionsInvalidUTF8(t *testing.T) {
+	regions := tree_sitter_sand.ProseRegions([]byte(invalidDoc))
+	if len(regions) != 5 || regions[1].Range.StartByte != 19 || regions[1].Range.EndByte != 27 {
+		t.Errorf("got %+v", regions)
+	}
+}
@@ -0,0 +1,29 @@
+package tree_sitter_sand
+
+import "embed"
+
+// queries holds a copy of queries/sand at the repository root; go:embed
+// cannot reach outside the package directory. queries_test.go keeps the two
+// in sync.
+//
+//go:embed queries
+var queries embed.FS
+
+func query(name string) string {
+	data, err := queries.ReadFile("queries/" + name)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// HighlightsQuery returns the contents of highlights.scm.
+func HighlightsQuery() string { return query("highlights.scm") }
+
+// InjectionsQuery returns the contents of injections.scm, or "" if the grammar
+// has none.
+func InjectionsQuery() string { return query("injections.scm") }
+
+// LocalsQuery returns the contents of locals.scm, or "" if the grammar has
+// none.
+func LocalsQuery() string { return query("locals.scm") }
@@ -0,0 +1,87 @@
+package tree_sitter_sand_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+var queryFiles = map[string]func() string{
+	"highlights.scm": tree_sitter_sand.HighlightsQuery,
+	"injections.scm": tree_sitter_sand.InjectionsQuery,
+	"locals.scm":     tree_sitter_sand.LocalsQuery,
+}
+
+func TestQueriesCompile(t *testing.T) {
+	language := tree_sitter.NewLanguage(tree_sitter_sand.Language())
+
+	for name, get := range queryFiles {
+		src := get()
+		if src == "" {
+			continue
+		}
+		query, err := tree_sitter.NewQuery(language, src)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		query.Close()
+	}
+
+	if tree_sitter_sand.HighlightsQuery() == "" {
+		t.Error("highlights.scm is not embedded")
+	}
+}
+
+func TestQueriesMatchRepository(t *testing.T) {
+	for name, get := range queryFiles {
+		want, err := os.ReadFile(filepath.Join("../../../queries/sand", name))
+		if os.IsNotExist(err) {
+			want = nil
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if got := get(); got != string(want) {
+			t.Errorf("queries/%s differs from queries/sand/%s at the repository root", name, name)
+		}
+	}
+}
+
+func TestHighlightsPunctuation(t *testing.T) {
+	src := []byte("#(en, ja)\n\n#s## Title\n\n#a[x][y] #{[en], {z}} #./s.a\n")
+	tree, err := tree_sitter_sand.ParseTree(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+
+	language := tree_sitter.NewLanguage(tree_sitter_sand.Language())
+	query, qerr := tree_sitter.NewQuery(language, tree_sitter_sand.HighlightsQuery())
+	if qerr != nil {
+		t.Fatal(qerr)
+	}
+	defer query.Close()
+	cursor := tree_sitter.NewQueryCursor()
+	defer cursor.Close()
+
+	// Punctuation is captured on the tokens, not on the nodes holding them.
+	names := query.CaptureNames()
+	matches := cursor.Matches(query, tree.RootNode(), src)
+	for m := matches.Next(); m != nil; m = matches.Next() {
+		for _, c := range m.Captures {
+			name := names[c.Index]
+			if !strings.HasPrefix(name, "punctuation") {
+				continue
+			}
+			text := string(src[c.Node.StartByte():c.Node.EndByte()])
+			if !slices.Contains([]string{"#(", ")", "#", "##", "[", "]", "{", "}", ",", "#.", "/", "."}, text) {
+				t.Errorf("@%s captures %q", name, text)
+			}
+		}
+	}
+}
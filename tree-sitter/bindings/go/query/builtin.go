@@ -0,0 +1,199 @@
+package query
+
+import (
+	"context"
+	"iter"
+	"sync"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Heading is a section heading: `#alias## Title`.
+type Heading struct {
+	// Range spans the heading line without its line break.
+	Range tree_sitter_sand.Range
+	Alias string
+	Level int
+	// Title is the title as written, escapes included, without the leading
+	// and trailing whitespace.
+	Title      string
+	TitleRange tree_sitter_sand.Range
+}
+
+// NameDefinition is a name definition: `#(en, ja)`.
+type NameDefinition struct {
+	Range tree_sitter_sand.Range
+	Names []Capture
+}
+
+// ApplyAllBlock is an apply-all block: `#alias{[en], {content}}`.
+type ApplyAllBlock struct {
+	Range tree_sitter_sand.Range
+	Alias string
+	// Targets lists the names the block is restricted to. It is nil when
+	// the block applies to all names.
+	Targets []Capture
+	// Content is the content as written, escapes included.
+	Content Capture
+}
+
+// precompiled returns a function compiling pattern on first use. Compiled
+// queries are never closed; there is one per pattern for the life of the
+// program.
+func precompiled(pattern string) func() *Query {
+	return sync.OnceValue(func() *Query {
+		q, err := Compile(pattern)
+		if err != nil {
+			panic("sand: invalid built-in query: " + err.Error())
+		}
+		return q
+	})
+}
+
+var (
+	headingsQuery = precompiled(`
+(section
+  alias: (identifier)? @alias
+  hashes: (hashes) @hashes
+  title: (one_line_str) @title) @heading`)
+
+	nameDefinitionsQuery = precompiled(`
+(name_definition
+  languages: (identifier_list) @names) @definition`)
+
+	applyAllQuery = precompiled(`
+(apply_all
+  alias: (identifier)? @alias
+  (identifier_list)? @targets
+  content: (string) @content) @apply_all`)
+)
+
+// Headings yields the headings of src in document order.
+func Headings(src []byte) iter.Seq[Heading] {
+	q := headingsQuery()
+	return func(yield func(Heading) bool) {
+		q.each(context.Background(), src, func(m *tree_sitter.QueryMatch) bool {
+			var h Heading
+			var start uint
+			for _, c := range m.Captures {
+				switch q.q.CaptureNames()[c.Index] {
+				case "heading":
+					start = c.Node.StartByte()
+					h.Range = c.Node.Range()
+				case "alias":
+					h.Alias = c.Node.Utf8Text(src)
+				case "hashes":
+					h.Level = int(c.Node.EndByte() - c.Node.StartByte())
+				case "title":
+					h.Title, h.TitleRange = trimmed(src, c.Node.Range())
+				}
+			}
+			h.Range = span(src, h.Range, start, h.TitleRange.EndByte)
+			return yield(h)
+		})
+	}
+}
+
+// NameDefinitions yields the name definitions of src in document order.
+func NameDefinitions(src []byte) iter.Seq[NameDefinition] {
+	q := nameDefinitionsQuery()
+	return func(yield func(NameDefinition) bool) {
+		q.each(context.Background(), src, func(m *tree_sitter.QueryMatch) bool {
+			var d NameDefinition
+			for _, c := range m.Captures {
+				switch q.q.CaptureNames()[c.Index] {
+				case "definition":
+					_, d.Range = trimmed(src, c.Node.Range())
+				case "names":
+					d.Names = identifiers(&c.Node, src)
+				}
+			}
+			return yield(d)
+		})
+	}
+}
+
+// ApplyAll yields the apply-all blocks of src in document order.
+func ApplyAll(src []byte) iter.Seq[ApplyAllBlock] {
+	q := applyAllQuery()
+	return func(yield func(ApplyAllBlock) bool) {
+		q.each(context.Background(), src, func(m *tree_sitter.QueryMatch) bool {
+			var b ApplyAllBlock
+			for _, c := range m.Captures {
+				switch q.q.CaptureNames()[c.Index] {
+				case "apply_all":
+					b.Range = blockRange(&c.Node, src)
+				case "alias":
+					b.Alias = c.Node.Utf8Text(src)
+				case "targets":
+					b.Targets = identifiers(&c.Node, src)
+				case "content":
+					b.Content = Capture{Name: "content", Text: c.Node.Utf8Text(src), Range: c.Node.Range()}
+				}
+			}
+			return yield(b)
+		})
+	}
+}
+
+// blockRange returns the range of an apply_all node up to its last closing
+// brace, leaving out the trailing whitespace the node includes.
+func blockRange(node *tree_sitter.Node, src []byte) tree_sitter_sand.Range {
+	for i := node.ChildCount(); i > 0; i-- {
+		if child := node.Child(i - 1); child.Kind() == "}" {
+			return span(src, node.Range(), node.StartByte(), child.EndByte())
+		}
+	}
+	return node.Range()
+}
+
+func identifiers(list *tree_sitter.Node, src []byte) []Capture {
+	var ids []Capture
+	for i := uint(0); i < list.NamedChildCount(); i++ {
+		id := list.NamedChild(i)
+		ids = append(ids, Capture{Name: "name", Text: id.Utf8Text(src), Range: id.Range()})
+	}
+	return ids
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// trimmed returns the text of r in src without surrounding whitespace, and
+// its range.
+func trimmed(src []byte, r tree_sitter_sand.Range) (string, tree_sitter_sand.Range) {
+	start, end := r.StartByte, r.EndByte
+	for start < end && isSpace(src[start]) {
+		start++
+	}
+	for end > start && isSpace(src[end-1]) {
+		end--
+	}
+	return string(src[start:end]), span(src, r, start, end)
+}
+
+// span returns the range from start to end, which lie at or after the start
+// of r.
+func span(src []byte, r tree_sitter_sand.Range, start, end uint) tree_sitter_sand.Range {
+	return tree_sitter_sand.Range{
+		StartByte:  start,
+		EndByte:    end,
+		StartPoint: advance(src, r.StartPoint, r.StartByte, start),
+		EndPoint:   advance(src, r.StartPoint, r.StartByte, end),
+	}
+}
+
+// advance returns the point of offset to, given that offset from is at p.
+func advance(src []byte, p tree_sitter.Point, from, to uint) tree_sitter.Point {
+	for _, c := range src[from:to] {
+		if c == '\n' {
+			p.Row++
+			p.Column = 0
+		} else {
+			p.Column++
+		}
+	}
+	return p
+}
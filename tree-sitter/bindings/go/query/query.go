@@ -0,0 +1,139 @@
+// Package query runs tree-sitter queries over Sand documents.
+//
+// [Compile] wraps [tree_sitter.NewQuery] for custom patterns, and [Headings],
+// [NameDefinitions] and [ApplyAll] run precompiled queries for common
+// constructs. All of them return Go 1.23 iterators that parse the document,
+// yield typed matches and release the tree when iteration stops.
+//
+// Queries see the tree-sitter tree, which records only the first bracket of a
+// sentence definition and only the `#.` of a selector. Use
+// [tree_sitter_sand.Parse] for those constructs.
+package query
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Query is a compiled query. It is safe for concurrent use.
+type Query struct {
+	q *tree_sitter.Query
+}
+
+// Capture is a node captured by a query.
+type Capture struct {
+	Name  string
+	Text  string
+	Range tree_sitter_sand.Range
+}
+
+// Match is one match of a query pattern.
+type Match struct {
+	// Pattern is the index of the matching pattern in the query source.
+	Pattern  uint
+	Captures []Capture
+}
+
+// Capture returns the first capture called name.
+func (m Match) Capture(name string) (Capture, bool) {
+	for _, c := range m.Captures {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Capture{}, false
+}
+
+// Compile compiles pattern for the Sand grammar. The caller must call
+// [Query.Close] when done with it.
+func Compile(pattern string) (*Query, error) {
+	language := tree_sitter.NewLanguage(tree_sitter_sand.Language())
+	q, err := tree_sitter.NewQuery(language, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{q: q}, nil
+}
+
+// Close releases the query.
+func (q *Query) Close() {
+	q.q.Close()
+}
+
+// Matches parses src and yields the matches of q in document order. It
+// yields nothing if src cannot be parsed.
+func (q *Query) Matches(src []byte) iter.Seq[Match] {
+	return func(yield func(Match) bool) {
+		q.each(context.Background(), src, func(m *tree_sitter.QueryMatch) bool {
+			return yield(q.match(m, src))
+		})
+	}
+}
+
+// MatchesContext is like [Query.Matches] but stops when ctx is done. If
+// parsing or matching is interrupted, or src cannot be parsed, the last pair
+// yielded has an empty match and the error, which wraps ctx.Err() on
+// cancellation.
+func (q *Query) MatchesContext(ctx context.Context, src []byte) iter.Seq2[Match, error] {
+	return func(yield func(Match, error) bool) {
+		err := q.each(ctx, src, func(m *tree_sitter.QueryMatch) bool {
+			return yield(q.match(m, src), nil)
+		})
+		if err != nil {
+			yield(Match{}, err)
+		}
+	}
+}
+
+// each calls fn for every raw match of q in src until it returns false. The
+// nodes of a match are only valid during the call.
+func (q *Query) each(ctx context.Context, src []byte, fn func(m *tree_sitter.QueryMatch) bool) error {
+	tree, err := tree_sitter_sand.ParseTreeContext(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer tree.Close()
+
+	cursor := tree_sitter.NewQueryCursor()
+	defer cursor.Close()
+
+	var matches tree_sitter.QueryMatches
+	if ctx.Done() != nil {
+		matches = cursor.MatchesWithOptions(q.q, tree.RootNode(), src, tree_sitter.QueryCursorOptions{
+			ProgressCallback: func(tree_sitter.QueryCursorState) bool {
+				return ctx.Err() != nil
+			},
+		})
+	} else {
+		matches = cursor.Matches(q.q, tree.RootNode(), src)
+	}
+	for m := matches.Next(); m != nil; m = matches.Next() {
+		if ctx.Err() != nil {
+			break
+		}
+		if !fn(m) {
+			return nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("sand: query: %w", err)
+	}
+	return nil
+}
+
+func (q *Query) match(m *tree_sitter.QueryMatch, src []byte) Match {
+	names := q.q.CaptureNames()
+	match := Match{Pattern: m.PatternIndex}
+	for _, c := range m.Captures {
+		match.Captures = append(match.Captures, Capture{
+			Name:  names[c.Index],
+			Text:  string(src[c.Node.StartByte():c.Node.EndByte()]),
+			Range: c.Node.Range(),
+		})
+	}
+	return match
+}
@@ -0,0 +1,208 @@
+package query_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/query"
+)
+
+func corpus(t *testing.T) map[string][]byte {
+	t.Helper()
+
+	paths, err := filepath.Glob("../testdata/*.sand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths = append(paths, "../../../../README.sand")
+
+	files := map[string][]byte{}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[filepath.Base(path)] = src
+	}
+	return files
+}
+
+func flatten(items []tree_sitter_sand.OutlineItem) []tree_sitter_sand.OutlineItem {
+	var all []tree_sitter_sand.OutlineItem
+	for _, item := range items {
+		all = append(all, item)
+		all = append(all, flatten(item.Children)...)
+	}
+	return all
+}
+
+func TestHeadingsMatchOutline(t *testing.T) {
+	for name, src := range corpus(t) {
+		want := flatten(tree_sitter_sand.Outline(src))
+		var got []query.Heading
+		for h := range query.Headings(src) {
+			got = append(got, h)
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: got %d headings, want %d", name, len(got), len(want))
+			continue
+		}
+		for i, h := range got {
+			w := want[i]
+			if h.Alias != w.Alias || h.Level != w.Level || h.Range != w.Heading || h.TitleRange.EndByte != w.Heading.EndByte {
+				t.Errorf("%s: heading %d = %+v, want %+v", name, i, h, w)
+			}
+		}
+	}
+}
+
+func TestHeadings(t *testing.T) {
+	src := []byte("#(en)\n\n#intro##  An \\# escaped title  \nbody\n")
+	var got []query.Heading
+	for h := range query.Headings(src) {
+		got = append(got, h)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d headings", len(got))
+	}
+	h := got[0]
+	if h.Alias != "intro" || h.Level != 2 || h.Title != `An \# escaped title` {
+		t.Errorf("heading = %+v", h)
+	}
+	if h.TitleRange.StartPoint.Row != 2 || h.TitleRange.StartPoint.Column != 10 || h.Range.EndPoint.Column != 29 {
+		t.Errorf("ranges = %+v %+v", h.Range, h.TitleRange)
+	}
+}
+
+func TestNameDefinitions(t *testing.T) {
+	src := []byte("#(en, ja)  \n\ntext\n#(fr)\n")
+	var got [][]string
+	for d := range query.NameDefinitions(src) {
+		var names []string
+		for _, n := range d.Names {
+			names = append(names, n.Text)
+		}
+		got = append(got, names)
+		if text := string(src[d.Range.StartByte:d.Range.EndByte]); text[len(text)-1] != ')' {
+			t.Errorf("definition range covers %q", text)
+		}
+	}
+	if len(got) != 2 || !slices.Equal(got[0], []string{"en", "ja"}) || !slices.Equal(got[1], []string{"fr"}) {
+		t.Errorf("names = %v", got)
+	}
+}
+
+func TestApplyAll(t *testing.T) {
+	src := []byte("#(en, ja)\n#a{[en, ja], { both }} #{all, {x}}  \n")
+	var got []query.ApplyAllBlock
+	for b := range query.ApplyAll(src) {
+		got = append(got, b)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d blocks", len(got))
+	}
+	if b := got[0]; b.Alias != "a" || len(b.Targets) != 2 || b.Targets[1].Text != "ja" || b.Content.Text != " both " {
+		t.Errorf("first block = %+v", b)
+	}
+	if b := got[1]; b.Targets != nil || b.Content.Text != "x" || string(src[b.Range.StartByte:b.Range.EndByte]) != "#{all, {x}}" {
+		t.Errorf("second block = %+v", b)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	q, err := query.Compile(`(sentence_definition alias: (identifier) @alias)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	var aliases []string
+	for m := range q.Matches([]byte("#a[x] #b[y] #[z]\n")) {
+		c, ok := m.Capture("alias")
+		if !ok {
+			t.Fatalf("match without alias: %+v", m)
+		}
+		aliases = append(aliases, c.Text)
+		// Stopping early must not leak or crash.
+		if len(aliases) == 2 {
+			break
+		}
+	}
+	if !slices.Equal(aliases, []string{"a", "b"}) {
+		t.Errorf("aliases = %v", aliases)
+	}
+
+	if _, err := query.Compile(`(no_such_node)`); err == nil {
+		t.Error("Compile accepted an unknown node kind")
+	}
+}
+
+func TestMatchesContext(t *testing.T) {
+	q, err := query.Compile(`(sentence_definition alias: (identifier) @alias)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	src := []byte("#a[x] #b[y] #c[z]\n")
+
+	n := 0
+	for _, err := range q.MatchesContext(context.Background(), src) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("got %d matches, want 3", n)
+	}
+
+	// Cancelling between matches ends the iteration with the error.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var texts []string
+	var last error
+	for m, err := range q.MatchesContext(ctx, src) {
+		if err != nil {
+			last = err
+			break
+		}
+		texts = append(texts, m.Captures[0].Text)
+		cancel()
+	}
+	if !errors.Is(last, context.Canceled) || !slices.Equal(texts, []string{"a"}) {
+		t.Errorf("got %q and %v, want the first match and context.Canceled", texts, last)
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	src := corpus(t)["sections.sand"]
+	want := 0
+	for range query.Headings(src) {
+		want++
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				n := 0
+				for range query.Headings(src) {
+					n++
+				}
+				if n != want {
+					t.Errorf("got %d headings, want %d", n, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
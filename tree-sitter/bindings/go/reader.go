@@ -0,0 +1,95 @@
+package tree_sitter_sand
+
+import (
+	"fmt"
+	"io"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// readWindow is how much input ParseReader keeps behind the furthest offset
+// read, for the parser to go back to. Sand tokens are short, so the lexer
+// never needs more than a line.
+const readWindow = 256 << 10
+
+// ParseReader parses the document read from r. The caller must close the
+// returned tree.
+//
+// ParseReader keeps at most readWindow bytes of input plus one chunk. The
+// tree does not hold the source, so reading node text needs the document
+// again. Note that go-tree-sitter also copies every chunk into C memory until
+// parsing ends, so peak memory still grows with the size of the input.
+//
+// If r fails, ParseReader returns the error and no tree.
+func ParseReader(r io.Reader) (*tree_sitter.Tree, error) {
+	parser, err := newParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+
+	in := &readerInput{r: r}
+	tree := parser.ParseWithOptions(func(offset int, _ tree_sitter.Point) []byte {
+		return in.at(offset)
+	}, nil, nil)
+	if in.err != nil {
+		if tree != nil {
+			tree.Close()
+		}
+		return nil, in.err
+	}
+	if tree == nil {
+		return nil, ErrNoTree
+	}
+	return tree, nil
+}
+
+// readerInput buffers the part of a reader the parser may still ask for.
+type readerInput struct {
+	r    io.Reader
+	buf  []byte
+	base int // offset of buf[0] in the input
+	eof  bool
+	err  error
+}
+
+// at returns the input from offset, at most readChunk bytes, reading more
+// from r as needed. It returns nil at the end of the input or after an error.
+func (in *readerInput) at(offset int) []byte {
+	if offset < in.base {
+		in.err = fmt.Errorf("sand: parser went back to offset %d, before the %d bytes ParseReader keeps", offset, readWindow)
+		return nil
+	}
+	for in.err == nil && !in.eof && offset+readChunk > in.base+len(in.buf) {
+		in.fill()
+	}
+	if in.err != nil || offset >= in.base+len(in.buf) {
+		return nil
+	}
+	chunk := in.buf[offset-in.base:]
+	return chunk[:min(len(chunk), readChunk)]
+}
+
+func (in *readerInput) fill() {
+	if cap(in.buf)-len(in.buf) < readChunk {
+		// Out of room: drop what lies before the window and make sure a
+		// whole chunk fits after the rest.
+		if drop := len(in.buf) - readWindow; drop > 0 {
+			in.buf = in.buf[:copy(in.buf, in.buf[drop:])]
+			in.base += drop
+		}
+		if cap(in.buf)-len(in.buf) < readChunk {
+			buf := make([]byte, len(in.buf), readWindow+2*readChunk)
+			copy(buf, in.buf)
+			in.buf = buf
+		}
+	}
+	n, err := in.r.Read(in.buf[len(in.buf):cap(in.buf)])
+	in.buf = in.buf[:len(in.buf)+n]
+	switch {
+	case err == io.EOF:
+		in.eof = true
+	case err != nil:
+		in.err = fmt.Errorf("sand: reading input at offset %d: %w", in.base+len(in.buf), err)
+	}
+}
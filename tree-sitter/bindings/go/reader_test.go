@@ -0,0 +1,58 @@
+package tree_sitter_sand_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+func TestParseReader(t *testing.T) {
+	files := corpus(t)
+	// Large enough to slide the window several times, with multi-byte text
+	// split at every possible offset by the one-byte reader.
+	files["generated"] = append(generate(600<<10), "#[日本語]\n"...)
+
+	for name, src := range files {
+		t.Run(name, func(t *testing.T) {
+			tree, err := tree_sitter_sand.ParseReader(iotest.OneByteReader(bytes.NewReader(src)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tree.Close()
+
+			want, err := tree_sitter_sand.ParseTree(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer want.Close()
+			if got, want := tree.RootNode().ToSexp(), want.RootNode().ToSexp(); got != want {
+				t.Errorf("tree differs from a whole-buffer parse:\n%s\nwant:\n%s", got, want)
+			}
+			if tree.RootNode().EndByte() != uint(len(src)) {
+				t.Errorf("tree ends at %d, want %d", tree.RootNode().EndByte(), len(src))
+			}
+		})
+	}
+}
+
+func TestParseReaderError(t *testing.T) {
+	errBroken := errors.New("broken pipe")
+	r := io.MultiReader(strings.NewReader("#(en)\n\n#[half"), iotest.ErrReader(errBroken))
+
+	tree, err := tree_sitter_sand.ParseReader(r)
+	if tree != nil {
+		tree.Close()
+		t.Error("ParseReader returned a tree for a failing reader")
+	}
+	if !errors.Is(err, errBroken) {
+		t.Fatalf("err = %v, want it to wrap %v", err, errBroken)
+	}
+	if want := "sand: reading input at offset 13: broken pipe"; err.Error() != want {
+		t.Errorf("err = %q, want %q", err, want)
+	}
+}
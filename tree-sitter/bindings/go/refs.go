@@ -0,0 +1,302 @@
+package tree_sitter_sand
+
+import (
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+)
+
+// Diagnostic codes reported by [ValidateRefs].
+const (
+	CodeUnresolvedAlias   = "unresolved-alias"
+	CodeIndexOutOfRange   = "index-out-of-range"
+	CodeLastNotName       = "last-not-name"
+	CodeDuplicateAlias    = "duplicate-alias"
+	CodeAliasConflictName = "alias-conflicts-with-name"
+	CodeUnusedAlias       = "unused-alias"
+	CodeAnchorRenamed     = "anchor-renamed"
+)
+
+// Link is a selector: a reference to a section, sentence or apply-all block
+// by the aliases and indexes on the way to it.
+type Link struct {
+	Range Range
+	// Target is the selector as written after `#.`, e.g. "/intro.hello.en".
+	Target      string
+	Local       bool
+	Path        []string
+	TrailingDot bool
+}
+
+// Links returns the selectors of src in document order. Escaped hashes in
+// prose (`\#.`) are not selectors.
+func Links(src []byte) []Link {
+	doc, err := parseLenient(src)
+	if err != nil {
+		return nil
+	}
+	var links []Link
+	for span := range selectors(doc.Children) {
+		links = append(links, link(src, span))
+	}
+	return links
+}
+
+func link(src []byte, span *InlineSpan) Link {
+	return Link{
+		Range:       span.Range,
+		Target:      string(src[span.Range.StartByte+2 : span.Range.EndByte]),
+		Local:       span.Local,
+		Path:        span.Path,
+		TrailingDot: span.TrailingDot,
+	}
+}
+
+// selectors yields the selector spans below nodes in document order.
+func selectors(nodes []Node) iter.Seq[*InlineSpan] {
+	return func(yield func(*InlineSpan) bool) {
+		var walk func(nodes []Node) bool
+		walk = func(nodes []Node) bool {
+			for _, node := range nodes {
+				switch node := node.(type) {
+				case *Section:
+					if !walk(node.Children) {
+						return false
+					}
+				case *Paragraph:
+					for _, span := range node.Spans {
+						if span.Kind == SpanSelector && !yield(span) {
+							return false
+						}
+					}
+				}
+			}
+			return true
+		}
+		walk(nodes)
+	}
+}
+
+// scope is the document or a section as selectors see it: the sentences,
+// apply-all blocks, selectors and subsections directly inside it, in order.
+// Prose does not count.
+type scope struct {
+	items   []Node
+	aliases map[string]int
+	// dups lists the items whose alias was already taken in this scope.
+	dups []int
+}
+
+func newScope(children []Node) *scope {
+	s := &scope{aliases: map[string]int{}}
+	add := func(node Node, alias string) {
+		if alias != "" {
+			if first, ok := s.aliases[alias]; ok {
+				if !slices.Contains(s.dups, first) {
+					s.dups = append(s.dups, first)
+				}
+				s.dups = append(s.dups, len(s.items))
+			} else {
+				s.aliases[alias] = len(s.items)
+			}
+		}
+		s.items = append(s.items, node)
+	}
+	for _, child := range children {
+		switch child := child.(type) {
+		case *Section:
+			add(child, child.Alias)
+		case *Paragraph:
+			for _, span := range child.Spans {
+				if span.Kind != SpanText {
+					add(span, span.Alias)
+				}
+			}
+		}
+	}
+	return s
+}
+
+// child looks key up as an alias, then as an index that skips selectors.
+func (s *scope) child(key string) (Node, string) {
+	if i, ok := s.aliases[key]; ok {
+		return s.items[i], ""
+	}
+	index, err := strconv.Atoi(key)
+	if err != nil || index < 0 {
+		return nil, CodeUnresolvedAlias
+	}
+	for _, item := range s.items {
+		if span, ok := item.(*InlineSpan); ok && span.Kind == SpanSelector {
+			continue
+		}
+		if index == 0 {
+			return item, ""
+		}
+		index--
+	}
+	return nil, CodeIndexOutOfRange
+}
+
+// refs resolves the selectors of a document.
+type refs struct {
+	doc    *Document
+	scopes map[Node]*scope
+	// used holds the nodes reached through an alias.
+	used map[Node]bool
+}
+
+func newRefs(doc *Document) *refs {
+	return &refs{doc: doc, scopes: map[Node]*scope{}, used: map[Node]bool{}}
+}
+
+func (r *refs) scope(node Node) *scope {
+	if s, ok := r.scopes[node]; ok {
+		return s
+	}
+	var children []Node
+	switch node := node.(type) {
+	case *Document:
+		children = node.Children
+	case *Section:
+		children = node.Children
+	}
+	s := newScope(children)
+	r.scopes[node] = s
+	return s
+}
+
+// resolve walks the path of sel from scope from, as `sand` does. It returns
+// the node reached and, if the walk failed, a diagnostic code and the
+// offending path element. If visit is not nil, it is called with the index
+// of every path element that is resolved as an alias, and the node reached.
+func (r *refs) resolve(sel *InlineSpan, from Node, visit func(i int, node Node)) (Node, string, string) {
+	path := sel.Path
+	if !sel.TrailingDot && len(path) > 0 {
+		last := path[len(path)-1]
+		if !slices.Contains(r.doc.Names, last) {
+			return nil, CodeLastNotName, last
+		}
+		path = path[:len(path)-1]
+	}
+
+	curr := from
+	for i, key := range path {
+		if span, ok := curr.(*InlineSpan); ok && span.Kind != SpanSelector {
+			// Sentences and apply-all blocks have no children; the rest
+			// of the path is ignored.
+			break
+		}
+		next, code := r.scope(curr).child(key)
+		if code != "" {
+			return nil, code, key
+		}
+		if _, ok := r.scope(curr).aliases[key]; ok {
+			r.used[next] = true
+			if visit != nil {
+				visit(i, next)
+			}
+		}
+		curr = next
+	}
+	return curr, "", ""
+}
+
+// ValidateRefs checks that every selector of src resolves, and reports
+// duplicated aliases, aliases that are also names and aliases no selector
+// goes through. Aliases are case-sensitive and may be used before they are
+// defined. It also warns about aliased headings whose [SectionAnchors]
+// anchor is not their alias.
+func ValidateRefs(src []byte) []Diagnostic {
+	doc, err := parseLenient(src)
+	if err != nil {
+		return nil
+	}
+	r := newRefs(doc)
+	var diags []Diagnostic
+	report := func(rng Range, severity Severity, code, msg string) {
+		diags = append(diags, Diagnostic{
+			Range:    rng,
+			Severity: severity,
+			Code:     code,
+			Message:  msg,
+			Source:   src[rng.StartByte:rng.EndByte],
+		})
+	}
+
+	var walk func(container Node, children []Node)
+	walk = func(container Node, children []Node) {
+		for _, child := range children {
+			switch child := child.(type) {
+			case *Section:
+				walk(child, child.Children)
+			case *Paragraph:
+				for _, span := range child.Spans {
+					if span.Kind != SpanSelector {
+						continue
+					}
+					from := Node(doc)
+					if span.Local {
+						from = container
+					}
+					switch _, code, key := r.resolve(span, from, nil); code {
+					case CodeLastNotName:
+						report(span.Range, SeverityError, code,
+							fmt.Sprintf("%q is not a name; end the selector with a name or \".\"", key))
+					case CodeUnresolvedAlias:
+						report(span.Range, SeverityError, code, fmt.Sprintf("no alias %q is defined here", key))
+					case CodeIndexOutOfRange:
+						report(span.Range, SeverityError, code, fmt.Sprintf("index %s is out of range", key))
+					}
+				}
+			}
+		}
+	}
+	walk(doc, doc.Children)
+
+	// Report definitions afterwards, scope by scope in document order.
+	anchors := SectionAnchors(doc)
+	var definitions func(container Node)
+	definitions = func(container Node) {
+		s := r.scope(container)
+		for i, item := range s.items {
+			alias, rng := aliasOf(item)
+			switch {
+			case alias == "":
+			case slices.Contains(s.dups, i):
+				report(rng, SeverityError, CodeDuplicateAlias, fmt.Sprintf("alias %q is defined more than once", alias))
+			case slices.Contains(doc.Names, alias):
+				report(rng, SeverityError, CodeAliasConflictName, fmt.Sprintf("alias %q is also a name", alias))
+			case !r.used[item]:
+				report(rng, SeverityHint, CodeUnusedAlias, fmt.Sprintf("alias %q is not used by any selector", alias))
+			}
+			if sub, ok := item.(*Section); ok {
+				// A section in another scope can take the alias first, which
+				// moves the heading's anchor in HTML.
+				if alias != "" && !slices.Contains(s.dups, i) && anchors[sub] != alias {
+					report(rng, SeverityWarning, CodeAnchorRenamed,
+						fmt.Sprintf("another section is anchored at %q, so this heading's anchor is %q", alias, anchors[sub]))
+				}
+				definitions(sub)
+			}
+		}
+	}
+	definitions(doc)
+
+	slices.SortStableFunc(diags, func(a, b Diagnostic) int {
+		return int(a.Range.StartByte) - int(b.Range.StartByte)
+	})
+	return diags
+}
+
+// aliasOf returns the alias of a scope item and the range to report it at.
+func aliasOf(node Node) (string, Range) {
+	switch node := node.(type) {
+	case *Section:
+		return node.Alias, node.Heading
+	case *InlineSpan:
+		return node.Alias, node.Range
+	}
+	return "", Range{}
+}
@@ -0,0 +1,118 @@
+package tree_sitter_sand_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+func TestLinks(t *testing.T) {
+	src := "#(en)\n\n#a# A\n\nSee #.a.s.en, #./s. and \\#.not.a.link\n\n#s[x]\n"
+	links := tree_sitter_sand.Links([]byte(src))
+	if len(links) != 2 {
+		t.Fatalf("got %d links, want 2: %+v", len(links), links)
+	}
+	if l := links[0]; l.Target != "a.s.en" || l.Local || !slices.Equal(l.Path, []string{"a", "s", "en"}) {
+		t.Errorf("first link = %+v", l)
+	}
+	if l := links[1]; l.Target != "/s." || !l.Local || !l.TrailingDot || src[l.Range.StartByte:l.Range.EndByte] != "#./s." {
+		t.Errorf("second link = %+v", l)
+	}
+}
+
+// codes returns "code source" for every diagnostic.
+func codes(diags []tree_sitter_sand.Diagnostic) []string {
+	var out []string
+	for _, d := range diags {
+		out = append(out, fmt.Sprintf("%s %s", d.Code, d.Source))
+	}
+	return out
+}
+
+func TestValidateRefs(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "defined after use",
+			src:  "#(en)\n\n#.later.en\n\n#later[x]\n",
+		},
+		{
+			name: "case sensitive",
+			src:  "#(en)\n\n#.Intro.en\n\n#intro# Intro\n",
+			want: []string{"unresolved-alias #.Intro.en", "unused-alias #intro# Intro"},
+		},
+		{
+			name: "escaped selectors do not count",
+			src:  "#(en)\n\n\\#.missing.en\n\n#s[x]\n",
+			want: []string{"unused-alias #s[x]"},
+		},
+		{
+			name: "indexes skip selectors",
+			src:  "#(en)\n\n#a# A\n#.a.0.en #[x]\n#.a.1.en\n#.0.",
+			want: []string{"index-out-of-range #.a.1.en"},
+		},
+		{
+			name: "local selectors start at their section",
+			src:  "#(en)\n\n#a# A\n#s[x] #./s.en #./t.en\n#b# B\n#t[y]\n#.b.t.en\n",
+			want: []string{"unused-alias #a# A", "unresolved-alias #./t.en"},
+		},
+		{
+			name: "last element",
+			src:  "#(en, ja)\n\n#s[x][y] #.s.fr #.s.ja #.s.\n",
+			want: []string{"last-not-name #.s.fr"},
+		},
+		{
+			name: "duplicates and conflicts",
+			src:  "#(en)\n\n#d[x] #d[y] #en[z]\n#a# A\n#d[w]\n#.d.en #.a.d.en\n",
+			want: []string{"duplicate-alias #d[x]", "duplicate-alias #d[y]", "alias-conflicts-with-name #en[z]"},
+		},
+		{
+			name: "renamed anchors",
+			src:  "#(en)\n\n#a# A\n#b## B\n#b# C\n#.a.b.en #.b.en\n",
+			want: []string{"anchor-renamed #b# C"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := codes(tree_sitter_sand.ValidateRefs([]byte(tt.src)))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRefsSeverity(t *testing.T) {
+	diags := tree_sitter_sand.ValidateRefs([]byte("#(en)\n\n#.missing.en\n#unused[x]\n"))
+	if len(diags) != 2 {
+		t.Fatalf("got %v", codes(diags))
+	}
+	if d := diags[0]; d.Severity != tree_sitter_sand.SeverityError || d.Range.StartPoint.Row != 2 {
+		t.Errorf("unresolved = %v", d)
+	}
+	if d := diags[1]; d.Severity != tree_sitter_sand.SeverityHint || d.Code != tree_sitter_sand.CodeUnusedAlias {
+		t.Errorf("unused = %v", d)
+	}
+}
+
+func TestRefsInvalidUTF8(t *testing.T) {
+	links := tree_sitter_sand.Links([]byte(invalidDoc))
+	if len(links) != 1 || links[0].Target != "missing.en" || links[0].Range.StartByte != 38 {
+		t.Errorf("got links %+v", links)
+	}
+	var codes []string
+	for _, d := range tree_sitter_sand.ValidateRefs([]byte(invalidDoc)) {
+		if d.Severity == tree_sitter_sand.SeverityError {
+			codes = append(codes, fmt.Sprintf("%d %s", d.Range.StartByte, d.Code))
+		}
+	}
+	if want := []string{"38 " + tree_sitter_sand.CodeUnresolvedAlias}; !slices.Equal(codes, want) {
+		t.Errorf("got errors %v, want %v", codes, want)
+	}
+}
@@ -0,0 +1,159 @@
+package tree_sitter_sand
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// RenameConflictError is returned by [Rename] when the new alias is already
+// defined in the scope of the renamed one.
+type RenameConflictError struct {
+	Alias string
+	// Range is the alias of the existing definition.
+	Range Range
+}
+
+func (e *RenameConflictError) Error() string {
+	pos := e.Range.StartPoint
+	return fmt.Sprintf("sand: alias %q is already defined at %d:%d", e.Alias, pos.Row+1, pos.Column+1)
+}
+
+// Rename returns the edits that rename the alias at offset to newName. The
+// offset may be on the alias of a section, sentence or apply-all block, or
+// on a selector path element that goes through that alias; it may also be
+// right after the alias. The edits change the definition and every such
+// path element, which are found through the tree, so prose that only looks
+// like a selector is left alone.
+//
+// The alias of a section is also the anchor of its heading, so renaming it
+// moves the anchor that [SectionAnchors] gives. Rename fails if newName is
+// not an identifier, is a name of the document or is already an alias in
+// the same scope; the last case is a [*RenameConflictError].
+func Rename(src []byte, offset uint, newName string) ([]TextEdit, error) {
+	if !isIdentifier(newName) {
+		return nil, fmt.Errorf("sand: %q is not a valid alias", newName)
+	}
+	doc, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	r := newRefs(doc)
+	lines := newLineIndex(src)
+
+	// Definitions, by scope.
+	type definition struct {
+		node  Node
+		scope *scope
+		rng   Range
+	}
+	var defs []definition
+	var definitions func(container Node)
+	definitions = func(container Node) {
+		s := r.scope(container)
+		for _, item := range s.items {
+			if alias, rng := aliasOf(item); alias != "" {
+				// The alias follows the `#` the construct starts with.
+				start := rng.StartByte + 1
+				defs = append(defs, definition{item, s, lines.rangeOf(start, start+uint(len(alias)))})
+			}
+			if sub, ok := item.(*Section); ok {
+				definitions(sub)
+			}
+		}
+	}
+	definitions(doc)
+
+	// References, by the node they reach.
+	refs := map[Node][]Range{}
+	var walk func(container Node, children []Node)
+	walk = func(container Node, children []Node) {
+		for _, child := range children {
+			switch child := child.(type) {
+			case *Section:
+				walk(child, child.Children)
+			case *Paragraph:
+				for _, span := range child.Spans {
+					if span.Kind != SpanSelector {
+						continue
+					}
+					from := Node(doc)
+					if span.Local {
+						from = container
+					}
+					elements := pathRanges(lines, span)
+					r.resolve(span, from, func(i int, node Node) {
+						refs[node] = append(refs[node], elements[i])
+					})
+				}
+			}
+		}
+	}
+	walk(doc, doc.Children)
+
+	var target *definition
+	for i, d := range defs {
+		if d.rng.StartByte <= offset && offset <= d.rng.EndByte {
+			target = &defs[i]
+			break
+		}
+		for _, ref := range refs[d.node] {
+			if ref.StartByte <= offset && offset <= ref.EndByte {
+				target = &defs[i]
+				break
+			}
+		}
+		if target != nil {
+			break
+		}
+	}
+	if target == nil {
+		return nil, errors.New("sand: no alias at the offset")
+	}
+
+	alias, _ := aliasOf(target.node)
+	if newName == alias {
+		return nil, nil
+	}
+	if slices.Contains(doc.Names, newName) {
+		return nil, fmt.Errorf("sand: %q is a name and cannot be an alias", newName)
+	}
+	if i, ok := target.scope.aliases[newName]; ok {
+		for _, d := range defs {
+			if d.node == target.scope.items[i] {
+				return nil, &RenameConflictError{Alias: newName, Range: d.rng}
+			}
+		}
+	}
+
+	edits := []TextEdit{{Range: target.rng, NewText: newName}}
+	for _, ref := range refs[target.node] {
+		edits = append(edits, TextEdit{Range: ref, NewText: newName})
+	}
+	slices.SortFunc(edits, func(a, b TextEdit) int { return int(a.Range.StartByte) - int(b.Range.StartByte) })
+	return edits, nil
+}
+
+// pathRanges returns the ranges of the path elements of a selector, which
+// follow `#.` and the optional `/` separated by dots.
+func pathRanges(lines lineIndex, sel *InlineSpan) []Range {
+	pos := sel.Range.StartByte + uint(len("#."))
+	if sel.Local {
+		pos++
+	}
+	ranges := make([]Range, len(sel.Path))
+	for i, key := range sel.Path {
+		ranges[i] = lines.rangeOf(pos, pos+uint(len(key)))
+		pos += uint(len(key) + len("."))
+	}
+	return ranges
+}
+
+func isIdentifier(s string) bool {
+	for i := range len(s) {
+		if !isIdentByte(s[i]) {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,103 @@
+package tree_sitter_sand_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+const renameDoc = `#(en, ja)
+
+#guide# Guide
+#step[First][最初]
+#./step.en and \#.guide.step.en in prose
+
+#other# Other
+#step[Second][二番目]
+#.guide.step.ja #.other.step.
+`
+
+func rename(t *testing.T, src string, offset int, newName string) string {
+	t.Helper()
+	edits, err := tree_sitter_sand.Rename([]byte(src), uint(offset), newName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tree_sitter_sand.ApplyEdits([]byte(src), edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestRename(t *testing.T) {
+	want := strings.NewReplacer(
+		"#step[First]", "#intro[First]",
+		"#./step.en", "#./intro.en",
+		"#.guide.step.ja", "#.guide.intro.ja",
+	).Replace(renameDoc)
+
+	for name, offset := range map[string]int{
+		"definition":           strings.Index(renameDoc, "step[First]"),
+		"after the definition": strings.Index(renameDoc, "[First]"),
+		"local reference":      strings.Index(renameDoc, "step.en and") + 2,
+		"reference":            strings.Index(renameDoc, "step.ja"),
+	} {
+		if got := rename(t, renameDoc, offset, "intro"); got != want {
+			t.Errorf("from the %s: got\n%s\nwant\n%s", name, got, want)
+		}
+	}
+	if got := rename(t, renameDoc, strings.Index(renameDoc, "step[First]"), "step"); got != renameDoc {
+		t.Errorf("renaming to the same alias changed the document:\n%s", got)
+	}
+}
+
+func TestRenameSection(t *testing.T) {
+	src := "#(en)\n\n#guide# Guide\n#s[x]\n#.guide.s.en #./s.en\n"
+	got := rename(t, src, strings.Index(src, "guide.s"), "manual")
+	want := "#(en)\n\n#manual# Guide\n#s[x]\n#.manual.s.en #./s.en\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	anchors := tree_sitter_sand.Anchors([]byte(got))
+	if _, ok := anchors["manual"]; !ok {
+		t.Errorf("anchors %v do not follow the alias", anchors)
+	}
+	if diags := tree_sitter_sand.ValidateRefs([]byte(got)); len(diags) != 0 {
+		t.Errorf("renaming broke references: %v", codes(diags))
+	}
+}
+
+func TestRenameErrors(t *testing.T) {
+	offset := uint(strings.Index(renameDoc, "step[First]"))
+
+	_, err := tree_sitter_sand.Rename([]byte(renameDoc+"#next[x]\n"), uint(strings.Index(renameDoc, "step[Second]")), "next")
+	var conflict *tree_sitter_sand.RenameConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("got %v, want a RenameConflictError", err)
+	}
+	if conflict.Alias != "next" || conflict.Range.StartPoint.Row != 9 || conflict.Range.StartPoint.Column != 1 {
+		t.Errorf("conflict = %+v", conflict)
+	}
+	if want := `sand: alias "next" is already defined at 10:2`; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+
+	// The other step is in another scope, so it does not conflict.
+	if _, err := tree_sitter_sand.Rename([]byte(renameDoc), offset, "other"); err != nil {
+		t.Errorf("renaming to an alias of another scope: %v", err)
+	}
+
+	for _, newName := range []string{"", "two words", "ja"} {
+		if _, err := tree_sitter_sand.Rename([]byte(renameDoc), offset, newName); err == nil {
+			t.Errorf("renaming to %q succeeded", newName)
+		}
+	}
+	for _, at := range []string{"Guide", "#.guide.step.en in", "en #.other"} {
+		if _, err := tree_sitter_sand.Rename([]byte(renameDoc), uint(strings.Index(renameDoc, at)+2), "x"); err == nil {
+			t.Errorf("renaming at %q succeeded", at)
+		}
+	}
+}
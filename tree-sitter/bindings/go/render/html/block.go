@@ -0,0 +1,98 @@
+package html
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+// BlockDecoration is how [Options.BlockTransform] renders a section or
+// paragraph, for example as a callout.
+type BlockDecoration struct {
+	// Element replaces the section element or wraps the paragraph. Empty
+	// means "div".
+	Element string
+	// Classes make up the class attribute of Element.
+	Classes []string
+
+	// Title, if not empty, is written at the start of the block in
+	// TitleElement, "p" if empty, with the class TitleClass.
+	Title        string
+	TitleElement string
+	TitleClass   string
+
+	// StripKeyword leaves out the heading of a section, or the first line of
+	// a paragraph, which held the keyword that selected the decoration.
+	StripKeyword bool
+}
+
+// AdmonitionTransform returns an [Options.BlockTransform] for admonitions: a
+// section titled with one of the keywords, or a paragraph whose first line is
+// one, optionally followed by a colon. keywords maps each keyword to the
+// title of its callout, such as "NOTE" to "Note" or "注意" to "注意".
+//
+// The block becomes an aside with the classes "admonition" and
+// "admonition-" followed by the slug of the keyword, and the keyword line is
+// replaced with a title paragraph of class "admonition-title".
+func AdmonitionTransform(keywords map[string]string) func(node tree_sitter_sand.Node, firstLine string) (BlockDecoration, bool) {
+	return func(_ tree_sitter_sand.Node, firstLine string) (BlockDecoration, bool) {
+		keyword := strings.TrimSuffix(strings.TrimSpace(firstLine), ":")
+		title, ok := keywords[keyword]
+		if !ok {
+			return BlockDecoration{}, false
+		}
+		return BlockDecoration{
+			Element:      "aside",
+			Classes:      []string{"admonition", "admonition-" + tree_sitter_sand.Slug(keyword, nil)},
+			Title:        title,
+			TitleClass:   "admonition-title",
+			StripKeyword: true,
+		}, true
+	}
+}
+
+// decoration returns the decoration of node, a block whose first line is
+// firstLine. Blocks inside a decorated one are not transformed.
+func (r *renderer) decoration(node tree_sitter_sand.Node, firstLine string) (BlockDecoration, bool) {
+	if r.opts.BlockTransform == nil || r.decorated {
+		return BlockDecoration{}, false
+	}
+	d, ok := r.opts.BlockTransform(node, firstLine)
+	if ok && d.Element == "" {
+		d.Element = "div"
+	}
+	return d, ok
+}
+
+// open writes the start tag and title of d.
+func (r *renderer) open(d BlockDecoration) {
+	r.out.WriteString("<" + d.Element)
+	r.attr(d.Element, "class", strings.Join(d.Classes, " "))
+	r.out.WriteString(">\n")
+	if d.Title == "" {
+		return
+	}
+	tag := d.TitleElement
+	if tag == "" {
+		tag = "p"
+	}
+	r.out.WriteString("<" + tag)
+	r.attr(tag, "class", d.TitleClass)
+	fmt.Fprintf(&r.out, ">%s</%s>\n", html.EscapeString(d.Title), tag)
+}
+
+// attr writes a non-empty attribute, passing it through the policy.
+func (r *renderer) attr(tag, name, value string) {
+	if value == "" {
+		return
+	}
+	if r.opts.Policy != nil {
+		var ok bool
+		if value, ok = r.opts.Policy.TransformAttr(tag, name, value); !ok {
+			return
+		}
+	}
+	fmt.Fprintf(&r.out, " %s=\"%s\"", name, html.EscapeString(value))
+}
@@ -0,0 +1,89 @@
+package html_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/render/html"
+)
+
+var keywords = map[string]string{"NOTE": "Note", "WARNING": "Warning", "注意": "注意"}
+
+func TestAdmonitionGolden(t *testing.T) {
+	paths, err := filepath.Glob("testdata/admonitions/*.sand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []byte
+			for _, name := range []string{"en", "ja"} {
+				out, err := html.RenderHTML(src, html.Options{
+					Name:           name,
+					HeadingIDs:     true,
+					BlockTransform: html.AdmonitionTransform(keywords),
+				})
+				if err != nil {
+					// unknown.sand and nested.sand only define en.
+					continue
+				}
+				got = append(got, "<!-- "+name+" -->\n"...)
+				got = append(got, out...)
+			}
+
+			golden := strings.TrimSuffix(path, ".sand") + ".html"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestBlockTransform(t *testing.T) {
+	var calls []string
+	out, err := html.RenderHTML([]byte("#(en)\n\n## Box\n\n#[<i>a</i> &amp;\\nb]\n"), html.Options{
+		Policy: attrPolicy{},
+		BlockTransform: func(node tree_sitter_sand.Node, firstLine string) (html.BlockDecoration, bool) {
+			calls = append(calls, firstLine)
+			if _, ok := node.(*tree_sitter_sand.Paragraph); !ok {
+				return html.BlockDecoration{}, false
+			}
+			return html.BlockDecoration{Classes: []string{"box", `x"y`}, Title: "<T>", TitleElement: "h6"}, true
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<section>\n<h1>Box</h1>\n<div class=\"box x&#34;y!\">\n<h6>&lt;T&gt;</h6>\n" +
+		"<p>&lt;i&gt;a&lt;/i&gt; &amp;amp;<br>\nb</p>\n</div>\n</section>\n"
+	if string(out) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+	if want := []string{"Box", "<i>a</i> &amp;"}; strings.Join(calls, "|") != strings.Join(want, "|") {
+		t.Errorf("first lines: got %q, want %q", calls, want)
+	}
+}
+
+// attrPolicy marks the class attributes it sees.
+type attrPolicy struct{ html.StrictPolicy }
+
+func (attrPolicy) TransformAttr(tag, name, value string) (string, bool) {
+	return value + "!", name == "class"
+}
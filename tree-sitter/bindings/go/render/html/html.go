@@ -0,0 +1,281 @@
+// Package html renders Sand documents as HTML.
+//
+// Rendering follows `sand out --markdown`: a document is rendered for one of
+// its names, sections become headings, and the contents of sentences and
+// apply-all blocks for that name make up the text. Prose outside those
+// constructs and selectors are notes for the author and are left out.
+package html
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/internal/observe"
+)
+
+// Options controls [RenderHTML].
+type Options struct {
+	// Name selects the language to render. The first defined name is used
+	// when it is empty.
+	Name string
+
+	// HeadingIDs adds an id attribute to every heading: its anchor as given
+	// by [tree_sitter_sand.SectionAnchors], the section alias or the
+	// [tree_sitter_sand.Slug] of the title.
+	HeadingIDs bool
+
+	// RawHTML writes contents without escaping them, so documents can embed
+	// HTML.
+	RawHTML bool
+
+	// Policy, if not nil, restricts the output for untrusted documents: it
+	// decides whether RawHTML takes effect, and rewrites the attributes of
+	// raw HTML and of headings.
+	Policy Policy
+
+	// Render is called for every *Section, *Paragraph and *InlineSpan before
+	// it is rendered. If it returns true, its result is written instead of
+	// the default rendering of the node and its children.
+	Render func(node tree_sitter_sand.Node) (string, bool)
+
+	// BlockTransform, if not nil, is called for every *Section with its
+	// title and every *Paragraph with the first line of its text, unescaped.
+	// If it returns true, the block is rendered with the decoration, and the
+	// blocks inside it are not transformed. [AdmonitionTransform] is one.
+	BlockTransform func(node tree_sitter_sand.Node, firstLine string) (BlockDecoration, bool)
+
+	// InvalidUTF8 is what to do with a source that is not valid UTF-8.
+	InvalidUTF8 tree_sitter_sand.InvalidUTF8Mode
+	// Observer, if not nil, is told about the
+	// [tree_sitter_sand.StageRender] and the [tree_sitter_sand.StageParse]
+	// inside it.
+	Observer tree_sitter_sand.Observer
+}
+
+// RenderHTML renders src as HTML.
+//
+// Syntax errors do not make RenderHTML fail; whatever [tree_sitter_sand.Parse]
+// recovers is rendered. An error is returned if src is not valid UTF-8 and
+// opts.InvalidUTF8 rejects it, if the parser cannot be used or if opts.Name
+// is not defined by the document.
+func RenderHTML(src []byte, opts Options) ([]byte, error) {
+	return RenderHTMLContext(context.Background(), src, opts)
+}
+
+// RenderHTMLContext is like [RenderHTML] but gives up when ctx is done, with
+// an error that wraps ctx.Err().
+func RenderHTMLContext(ctx context.Context, src []byte, opts Options) (out []byte, err error) {
+	stage := observe.Start(opts.Observer, tree_sitter_sand.StageRender, len(src))
+	defer func() {
+		stage.End(err, func(m map[string]any) {
+			if out != nil {
+				m[tree_sitter_sand.MetaOutputBytes] = len(out)
+			}
+		})
+	}()
+
+	src, _, err = tree_sitter_sand.CheckUTF8(src, opts.InvalidUTF8)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := tree_sitter_sand.ParseContextWithOptions(ctx, src, tree_sitter_sand.ParseOptions{Observer: opts.Observer})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &renderer{ctx: ctx, src: src, opts: opts}
+	if opts.HeadingIDs {
+		r.anchors = tree_sitter_sand.SectionAnchors(doc)
+	}
+	if opts.Name != "" {
+		r.index = slices.Index(doc.Names, opts.Name)
+		if r.index < 0 {
+			return nil, fmt.Errorf("sand: name %q is not defined", opts.Name)
+		}
+		r.name = opts.Name
+	} else if len(doc.Names) > 0 {
+		r.name = doc.Names[0]
+	}
+
+	r.nodes(doc.Children)
+	if r.err != nil {
+		return nil, fmt.Errorf("sand: render: %w", r.err)
+	}
+	return r.out.Bytes(), nil
+}
+
+type renderer struct {
+	ctx context.Context
+	// err is set when ctx is done, which stops rendering.
+	err   error
+	src   []byte
+	opts  Options
+	name  string
+	index int
+	// anchors holds the heading ids.
+	anchors map[*tree_sitter_sand.Section]string
+	// decorated is set inside a block decorated by BlockTransform.
+	decorated bool
+	out       bytes.Buffer
+}
+
+func (r *renderer) nodes(nodes []tree_sitter_sand.Node) {
+	for _, node := range nodes {
+		if r.err != nil {
+			return
+		}
+		if r.hook(node) {
+			continue
+		}
+		switch node := node.(type) {
+		case *tree_sitter_sand.Section:
+			if r.err = r.ctx.Err(); r.err != nil {
+				return
+			}
+			r.section(node)
+		case *tree_sitter_sand.Paragraph:
+			r.paragraph(node)
+		}
+	}
+}
+
+func (r *renderer) hook(node tree_sitter_sand.Node) bool {
+	if r.opts.Render == nil {
+		return false
+	}
+	s, ok := r.opts.Render(node)
+	if ok {
+		r.out.WriteString(s)
+	}
+	return ok
+}
+
+func (r *renderer) section(s *tree_sitter_sand.Section) {
+	level := min(max(s.Level, 1), 6)
+
+	d, ok := r.decoration(s, s.Title)
+	if ok {
+		r.open(d)
+	} else {
+		d.Element = "section"
+		r.out.WriteString("<section>\n")
+	}
+	if !d.StripKeyword {
+		fmt.Fprintf(&r.out, "<h%d", level)
+		if r.opts.HeadingIDs {
+			r.attr(fmt.Sprintf("h%d", level), "id", r.anchors[s])
+		}
+		fmt.Fprintf(&r.out, ">%s</h%d>\n", html.EscapeString(s.Title), level)
+	}
+	decorated := r.decorated
+	r.decorated = r.decorated || ok
+	r.nodes(s.Children)
+	r.decorated = decorated
+	r.out.WriteString("</" + d.Element + ">\n")
+}
+
+func (r *renderer) paragraph(p *tree_sitter_sand.Paragraph) {
+	var parts []string
+	// Contents are sanitized together, so that raw HTML split across spans
+	// is seen whole.
+	var run []string
+	flush := func() {
+		if len(run) > 0 {
+			text := strings.Join(run, " ")
+			if r.opts.Policy != nil && r.raw() {
+				text = sanitize(text, r.opts.Policy)
+			}
+			parts = append(parts, text)
+			run = nil
+		}
+	}
+	for _, span := range p.Spans {
+		if r.opts.Render != nil {
+			if s, ok := r.opts.Render(span); ok {
+				flush()
+				parts = append(parts, s)
+				continue
+			}
+		}
+		if c, ok := r.content(span); ok {
+			run = append(run, r.text(c))
+		}
+	}
+	flush()
+	if len(parts) == 0 {
+		return
+	}
+	text := strings.Join(parts, " ")
+
+	first, rest, _ := strings.Cut(text, "<br>\n")
+	d, ok := r.decoration(p, html.UnescapeString(first))
+	if !ok {
+		r.out.WriteString("<p>" + text + "</p>\n")
+		return
+	}
+	r.open(d)
+	if d.StripKeyword {
+		text = strings.TrimLeft(rest, " ")
+	}
+	if text != "" {
+		r.out.WriteString("<p>" + text + "</p>\n")
+	}
+	r.out.WriteString("</" + d.Element + ">\n")
+}
+
+// content returns the content span contributes for the rendered name.
+func (r *renderer) content(span *tree_sitter_sand.InlineSpan) (tree_sitter_sand.Content, bool) {
+	switch span.Kind {
+	case tree_sitter_sand.SpanSentence:
+		if r.index < len(span.Contents) {
+			return span.Contents[r.index], true
+		}
+	case tree_sitter_sand.SpanApplyAll:
+		if len(span.Contents) > 0 && (span.Targets == nil || slices.Contains(span.Targets, r.name)) {
+			return span.Contents[0], true
+		}
+	}
+	return tree_sitter_sand.Content{}, false
+}
+
+// raw reports whether contents are written without escaping.
+func (r *renderer) raw() bool {
+	return r.opts.RawHTML && (r.opts.Policy == nil || r.opts.Policy.AllowRawHTML())
+}
+
+// text renders a content the way `sand out` does: runs of whitespace in the
+// source collapse to one space and `\n` becomes a line break.
+func (r *renderer) text(c tree_sitter_sand.Content) string {
+	raw := strings.Join(strings.Fields(string(r.src[c.Range.StartByte:c.Range.EndByte])), " ")
+
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\\' && i+1 < len(raw) {
+			i++
+			switch raw[i] {
+			case 'n':
+				b.WriteString("<br>\n")
+				continue
+			case ']', '}', '\\', '/', '#':
+			default:
+				b.WriteByte('\\')
+			}
+		}
+		j := i + 1
+		for j < len(raw) && raw[j] != '\\' {
+			j++
+		}
+		if r.raw() {
+			b.WriteString(raw[i:j])
+		} else {
+			b.WriteString(html.EscapeString(raw[i:j]))
+		}
+		i = j - 1
+	}
+	return b.String()
+}
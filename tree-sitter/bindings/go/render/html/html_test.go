@@ -0,0 +1,274 @@
+package html_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/render/html"
+)
+
+var update = flag.Bool("update", false, "rewrite the .html golden files")
+
+func TestRenderHTMLGolden(t *testing.T) {
+	paths, err := filepath.Glob("../../testdata/*.sand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths = append(paths, "../../../../../README.sand")
+
+	for _, path := range paths {
+		name := filepath.Base(path)
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := html.RenderHTML(src, html.Options{HeadingIDs: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", strings.TrimSuffix(name, ".sand")+".html")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+const doc = `#(en, ja)
+
+#Usage## Usage & more
+#[<b>Hello</b>][<b>こんにちは</b>]
+#{[ja], {日本語のみ}} #{{ line\nbreak }}
+
+## Usage & more
+`
+
+func render(t *testing.T, opts html.Options) string {
+	t.Helper()
+
+	out, err := html.RenderHTML([]byte(doc), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestRenderHTMLOptions(t *testing.T) {
+	got := render(t, html.Options{})
+	want := "<section>\n<h2>Usage &amp; more</h2>\n" +
+		"<p>&lt;b&gt;Hello&lt;/b&gt; line<br>\nbreak</p>\n</section>\n" +
+		"<section>\n<h1>Usage &amp; more</h1>\n</section>\n"
+	if got != want {
+		t.Errorf("default:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = render(t, html.Options{Name: "ja", RawHTML: true, HeadingIDs: true})
+	for _, want := range []string{
+		`<h2 id="Usage">`,
+		`<h1 id="usage-more">`,
+		"<p><b>こんにちは</b> 日本語のみ line<br>\nbreak</p>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ja output does not contain %q:\n%s", want, got)
+		}
+	}
+
+	if _, err := html.RenderHTML([]byte(doc), html.Options{Name: "fr"}); err == nil {
+		t.Error("RenderHTML accepted an undefined name")
+	}
+}
+
+func TestRenderHTMLHook(t *testing.T) {
+	got := render(t, html.Options{
+		Render: func(node tree_sitter_sand.Node) (string, bool) {
+			switch node := node.(type) {
+			case *tree_sitter_sand.Section:
+				if node.Level == 1 {
+					return "<hr>\n", true
+				}
+			case *tree_sitter_sand.InlineSpan:
+				if node.Kind == tree_sitter_sand.SpanApplyAll {
+					return "[all]", true
+				}
+			}
+			return "", false
+		},
+	})
+	want := "<section>\n<h2>Usage &amp; more</h2>\n" +
+		"<p>&lt;b&gt;Hello&lt;/b&gt; [all] [all]</p>\n</section>\n<hr>\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRenderHTMLErrors(t *testing.T) {
+	out, err := html.RenderHTML([]byte("#(en)\n\n## Broken\n\n#[fine]\n\n#s[unterminated\n"), html.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<section>\n<h1>Broken</h1>\n<p>fine</p>\n</section>\n"; string(out) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestRenderHTMLInvalidUTF8(t *testing.T) {
+	src := []byte("#(en)\n\n#[caf\xe9]\n")
+	if _, err := html.RenderHTML(src, html.Options{}); !errors.Is(err, tree_sitter_sand.ErrInvalidUTF8) {
+		t.Fatalf("got error %v, want ErrInvalidUTF8", err)
+	}
+	out, err := html.RenderHTML(src, html.Options{InvalidUTF8: tree_sitter_sand.InvalidUTF8Replace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<p>caf\ufffd</p>\n"; string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestRenderHTMLContext(t *testing.T) {
+	src := []byte("#(en)\n\n## One\n\n#[a]\n\n## Two\n\n#[b]\n")
+
+	// The hook cancels rendering at the first section, after parsing.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := html.RenderHTMLContext(ctx, src, html.Options{
+		Render: func(tree_sitter_sand.Node) (string, bool) {
+			cancel()
+			return "", false
+		},
+	})
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "render") {
+		t.Errorf("cancelling during rendering gave %v", err)
+	}
+
+	if _, err := html.RenderHTMLContext(ctx, src, html.Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("rendering with a done context gave %v", err)
+	}
+}
+
+// TestHeadingIDsMatchAnchors checks that the heading ids are the anchors
+// that editors and ValidateRefs see.
+func TestHeadingIDsMatchAnchors(t *testing.T) {
+	paths, err := filepath.Glob("../../testdata/*.sand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := regexp.MustCompile(`<h[1-6] id="([^"]*)"`)
+	for _, path := range append(paths, "../../../../../README.sand") {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := html.RenderHTML(src, html.Options{HeadingIDs: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, m := range id.FindAllSubmatch(out, -1) {
+			got = append(got, string(m[1]))
+		}
+		var want []string
+		for anchor := range tree_sitter_sand.Anchors(src) {
+			want = append(want, anchor)
+		}
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: heading ids %q, want %q", path, got, want)
+		}
+	}
+}
+
+func FuzzRenderHTML(f *testing.F) {
+	paths, err := filepath.Glob("../../testdata/*.sand")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range append(paths, "../../../../../README.sand") {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		out, err := html.RenderHTML(src, html.Options{HeadingIDs: true, InvalidUTF8: tree_sitter_sand.InvalidUTF8Replace})
+		if errors.Is(err, tree_sitter_sand.ErrInvalidUTF8) {
+			// A UTF-16 byte order mark.
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(out, []byte("<script")) {
+			t.Fatalf("escaped output contains a tag: %q", out)
+		}
+	})
+}
+
+// counter is an Observer that records the stages it is told about, and
+// whether they failed.
+type counter struct{ events []string }
+
+func (c *counter) OnStageStart(stage string, _ int) {
+	c.events = append(c.events, "start "+stage)
+}
+
+func (c *counter) OnStageEnd(stage string, _ time.Duration, meta map[string]any) {
+	event := "end " + stage
+	if _, ok := meta[tree_sitter_sand.MetaError]; ok {
+		event += " failed"
+	}
+	c.events = append(c.events, event)
+}
+
+func TestRenderHTMLObserver(t *testing.T) {
+	src := []byte("#(en)\n\n## One\n")
+	for _, tt := range []struct {
+		name string
+		opts html.Options
+		// cancel cancels the context at the first node rendered.
+		cancel bool
+		want   string
+	}{
+		{"rendered", html.Options{}, false, "start render, start parse, end parse, end render"},
+		{"unknown name", html.Options{Name: "fr"}, false, "start render, start parse, end parse, end render failed"},
+		{"canceled", html.Options{}, true, "start render, start parse, end parse, end render failed"},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		if tt.cancel {
+			tt.opts.Render = func(tree_sitter_sand.Node) (string, bool) {
+				cancel()
+				return "", false
+			}
+		}
+		c := &counter{}
+		tt.opts.Observer = c
+		html.RenderHTMLContext(ctx, src, tt.opts)
+		cancel()
+		if got := strings.Join(c.events, ", "); got != tt.want {
+			t.Errorf("%s: got events %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
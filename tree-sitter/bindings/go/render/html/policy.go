@@ -0,0 +1,350 @@
+package html
+
+import (
+	"html"
+	"slices"
+	"strings"
+)
+
+// Policy decides what output [RenderHTML] may produce from an untrusted
+// document. Set it in [Options.Policy]; [StrictPolicy] is a policy for user
+// generated content, and integrators can back their own with a sanitizer
+// such as bluemonday.
+//
+// Sand has no links or images of its own, so URLs only come from raw HTML.
+// When a policy allows raw HTML, the renderer re-tokenizes the start tags of
+// every paragraph, passes each attribute through the policy and writes it
+// back quoted and escaped. A tag that is not closed within its paragraph is
+// escaped. The policy sees attributes only: it cannot drop elements such as
+// script, so a policy allowing raw HTML should only be used for trusted
+// markup or be backed by a full sanitizer.
+type Policy interface {
+	// AllowRawHTML reports whether contents are written unescaped when
+	// [Options.RawHTML] is set. If it returns false they are escaped.
+	AllowRawHTML() bool
+	// SanitizeURL returns the URL to write for the value of a URL
+	// attribute such as href or src, with character references already
+	// resolved, or false to drop the attribute. It is called for each URL
+	// of a list such as srcset or ping, dropping only that URL, and for the
+	// URL of a refresh meta tag.
+	SanitizeURL(url string) (string, bool)
+	// TransformAttr returns the value to write for attribute name of a tag,
+	// or false to drop it. Names are lowercase. It is called for every
+	// attribute the renderer writes, including heading ids, and with name
+	// "rel" and an empty value for every a tag without a rel attribute, so
+	// that a policy can add one.
+	TransformAttr(tag, name, value string) (string, bool)
+}
+
+// StrictPolicy is a [Policy] for untrusted documents. It escapes raw HTML,
+// allows only the URL schemes in Schemes and relative URLs, drops event
+// handler and style attributes, and adds Rel to links.
+type StrictPolicy struct {
+	// Schemes lists the allowed URL schemes in lowercase. Nil allows http,
+	// https and mailto.
+	Schemes []string
+	// Rel is added to links without a rel attribute. Empty means
+	// "nofollow noopener".
+	Rel string
+}
+
+// AllowRawHTML implements [Policy]. It returns false.
+func (p StrictPolicy) AllowRawHTML() bool { return false }
+
+// SanitizeURL implements [Policy]. It decides by the scheme the way browsers
+// read it: tabs and line breaks inside the URL, and controls and spaces
+// around it, are ignored, and the scheme is case-insensitive.
+func (p StrictPolicy) SanitizeURL(url string) (string, bool) {
+	url = strings.TrimFunc(url, func(r rune) bool { return r <= ' ' })
+	url = strings.NewReplacer("\t", "", "\n", "", "\r", "").Replace(url)
+	scheme, _, ok := strings.Cut(url, ":")
+	if !ok || strings.ContainsAny(scheme, "/?#") {
+		// A relative URL, whose colon, if any, comes after the path
+		// starts.
+		return url, true
+	}
+	schemes := p.Schemes
+	if schemes == nil {
+		schemes = []string{"http", "https", "mailto"}
+	}
+	return url, slices.Contains(schemes, strings.ToLower(scheme))
+}
+
+// TransformAttr implements [Policy].
+func (p StrictPolicy) TransformAttr(tag, name, value string) (string, bool) {
+	switch {
+	case strings.HasPrefix(name, "on"), name == "style", name == "srcdoc":
+		return "", false
+	case tag == "a" && name == "rel" && value == "":
+		if p.Rel == "" {
+			return "nofollow noopener", true
+		}
+		return p.Rel, true
+	}
+	return value, true
+}
+
+// urlAttrs are the attributes whose value is a URL.
+var urlAttrs = []string{"action", "background", "cite", "data", "formaction", "href", "longdesc", "poster", "src", "xlink:href"}
+
+// sanitizeAttr passes the URLs in the value of attr through policy. refresh
+// reports whether the tag is a refresh meta tag, whose content holds a URL.
+func sanitizeAttr(attr, value string, refresh bool, policy Policy) (string, bool) {
+	switch {
+	case slices.Contains(urlAttrs, attr):
+		return policy.SanitizeURL(value)
+	case attr == "ping":
+		var urls []string
+		for _, url := range strings.FieldsFunc(value, isSpaceRune) {
+			if url, ok := policy.SanitizeURL(url); ok {
+				urls = append(urls, url)
+			}
+		}
+		return strings.Join(urls, " "), len(urls) > 0
+	case attr == "srcset", attr == "imagesrcset":
+		return sanitizeSrcset(value, policy)
+	case attr == "content" && refresh:
+		return sanitizeRefresh(value, policy)
+	}
+	return value, true
+}
+
+// sanitizeSrcset passes the URL of every image candidate in a srcset, such
+// as `a.png 1x, b.png 2x`, through policy, dropping the rejected candidates.
+// It splits the candidates the way browsers do: a URL runs up to
+// whitespace, less any trailing commas, and its descriptors up to the next
+// comma outside parentheses.
+func sanitizeSrcset(value string, policy Policy) (string, bool) {
+	var candidates []string
+	for {
+		value = strings.TrimLeftFunc(value, func(r rune) bool { return r == ',' || isSpaceRune(r) })
+		if value == "" {
+			break
+		}
+		i := strings.IndexFunc(value, isSpaceRune)
+		if i < 0 {
+			i = len(value)
+		}
+		url, descriptors := value[:i], ""
+		value = value[i:]
+		if trimmed := strings.TrimRight(url, ","); trimmed != url {
+			url = trimmed
+		} else {
+			depth, j := 0, 0
+		descriptors:
+			for ; j < len(value); j++ {
+				switch value[j] {
+				case '(':
+					depth++
+				case ')':
+					depth = max(depth-1, 0)
+				case ',':
+					if depth == 0 {
+						break descriptors
+					}
+				}
+			}
+			descriptors = strings.Join(strings.FieldsFunc(value[:j], isSpaceRune), " ")
+			value = value[j:]
+		}
+		if url, ok := policy.SanitizeURL(url); ok {
+			if descriptors != "" {
+				url += " " + descriptors
+			}
+			candidates = append(candidates, url)
+		}
+	}
+	return strings.Join(candidates, ", "), len(candidates) > 0
+}
+
+// sanitizeRefresh passes the URL in the content of a refresh meta tag, such
+// as `5; url=/next`, through policy, and drops the attribute if it is
+// rejected. A `url` that is not followed by `=` is skipped too, so that the
+// URL policy sees is never longer than the one a browser reads.
+func sanitizeRefresh(value string, policy Policy) (string, bool) {
+	i := 0
+	skip := func(f func(c byte) bool) {
+		for i < len(value) && f(value[i]) {
+			i++
+		}
+	}
+	skip(isSpace)
+	skip(func(c byte) bool { return '0' <= c && c <= '9' || c == '.' })
+	skip(isSpace)
+	if i < len(value) && (value[i] == ';' || value[i] == ',') {
+		i++
+	}
+	skip(isSpace)
+	if len(value)-i >= 3 && strings.EqualFold(value[i:i+3], "url") {
+		i += 3
+		skip(isSpace)
+		if i < len(value) && value[i] == '=' {
+			i++
+			skip(isSpace)
+		}
+	}
+	url, quote := value[i:], ""
+	if url != "" && (url[0] == '"' || url[0] == '\'') {
+		quote, url = url[:1], url[1:]
+		if end := strings.IndexByte(url, quote[0]); end >= 0 {
+			url = url[:end]
+		}
+	}
+	if url == "" {
+		// A refresh of the page itself.
+		return value, true
+	}
+	url, ok := policy.SanitizeURL(url)
+	if !ok {
+		return "", false
+	}
+	return value[:i] + quote + url + quote, true
+}
+
+// sanitize rewrites the start tags of raw HTML through policy. Text, end
+// tags and character references are kept; a `<` that does not start a
+// complete tag is escaped.
+func sanitize(s string, policy Policy) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+		n, tag := startTag(s, policy)
+		if n == 0 {
+			if end := endTag(s); end > 0 {
+				b.WriteString(s[:end])
+				s = s[end:]
+				continue
+			}
+			b.WriteString("&lt;")
+			s = s[1:]
+			continue
+		}
+		b.WriteString(tag)
+		s = s[n:]
+	}
+}
+
+func isNameByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == ':' || c == '_'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isSpaceRune(r rune) bool {
+	return r < 0x80 && isSpace(byte(r))
+}
+
+// endTag returns the length of the end tag s starts with, or 0.
+func endTag(s string) int {
+	if !strings.HasPrefix(s, "</") || len(s) < 3 || !isNameByte(s[2]) {
+		return 0
+	}
+	i := strings.IndexByte(s, '>')
+	if i < 0 || strings.IndexByte(s[1:i], '<') >= 0 {
+		return 0
+	}
+	return i + 1
+}
+
+// startTag parses the start tag s starts with and returns its length and
+// the tag rewritten through policy, or 0 if s does not start with a
+// complete start tag.
+func startTag(s string, policy Policy) (int, string) {
+	i := 1
+	for i < len(s) && isNameByte(s[i]) {
+		i++
+	}
+	if i == 1 {
+		return 0, ""
+	}
+	name := strings.ToLower(s[1:i])
+
+	var b strings.Builder
+	b.WriteString("<" + name)
+	write := func(attr, value string) {
+		if value, ok := policy.TransformAttr(name, attr, value); ok {
+			b.WriteString(" " + attr + `="` + html.EscapeString(value) + `"`)
+		}
+	}
+	// The attributes are written once the tag is complete, as whether a
+	// meta tag is a refresh can come after its content.
+	type attribute struct{ name, value string }
+	var attrs []attribute
+	hasRel := false
+	for {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		switch {
+		case i == len(s) || s[i] == '<':
+			return 0, ""
+		case s[i] == '>' || strings.HasPrefix(s[i:], "/>"):
+			refresh := name == "meta" && slices.ContainsFunc(attrs, func(a attribute) bool {
+				return a.name == "http-equiv" && strings.EqualFold(strings.TrimFunc(a.value, isSpaceRune), "refresh")
+			})
+			for _, a := range attrs {
+				if value, ok := sanitizeAttr(a.name, a.value, refresh, policy); ok {
+					write(a.name, value)
+				}
+			}
+			if name == "a" && !hasRel {
+				write("rel", "")
+			}
+			if s[i] == '/' {
+				b.WriteString(" /")
+				i++
+			}
+			b.WriteByte('>')
+			return i + 1, b.String()
+		}
+
+		start := i
+		for i < len(s) && !isSpace(s[i]) && !strings.ContainsRune("=>/<", rune(s[i])) {
+			i++
+		}
+		if i == start {
+			// A stray slash.
+			i++
+			continue
+		}
+		attr := strings.ToLower(s[start:i])
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		value := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				end := strings.IndexByte(s[i+1:], s[i])
+				if end < 0 {
+					return 0, ""
+				}
+				value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[start:i]
+			}
+		}
+
+		value = html.UnescapeString(value)
+		if attr == "rel" {
+			hasRel = true
+		}
+		attrs = append(attrs, attribute{attr, value})
+	}
+}
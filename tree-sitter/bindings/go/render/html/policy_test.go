@@ -0,0 +1,206 @@
+package html_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/satler-git/sand-markup/bindings/go/render/html"
+)
+
+// trusted is StrictPolicy with raw HTML allowed, so only its attribute
+// rules apply.
+type trusted struct{ html.StrictPolicy }
+
+func (trusted) AllowRawHTML() bool { return true }
+
+func renderRaw(t *testing.T, body string, policy html.Policy) string {
+	t.Helper()
+	out, err := html.RenderHTML([]byte("#(en)\n\n"+body+"\n"), html.Options{RawHTML: true, Policy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+func TestStrictPolicyEscapesRawHTML(t *testing.T) {
+	got := renderRaw(t, `#[<script>alert(1)</script> <a href="https://example.com">x</a>]`, html.StrictPolicy{})
+	want := `<p>&lt;script&gt;alert(1)&lt;/script&gt; &lt;a href=&#34;https://example.com&#34;&gt;x&lt;/a&gt;</p>`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestPolicyAttributes(t *testing.T) {
+	for _, tt := range []struct {
+		name, body, want string
+	}{
+		{
+			"javascript URL",
+			`#[<a href="javascript:alert(1)">x</a>]`,
+			`<p><a rel="nofollow noopener">x</a></p>`,
+		},
+		{
+			"uppercase scheme",
+			`#[<A HREF="JaVaScRiPt:alert(1)">x</A>]`,
+			`<p><a rel="nofollow noopener">x</A></p>`,
+		},
+		{
+			"character references",
+			`#[<a href=" &#106;ava&#x09;script&colon;alert(1)">x</a>]`,
+			`<p><a rel="nofollow noopener">x</a></p>`,
+		},
+		{
+			"nested scheme",
+			`#[<a href="javascript:https://example.com">x</a>]`,
+			`<p><a rel="nofollow noopener">x</a></p>`,
+		},
+		{
+			"javascript in the query",
+			`#[<a href='https://example.com/?next=javascript:alert(1)'>x</a>]`,
+			`<p><a href="https://example.com/?next=javascript:alert(1)" rel="nofollow noopener">x</a></p>`,
+		},
+		{
+			"relative URL",
+			`#[<a href=docs/a:b.html rel=me>x</a>]`,
+			`<p><a href="docs/a:b.html" rel="me">x</a></p>`,
+		},
+		{
+			"data image",
+			`#[<img src="data:image/svg+xml,<svg onload=alert(1)>" alt="x" />]`,
+			`<p><img alt="x" /></p>`,
+		},
+		{
+			"event handlers and styles",
+			`#[<b onclick="alert(1)" OnMouseOver=alert(1) style="background:url(javascript:x)" title="a&quot;b">x</b>]`,
+			`<p><b title="a&#34;b">x</b></p>`,
+		},
+		{
+			"split across spans",
+			`#[<a href="java] #[script:alert(1)">x</a>]`,
+			`<p><a rel="nofollow noopener">x</a></p>`,
+		},
+		{
+			"split across paragraphs",
+			"#[<a href=\"https://example.com\"]\n\n#[onclick=\"alert(1)\">x</a>]",
+			"<p>&lt;a href=\"https://example.com\"</p>\n<p>onclick=\"alert(1)\">x</a></p>",
+		},
+		{
+			"unterminated quote",
+			`#[<a href="x>y</a>]`,
+			`<p>&lt;a href="x>y</a></p>`,
+		},
+		{
+			"object data",
+			`#[<object data="javascript:alert(1)" type="text/html"></object>]`,
+			`<p><object type="text/html"></object></p>`,
+		},
+		{
+			"poster and formaction",
+			`#[<video poster="javascript:x"></video><button formaction="javascript:x">x</button>]`,
+			`<p><video></video><button>x</button></p>`,
+		},
+		{
+			"ping",
+			`#[<a href="/x" ping="https://example.com/p javascript:x  /p">x</a>]`,
+			`<p><a href="/x" ping="https://example.com/p /p" rel="nofollow noopener">x</a></p>`,
+		},
+		{
+			"srcset",
+			`#[<img srcset="a.png 1x, javascript:x 2x,b.png,, data:image/png;base64,AAAA 3x,c.png (max-width: 1px, x) 100w" />]`,
+			`<p><img srcset="a.png 1x, b.png, c.png (max-width: 1px, x) 100w" /></p>`,
+		},
+		{
+			"imagesrcset without allowed URLs",
+			`#[<link rel=preload imagesrcset="javascript:x 1x, javascript:y 2x" />]`,
+			`<p><link rel="preload" /></p>`,
+		},
+		{
+			"meta refresh",
+			`#[<meta content="0; URL='javascript:alert(1)'" http-equiv=" Refresh" />]`,
+			`<p><meta http-equiv=" Refresh" /></p>`,
+		},
+		{
+			"meta refresh without url=",
+			`#[<meta http-equiv=refresh content="0;url javascript:alert(1)" />]`,
+			`<p><meta http-equiv="refresh" /></p>`,
+		},
+		{
+			"meta refresh to a relative URL",
+			`#[<meta http-equiv=refresh content="5;url=/next" /><meta http-equiv=refresh content=5 />]`,
+			`<p><meta http-equiv="refresh" content="5;url=/next" /><meta http-equiv="refresh" content="5" /></p>`,
+		},
+		{
+			"meta content",
+			`#[<meta name="description" content="javascript:x" />]`,
+			`<p><meta name="description" content="javascript:x" /></p>`,
+		},
+		{
+			"comments and doctypes",
+			`#[<!-- <a href="javascript:x"> --> <!doctype html>]`,
+			`<p>&lt;!-- <a rel="nofollow noopener"> --> &lt;!doctype html></p>`,
+		},
+	} {
+		if got := renderRaw(t, tt.body, trusted{}); got != tt.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStrictPolicySchemes(t *testing.T) {
+	p := trusted{html.StrictPolicy{Schemes: []string{"https", "ftp"}, Rel: "ugc"}}
+	got := renderRaw(t, `#[<a href="FTP://example.com">x</a> <a href="mailto:a@example.com">y</a>]`, p)
+	want := `<p><a href="FTP://example.com" rel="ugc">x</a> <a rel="ugc">y</a></p>`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+// urls records the URLs it is asked about and rejects those starting with
+// "x".
+type urls struct {
+	trusted
+	seen *[]string
+}
+
+func (p urls) SanitizeURL(url string) (string, bool) {
+	*p.seen = append(*p.seen, url)
+	return url, !strings.HasPrefix(url, "x")
+}
+
+func TestPolicySanitizeURLLists(t *testing.T) {
+	var seen []string
+	got := renderRaw(t, `#[<img srcset="a 1x, x 2x" /><a ping="b x" href=c>d</a><object data=e></object><meta http-equiv=refresh content="1; url='f'" />]`, urls{seen: &seen})
+	want := `<p><img srcset="a 1x" /><a ping="b" href="c" rel="nofollow noopener">d</a><object data="e"></object><meta http-equiv="refresh" content="1; url=&#39;f&#39;" /></p>`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if want := "a x b x c e f"; strings.Join(seen, " ") != want {
+		t.Errorf("SanitizeURL saw %q, want %q", seen, want)
+	}
+}
+
+// noIDs drops heading ids.
+type noIDs struct{ html.StrictPolicy }
+
+func (noIDs) TransformAttr(tag, name, value string) (string, bool) {
+	return value, name != "id"
+}
+
+func TestPolicyHeadingIDs(t *testing.T) {
+	src := []byte("#(en)\n\n#intro# Intro\n")
+	for _, tt := range []struct {
+		policy html.Policy
+		want   string
+	}{
+		{html.StrictPolicy{}, `<h1 id="intro">`},
+		{noIDs{}, `<h1>`},
+	} {
+		out, err := html.RenderHTML(src, html.Options{HeadingIDs: true, Policy: tt.policy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(out), tt.want) {
+			t.Errorf("%T: got %s, want it to contain %s", tt.policy, out, tt.want)
+		}
+	}
+}
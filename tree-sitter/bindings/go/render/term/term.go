@@ -0,0 +1,292 @@
+// Package term renders Sand documents for a terminal, with ANSI colors and
+// text wrapped to a width.
+//
+// Like the HTML renderer, it follows `sand out`: a document is rendered for
+// one of its names, sections become headings, and the contents of sentences
+// and apply-all blocks for that name make up the text.
+package term
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/internal/observe"
+	"github.com/satler-git/sand-markup/bindings/go/internal/width"
+)
+
+// Theme holds the SGR parameters used for each construct, such as "1" for
+// bold or "38;5;208" for an orange foreground. An empty style writes the text
+// without escape sequences.
+type Theme struct {
+	// Heading is the style of section titles.
+	Heading string
+	// HeadingMarker is the style of the hashes before a title.
+	HeadingMarker string
+	// Sentence is the style of the contents of sentence definitions.
+	Sentence string
+	// ApplyAll is the style of the contents of apply-all blocks, which are
+	// shared between languages.
+	ApplyAll string
+}
+
+// DefaultTheme returns bold headings with dim markers and italic apply-all
+// text.
+func DefaultTheme() Theme {
+	return Theme{Heading: "1", HeadingMarker: "2", ApplyAll: "3"}
+}
+
+// TermOptions controls [RenderANSI].
+type TermOptions struct {
+	// Name selects the language to render. The first defined name is used
+	// when it is empty.
+	Name string
+	// Width is the number of columns to wrap text at, 80 if it is zero or
+	// less. Wide runes such as CJK ideographs take two columns and lines may
+	// break between them.
+	Width int
+	// NoColor writes plain text without escape sequences. The library does
+	// not read the environment: set it when NO_COLOR is set or the output is
+	// not a terminal.
+	NoColor bool
+	// Theme is used unless NoColor is set. The zero Theme uses no styles;
+	// start from [DefaultTheme] to change some of them.
+	Theme Theme
+	// InvalidUTF8 is what to do with a source that is not valid UTF-8.
+	InvalidUTF8 tree_sitter_sand.InvalidUTF8Mode
+	// Observer, if not nil, is told about the
+	// [tree_sitter_sand.StageRender] and the [tree_sitter_sand.StageParse]
+	// inside it.
+	Observer tree_sitter_sand.Observer
+}
+
+// RenderANSI renders src for a terminal.
+//
+// Syntax errors do not make RenderANSI fail; whatever
+// [tree_sitter_sand.Parse] recovers is rendered. An error is returned if src
+// is not valid UTF-8 and opts.InvalidUTF8 rejects it, if the parser cannot be
+// used or if opts.Name is not defined by the document.
+func RenderANSI(src []byte, opts TermOptions) (out []byte, err error) {
+	stage := observe.Start(opts.Observer, tree_sitter_sand.StageRender, len(src))
+	defer func() {
+		stage.End(err, func(m map[string]any) {
+			if out != nil {
+				m[tree_sitter_sand.MetaOutputBytes] = len(out)
+			}
+		})
+	}()
+
+	src, _, err = tree_sitter_sand.CheckUTF8(src, opts.InvalidUTF8)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := tree_sitter_sand.ParseWithOptions(src, tree_sitter_sand.ParseOptions{Observer: opts.Observer})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &renderer{src: src, opts: opts, width: opts.Width}
+	if r.width <= 0 {
+		r.width = 80
+	}
+	if opts.Name != "" {
+		r.index = slices.Index(doc.Names, opts.Name)
+		if r.index < 0 {
+			return nil, fmt.Errorf("sand: name %q is not defined", opts.Name)
+		}
+		r.name = opts.Name
+	} else if len(doc.Names) > 0 {
+		r.name = doc.Names[0]
+	}
+
+	r.nodes(doc.Children)
+	return r.out.Bytes(), nil
+}
+
+type renderer struct {
+	src   []byte
+	opts  TermOptions
+	width int
+	name  string
+	index int
+	out   bytes.Buffer
+}
+
+func (r *renderer) nodes(nodes []tree_sitter_sand.Node) {
+	for _, node := range nodes {
+		switch node := node.(type) {
+		case *tree_sitter_sand.Section:
+			r.section(node)
+		case *tree_sitter_sand.Paragraph:
+			r.paragraph(node)
+		}
+	}
+}
+
+func (r *renderer) block(atoms []atom) {
+	if !slices.ContainsFunc(atoms, func(a atom) bool { return a.text != "" }) {
+		return
+	}
+	if r.out.Len() > 0 {
+		r.out.WriteByte('\n')
+	}
+	r.wrap(atoms)
+}
+
+func (r *renderer) section(s *tree_sitter_sand.Section) {
+	atoms := []atom{{text: strings.Repeat("#", max(s.Level, 1)), style: r.opts.Theme.HeadingMarker}}
+	atoms = appendWords(atoms, s.Title, r.opts.Theme.Heading, true)
+	r.block(atoms)
+	r.nodes(s.Children)
+}
+
+func (r *renderer) paragraph(p *tree_sitter_sand.Paragraph) {
+	var atoms []atom
+	for _, span := range p.Spans {
+		switch span.Kind {
+		case tree_sitter_sand.SpanSentence:
+			if r.index < len(span.Contents) {
+				atoms = r.content(atoms, span.Contents[r.index], r.opts.Theme.Sentence)
+			}
+		case tree_sitter_sand.SpanApplyAll:
+			if len(span.Contents) > 0 && (span.Targets == nil || slices.Contains(span.Targets, r.name)) {
+				atoms = r.content(atoms, span.Contents[0], r.opts.Theme.ApplyAll)
+			}
+		}
+	}
+	r.block(atoms)
+}
+
+// content appends the atoms of c, resolving escapes as `sand out` does.
+func (r *renderer) content(atoms []atom, c tree_sitter_sand.Content, style string) []atom {
+	raw := string(r.src[c.Range.StartByte:c.Range.EndByte])
+	var text strings.Builder
+	space := true
+	flush := func() {
+		atoms = appendWords(atoms, text.String(), style, space)
+		space = false
+		text.Reset()
+	}
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\\' && i+1 < len(raw) {
+			i++
+			switch raw[i] {
+			case 'n':
+				flush()
+				atoms = append(atoms, atom{text: "\n"})
+				continue
+			case ']', '}', '\\', '/', '#':
+			default:
+				text.WriteByte('\\')
+			}
+		}
+		text.WriteByte(raw[i])
+	}
+	flush()
+	return atoms
+}
+
+// atom is a piece of text that is not broken across lines.
+type atom struct {
+	text  string
+	style string
+	// space is set if the atom follows a space, unless it starts a line.
+	space bool
+}
+
+// appendWords appends the words of text. Wide runes are atoms of their own
+// so that lines can break between them. space says whether the first word
+// follows a space.
+func appendWords(atoms []atom, text, style string, space bool) []atom {
+	for i, field := range strings.Fields(text) {
+		space := space || i > 0 || unicode.IsSpace(rune(text[0]))
+		start := 0
+		for j, c := range field {
+			if width.Rune(c) < 2 {
+				continue
+			}
+			if j > start {
+				atoms = append(atoms, atom{text: field[start:j], style: style, space: space})
+				space = false
+			}
+			size := len(string(c))
+			if n := len(atoms); n > 0 && !space && j > 0 && width.NoLineStart(c) && atoms[n-1].style == style {
+				// Keep closing punctuation on the line before.
+				atoms[n-1].text += field[j : j+size]
+			} else {
+				atoms = append(atoms, atom{text: field[j : j+size], style: style, space: space})
+			}
+			space = false
+			start = j + size
+		}
+		if start < len(field) {
+			atoms = append(atoms, atom{text: field[start:], style: style, space: space})
+		}
+	}
+	if len(text) > 0 && len(atoms) > 0 && unicode.IsSpace(rune(text[len(text)-1])) {
+		// The next piece of text follows a space.
+		atoms = append(atoms, atom{space: true})
+	}
+	return atoms
+}
+
+// wrap writes atoms filling lines up to the width.
+func (r *renderer) wrap(atoms []atom) {
+	col := 0
+	style := ""
+	setStyle := func(s string) {
+		if r.opts.NoColor || s == style {
+			return
+		}
+		if style != "" {
+			r.out.WriteString("\x1b[0m")
+		}
+		if s != "" {
+			fmt.Fprintf(&r.out, "\x1b[%sm", s)
+		}
+		style = s
+	}
+	newline := func() {
+		setStyle("")
+		r.out.WriteByte('\n')
+		col = 0
+	}
+
+	space := false
+	for _, a := range atoms {
+		if a.text == "\n" {
+			newline()
+			space = false
+			continue
+		}
+		space = space || a.space
+		if a.text == "" {
+			continue
+		}
+		w := width.String(a.text)
+		sep := 0
+		if space && col > 0 {
+			sep = 1
+		}
+		if col > 0 && col+sep+w > r.width {
+			newline()
+			sep = 0
+		}
+		if sep > 0 {
+			if a.style != style {
+				setStyle("")
+			}
+			r.out.WriteByte(' ')
+		}
+		setStyle(a.style)
+		r.out.WriteString(a.text)
+		col += sep + w
+		space = false
+	}
+	if col > 0 {
+		newline()
+	}
+}
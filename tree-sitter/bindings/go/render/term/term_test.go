@@ -0,0 +1,191 @@
+package term_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	"github.com/satler-git/sand-markup/bindings/go/render/term"
+)
+
+var update = flag.Bool("update", false, "rewrite the .ansi golden files")
+
+// readable shows escape sequences as text so that snapshots can be read and
+// diffed.
+func readable(b []byte) string {
+	return strings.ReplaceAll(string(b), "\x1b", `\x1b`)
+}
+
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".ansi")
+	if *update {
+		if err := os.WriteFile(path, []byte(readable(got)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readable(got) != string(want) {
+		t.Errorf("got:\n%s\nwant:\n%s", readable(got), want)
+	}
+}
+
+func TestRenderANSIGolden(t *testing.T) {
+	paths, err := filepath.Glob("../../testdata/*.sand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths = append(paths, "../../../../../README.sand")
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".sand")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := tree_sitter_sand.Parse(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, lang := range doc.Names {
+				got, err := term.RenderANSI(src, term.TermOptions{Name: lang, Width: 40, Theme: term.DefaultTheme()})
+				if err != nil {
+					t.Fatal(err)
+				}
+				golden(t, name+"."+lang, got)
+			}
+		})
+	}
+}
+
+const doc = `#(en, ja)
+
+#Usage## Usage and more
+#[The quick brown fox jumps over the lazy dog.][速い茶色の狐がのろまな犬を飛び越える。]
+#{[ja], {日本語のみ}} #{{ line\nbreak }}
+`
+
+func TestRenderANSIWrap(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts term.TermOptions
+	}{
+		{"wrap-en", term.TermOptions{Width: 12, Theme: term.DefaultTheme()}},
+		{"wrap-ja", term.TermOptions{Name: "ja", Width: 12, Theme: term.DefaultTheme()}},
+		{"nocolor", term.TermOptions{Name: "ja", Width: 20, NoColor: true, Theme: term.DefaultTheme()}},
+		{"theme", term.TermOptions{Width: 80, Theme: term.Theme{Heading: "1;4", HeadingMarker: "1;4", Sentence: "32"}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := term.RenderANSI([]byte(doc), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden(t, tt.name, got)
+		})
+	}
+}
+
+func TestRenderANSINoColor(t *testing.T) {
+	got, err := term.RenderANSI([]byte(doc), term.TermOptions{NoColor: true, Theme: term.DefaultTheme()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.IndexByte(got, 0x1b) >= 0 {
+		t.Errorf("NoColor output has escape sequences: %q", got)
+	}
+}
+
+func TestRenderANSIWidth(t *testing.T) {
+	got, err := term.RenderANSI([]byte(doc), term.TermOptions{Name: "ja", Width: 10, NoColor: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(got), "\n"), "\n") {
+		w := 0
+		for _, c := range line {
+			if c < 0x1100 {
+				w++
+			} else {
+				w += 2
+			}
+		}
+		if w > 10 {
+			t.Errorf("line %q is %d columns wide", line, w)
+		}
+	}
+}
+
+func TestRenderANSIUnknownName(t *testing.T) {
+	if _, err := term.RenderANSI([]byte(doc), term.TermOptions{Name: "fr"}); err == nil || err.Error() != `sand: name "fr" is not defined` {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func FuzzRenderANSI(f *testing.F) {
+	paths, err := filepath.Glob("../../testdata/*.sand")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range append(paths, "../../../../../README.sand") {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(src, uint8(20))
+	}
+	f.Fuzz(func(t *testing.T, src []byte, width uint8) {
+		out, err := term.RenderANSI(src, term.TermOptions{Width: int(width), NoColor: true, InvalidUTF8: tree_sitter_sand.InvalidUTF8Replace})
+		if errors.Is(err, tree_sitter_sand.ErrInvalidUTF8) {
+			// A UTF-16 byte order mark.
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			t.Fatalf("output does not end with a newline: %q", out)
+		}
+	})
+}
+
+// counter is an Observer that records the stages it is told about, and
+// whether they failed.
+type counter struct{ events []string }
+
+func (c *counter) OnStageStart(stage string, _ int) {
+	c.events = append(c.events, "start "+stage)
+}
+
+func (c *counter) OnStageEnd(stage string, _ time.Duration, meta map[string]any) {
+	event := "end " + stage
+	if _, ok := meta[tree_sitter_sand.MetaError]; ok {
+		event += " failed"
+	}
+	c.events = append(c.events, event)
+}
+
+func TestRenderANSIObserver(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want string
+	}{
+		{"#(en)\n\n## One\n", "start render, start parse, end parse, end render"},
+		{"#(en)\n\xff", "start render, end render failed"},
+	} {
+		c := &counter{}
+		term.RenderANSI([]byte(tt.src), term.TermOptions{Observer: c})
+		if got := strings.Join(c.events, ", "); got != tt.want {
+			t.Errorf("%q: got events %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
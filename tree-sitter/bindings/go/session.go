@@ -0,0 +1,144 @@
+package tree_sitter_sand
+
+import (
+	"sync"
+
+	"github.com/satler-git/sand-markup/bindings/go/internal/observe"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Session keeps a parser and the last tree of one document so that edits can
+// be reparsed incrementally.
+//
+// All methods lock the session, so it may be shared between goroutines, but
+// the tree returned by [Session.Parse], [Session.ApplyEdit] and
+// [Session.Tree] belongs to the session: it stays valid only until the next
+// call to one of those methods or to [Session.Close]. Use
+// [tree_sitter.Tree.Clone] to keep a tree for longer.
+type Session struct {
+	mu      sync.Mutex
+	parser  *tree_sitter.Parser
+	tree    *tree_sitter.Tree
+	changed []Range
+	// observer is told about every parse.
+	observer Observer
+}
+
+// NewSession returns an empty session. The caller must call [Session.Close]
+// when it is done with it.
+func NewSession() (*Session, error) {
+	parser, err := newParser()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{parser: parser}, nil
+}
+
+// Parse parses src from scratch, discarding the previous tree.
+func (s *Session) Parse(src []byte) *tree_sitter.Tree {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.parse(src, nil, nil)
+}
+
+// ApplyEdit records edit on the previous tree and reparses newSource, reusing
+// the unchanged parts of that tree. Without a previous tree it behaves like
+// [Session.Parse].
+func (s *Session) ApplyEdit(edit tree_sitter.InputEdit, newSource []byte) *tree_sitter.Tree {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.parse(newSource, &edit, s.tree)
+}
+
+// parse parses src, reusing old once edit is recorded on it if there is an
+// old tree, and reports the parse to the observer.
+func (s *Session) parse(src []byte, edit *tree_sitter.InputEdit, old *tree_sitter.Tree) *tree_sitter.Tree {
+	stage := observe.Start(s.observer, StageParse, len(src))
+	var tree *tree_sitter.Tree
+	defer func() {
+		var err error
+		if tree == nil {
+			err = ErrNoTree
+		}
+		stage.End(err, func(m map[string]any) {
+			if tree != nil {
+				treeMeta(m, tree)
+			}
+			m[MetaIncremental] = old != nil
+		})
+	}()
+
+	if old == nil {
+		tree = s.replace(parse(s.parser, src, nil), nil)
+		return tree
+	}
+	old.Edit(edit)
+	tree = parse(s.parser, src, old)
+	if tree == nil {
+		return nil
+	}
+	tree = s.replace(tree, old.ChangedRanges(tree))
+	return tree
+}
+
+func (s *Session) replace(tree *tree_sitter.Tree, changed []Range) *tree_sitter.Tree {
+	if tree == nil {
+		return nil
+	}
+	if s.tree != nil {
+		s.tree.Close()
+	}
+	s.tree = tree
+	s.changed = changed
+	return tree
+}
+
+// SetObserver makes the session report every [Session.Parse] and
+// [Session.ApplyEdit] to o as a [StageParse], with [MetaIncremental] set
+// when the previous tree was reused. A nil o turns reporting off.
+func (s *Session) SetObserver(o Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.observer = o
+}
+
+// Tree returns the current tree, or nil before the first parse.
+func (s *Session) Tree() *tree_sitter.Tree {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tree
+}
+
+// ChangedRanges returns the ranges whose syntactic structure differs between
+// the tree before the last [Session.ApplyEdit] and the current one. It is nil
+// after a full parse.
+//
+// Text edits that leave the structure intact, such as typing inside prose,
+// are not reported; callers re-rendering a preview should treat the edited
+// range itself as changed too.
+func (s *Session) ChangedRanges() []Range {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.changed
+}
+
+// Close releases the parser and the current tree. The session must not be
+// used afterwards.
+func (s *Session) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tree != nil {
+		s.tree.Close()
+		s.tree = nil
+	}
+	if s.parser != nil {
+		s.parser.Close()
+		s.parser = nil
+	}
+}
@@ -0,0 +1,121 @@
+package tree_sitter_sand_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+func newSession(t testing.TB) *tree_sitter_sand.Session {
+	t.Helper()
+
+	s, err := tree_sitter_sand.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// insert returns src with text inserted at offset and the matching edit.
+func insert(src []byte, offset int, text string) ([]byte, tree_sitter.InputEdit) {
+	point := func(src []byte, offset int) tree_sitter.Point {
+		row := bytes.Count(src[:offset], []byte("\n"))
+		col := offset - (bytes.LastIndexByte(src[:offset], '\n') + 1)
+		return tree_sitter.Point{Row: uint(row), Column: uint(col)}
+	}
+
+	out := append(append(append([]byte{}, src[:offset]...), text...), src[offset:]...)
+	return out, tree_sitter.InputEdit{
+		StartByte:      uint(offset),
+		OldEndByte:     uint(offset),
+		NewEndByte:     uint(offset + len(text)),
+		StartPosition:  point(src, offset),
+		OldEndPosition: point(src, offset),
+		NewEndPosition: point(out, offset+len(text)),
+	}
+}
+
+func TestSessionApplyEdit(t *testing.T) {
+	s := newSession(t)
+	src := []byte("#(en, ja)\n\n## One\n\n#[a][b]\n\n## Two\n\ntext\n")
+
+	// Without a previous tree, ApplyEdit is a full parse.
+	if tree := s.ApplyEdit(tree_sitter.InputEdit{}, src); tree == nil || tree.RootNode().HasError() {
+		t.Fatal("first ApplyEdit did not parse the document")
+	}
+	if s.ChangedRanges() != nil {
+		t.Errorf("changed ranges after a full parse = %v", s.ChangedRanges())
+	}
+
+	offset := bytes.Index(src, []byte("## Two"))
+	src, edit := insert(src, offset, "## Between\n\n")
+	tree := s.ApplyEdit(edit, src)
+	if tree != s.Tree() {
+		t.Error("Tree does not return the tree of the last edit")
+	}
+
+	full := cst(t, src)
+	defer full.Close()
+	if got, want := tree.RootNode().ToSexp(), full.RootNode().ToSexp(); got != want {
+		t.Errorf("incremental tree = %s\nwant %s", got, want)
+	}
+
+	changed := s.ChangedRanges()
+	if len(changed) == 0 {
+		t.Fatal("no changed ranges after inserting a section")
+	}
+	if r := changed[0]; r.StartByte > uint(offset) || r.EndByte < edit.NewEndByte {
+		t.Errorf("changed range %d-%d does not cover the new section at %d-%d",
+			r.StartByte, r.EndByte, offset, edit.NewEndByte)
+	}
+}
+
+func generate(size int) []byte {
+	var b bytes.Buffer
+	b.WriteString("#(en, ja)\n\n")
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, "#s%d## Section %d\n\n", i, i)
+		fmt.Fprintf(&b, "Some prose with #p%d[an english sentence][a japanese one] inside.\n", i)
+		fmt.Fprintf(&b, "#{[ja], {shared text}} and a reference to #./p%d.en\n\n", i)
+	}
+	return b.Bytes()
+}
+
+func BenchmarkReparse(b *testing.B) {
+	src := generate(1 << 20)
+	offset := len(src) / 2
+
+	b.Run("full", func(b *testing.B) {
+		s := newSession(b)
+		for range b.N {
+			s.Parse(src)
+		}
+	})
+
+	b.Run("incremental", func(b *testing.B) {
+		s := newSession(b)
+		s.Parse(src)
+		edited, edit := insert(src, offset, "x")
+		b.ResetTimer()
+		for i := range b.N {
+			// Alternate between inserting and removing the byte so that
+			// every iteration applies a real edit.
+			if i%2 == 0 {
+				s.ApplyEdit(edit, edited)
+			} else {
+				s.ApplyEdit(tree_sitter.InputEdit{
+					StartByte:      edit.StartByte,
+					OldEndByte:     edit.NewEndByte,
+					NewEndByte:     edit.StartByte,
+					StartPosition:  edit.StartPosition,
+					OldEndPosition: edit.NewEndPosition,
+					NewEndPosition: edit.StartPosition,
+				}, src)
+			}
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package tree_sitter_sand
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Slug turns a heading title into an anchor. Letters, digits, combining
+// marks and underscores are kept in any script and lowercased; nothing is
+// transliterated. Whitespace and hyphens separate words: every run of them
+// becomes one hyphen, and runs at either end are dropped. Everything else,
+// which is punctuation and symbols, is dropped. A title with nothing left
+// gives "section".
+//
+// If existing is not nil, Slug records the anchor in it and makes it unique
+// against the anchors already recorded by appending "-1", "-2" and so on, as
+// GitHub does.
+func Slug(title string, existing map[string]int) string {
+	var b strings.Builder
+	hyphen := false
+	for _, c := range strings.ToLower(title) {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.IsMark(c) || c == '_':
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			hyphen = false
+		case unicode.IsSpace(c) || c == '-':
+			hyphen = true
+		}
+	}
+	if b.Len() == 0 {
+		b.WriteString("section")
+	}
+	return unique(b.String(), existing)
+}
+
+// unique records anchor in existing and returns it, with a numeric suffix if
+// it is already taken.
+func unique(anchor string, existing map[string]int) string {
+	if existing == nil {
+		return anchor
+	}
+	id, n := anchor, existing[anchor]
+	for n > 0 {
+		id = anchor + "-" + strconv.Itoa(n)
+		if existing[id] == 0 {
+			break
+		}
+		n++
+	}
+	existing[anchor] = n + 1
+	if id != anchor {
+		existing[id]++
+	}
+	return id
+}
+
+// SectionAnchors returns the anchor of every section of doc, which the HTML
+// renderer uses as the id of its heading. A section with an alias is
+// anchored at the alias, and the others at the [Slug] of their title.
+// Aliases are given out first, in document order, so that they keep their
+// anchor when a title slugs to the same text; a repeated alias gets a
+// suffix like a repeated slug.
+func SectionAnchors(doc *Document) map[*Section]string {
+	var sections []*Section
+	var walk func(nodes []Node)
+	walk = func(nodes []Node) {
+		for _, node := range nodes {
+			if s, ok := node.(*Section); ok {
+				sections = append(sections, s)
+				walk(s.Children)
+			}
+		}
+	}
+	walk(doc.Children)
+
+	existing := map[string]int{}
+	anchors := make(map[*Section]string, len(sections))
+	for _, s := range sections {
+		if s.Alias != "" {
+			anchors[s] = unique(s.Alias, existing)
+		}
+	}
+	for _, s := range sections {
+		if s.Alias == "" {
+			anchors[s] = Slug(s.Title, existing)
+		}
+	}
+	return anchors
+}
+
+// Anchors returns the anchors of the headings of src, as [SectionAnchors]
+// gives them, mapped to the range of the heading.
+func Anchors(src []byte) map[string]Range {
+	doc, err := parseLenient(src)
+	if err != nil {
+		return nil
+	}
+	anchors := map[string]Range{}
+	for s, anchor := range SectionAnchors(doc) {
+		anchors[anchor] = s.Heading
+	}
+	return anchors
+}
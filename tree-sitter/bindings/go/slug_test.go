@@ -0,0 +1,72 @@
+package tree_sitter_sand_test
+
+import (
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+func TestSlug(t *testing.T) {
+	for _, test := range []struct{ title, want string }{
+		{"Getting Started", "getting-started"},
+		{"  What's new?  ", "whats-new"},
+		{"C++ & Go", "c-go"},
+		{"snake_case -- kebab-case", "snake_case-kebab-case"},
+		{"日本語の見出し", "日本語の見出し"},
+		{"Café Crème", "café-crème"},
+		{"v1.2", "v12"},
+		{"!!!", "section"},
+		{"", "section"},
+	} {
+		if got := tree_sitter_sand.Slug(test.title, nil); got != test.want {
+			t.Errorf("Slug(%q) = %q, want %q", test.title, got, test.want)
+		}
+	}
+}
+
+func TestSlugUnique(t *testing.T) {
+	existing := map[string]int{}
+	var got []string
+	for _, title := range []string{"Intro", "Intro", "Intro 1", "Intro", "?"} {
+		got = append(got, tree_sitter_sand.Slug(title, existing))
+	}
+	want := []string{"intro", "intro-1", "intro-1-1", "intro-2", "section"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("slugs %q, want %q", got, want)
+			break
+		}
+	}
+}
+
+func TestAnchors(t *testing.T) {
+	src := "#(en)\n\n## Setup\n#setup# Install\n## Install\n#a# One\n#a# Two\n"
+	anchors := tree_sitter_sand.Anchors([]byte(src))
+	want := map[string]string{
+		"setup":   "#setup# Install",
+		"setup-1": "## Setup",
+		"install": "## Install",
+		"a":       "#a# One",
+		"a-1":     "#a# Two",
+	}
+	if len(anchors) != len(want) {
+		t.Errorf("anchors %v, want %d", anchors, len(want))
+	}
+	for anchor, heading := range want {
+		r, ok := anchors[anchor]
+		if !ok {
+			t.Errorf("no anchor %q", anchor)
+			continue
+		}
+		if got := src[r.StartByte:r.EndByte]; got != heading {
+			t.Errorf("anchor %q is at %q, want %q", anchor, got, heading)
+		}
+	}
+}
+
+func TestAnchorsInvalidUTF8(t *testing.T) {
+	anchors := tree_sitter_sand.Anchors([]byte(invalidDoc))
+	if r, ok := anchors["title"]; !ok || r.StartByte != 7 || r.EndByte != 17 || len(anchors) != 2 {
+		t.Errorf("got %+v", anchors)
+	}
+}
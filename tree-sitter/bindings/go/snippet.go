@@ -0,0 +1,117 @@
+package tree_sitter_sand
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Snippet returns the start of the text of the section anchored at anchor,
+// as [SectionAnchors] gives anchors, for link previews and search results.
+//
+// The text is that of [PlainText] for the first defined name, without the
+// titles of the section and the sections inside it, and with every run of
+// whitespace, line break escapes included, collapsed to one space. Text
+// longer than maxRunes is cut at the last sentence end that keeps at least
+// half of it, or else at the last space or Japanese comma, or else after
+// maxRunes-1 runes, and "…" is appended; the result is never longer than
+// maxRunes. A maxRunes of zero or less means no limit.
+//
+// A section without text gives "". An error is returned if no section has
+// the anchor or src cannot be parsed.
+func Snippet(src []byte, anchor string, maxRunes int) (string, error) {
+	doc, err := Parse(src)
+	if err != nil {
+		return "", err
+	}
+	for s, a := range SectionAnchors(doc) {
+		if a == anchor {
+			return snippet(src, doc, s, maxRunes), nil
+		}
+	}
+	return "", fmt.Errorf("sand: no section is anchored at %q", anchor)
+}
+
+// SnippetAt is like [Snippet] for the innermost section that offset is in,
+// as for a hover at the cursor. Before the first heading it returns the start
+// of the text there.
+func SnippetAt(src []byte, offset uint, maxRunes int) (string, error) {
+	doc, err := Parse(src)
+	if err != nil {
+		return "", err
+	}
+	// The section offset is in is the last one whose heading starts before
+	// it: each section runs up to the next heading of the same or a lower
+	// level, and the sections inside it only start after a later heading.
+	var in *Section
+	var walk func(nodes []Node)
+	walk = func(nodes []Node) {
+		for _, node := range nodes {
+			if s, ok := node.(*Section); ok && s.Heading.StartByte <= offset {
+				in = s
+				walk(s.Children)
+			}
+		}
+	}
+	walk(doc.Children)
+	return snippet(src, doc, in, maxRunes), nil
+}
+
+// snippet returns the snippet of s, or of the text before the first section
+// if s is nil.
+func snippet(src []byte, doc *Document, s *Section, maxRunes int) string {
+	opts := TextOptions{}
+	if len(doc.Names) > 0 {
+		opts.Name = doc.Names[0]
+	}
+	t := &textBuilder{src: src, m: &TextMap{}}
+	texts(doc, opts, func(r Range, section *Section, heading bool) {
+		switch {
+		case heading:
+		case s == nil && section == nil,
+			s != nil && r.StartByte >= s.Range.StartByte && r.EndByte <= s.Range.EndByte:
+			t.piece(" ", r)
+		}
+	})
+	return truncate(strings.Join(strings.Fields(t.out.String()), " "), maxRunes)
+}
+
+// truncate cuts text to at most maxRunes runes, as [Snippet] describes.
+func truncate(text string, maxRunes int) string {
+	if maxRunes <= 0 || utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+
+	// Find where the first maxRunes-1 runes end, and the last sentence
+	// and word boundaries before that.
+	var end, sentence, word int
+	n := 0
+	for i, c := range text {
+		if n == maxRunes-1 {
+			end = i
+			break
+		}
+		n++
+		next := i + utf8.RuneLen(c)
+		switch {
+		case strings.ContainsRune("。！？", c):
+			sentence = next
+		case strings.ContainsRune(".!?", c) && next < len(text) && text[next] == ' ':
+			sentence = next
+		case c == ' ':
+			word = i
+		case strings.ContainsRune("、，", c):
+			word = next
+		}
+	}
+	if text[end] == ' ' {
+		word = end
+	}
+	switch {
+	case utf8.RuneCountInString(text[:sentence]) >= (maxRunes-1)/2 && sentence > 0:
+		end = sentence
+	case word > 0:
+		end = word
+	}
+	return strings.TrimRight(text[:end], " 、，") + "…"
+}
@@ -0,0 +1,126 @@
+package tree_sitter_sand_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+const snippetDoc = `#(en, ja)
+
+#[Preamble.][前書き。]
+
+#intro# Introduction
+
+#[Sand is a markup language for documents in several languages. Each sentence is written in all of them side by side.][Sandは複数の言語で文書を書くためのマークアップ言語です。各文をすべての言語で並べて書きます。]
+Notes for the author are left out.
+#{{See below\nfor more}}
+
+#details## Details
+
+#[Details come later. They are not written yet, as the format is still changing.][詳細は後で。]
+
+## Empty
+
+## Last
+
+#[Supercalifragilisticexpialidocious][ありがとうございました]
+`
+
+func TestSnippet(t *testing.T) {
+	for _, tt := range []struct {
+		anchor   string
+		maxRunes int
+		want     string
+	}{
+		// Titles are left out, and the text of nested sections follows.
+		{"intro", 0, "Sand is a markup language for documents in several languages. Each sentence is written in all of them side by side. See below for more Details come later. They are not written yet, as the format is still changing."},
+		{"intro", 1000, "Sand is a markup language for documents in several languages. Each sentence is written in all of them side by side. See below for more Details come later. They are not written yet, as the format is still changing."},
+		// The last sentence end that keeps half of the text.
+		{"intro", 100, "Sand is a markup language for documents in several languages.…"},
+		{"intro", 150, "Sand is a markup language for documents in several languages. Each sentence is written in all of them side by side.…"},
+		// Without one, the last space.
+		{"intro", 30, "Sand is a markup language for…"},
+		{"intro", 31, "Sand is a markup language for…"},
+		{"details", 50, "Details come later. They are not written yet, as…"},
+		{"details", 40, "Details come later.…"},
+		{"details", 8, "Details…"},
+		{"empty", 10, ""},
+		// A word longer than the limit is cut inside.
+		{"last", 10, "Supercali…"},
+		{"last", 1, "…"},
+	} {
+		got, err := tree_sitter_sand.Snippet([]byte(snippetDoc), tt.anchor, tt.maxRunes)
+		if err != nil {
+			t.Fatalf("%s %d: %v", tt.anchor, tt.maxRunes, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s %d: got %q, want %q", tt.anchor, tt.maxRunes, got, tt.want)
+		}
+		if n := utf8.RuneCountInString(got); tt.maxRunes > 0 && n > tt.maxRunes {
+			t.Errorf("%s %d: got %d runes", tt.anchor, tt.maxRunes, n)
+		}
+	}
+
+	if _, err := tree_sitter_sand.Snippet([]byte(snippetDoc), "missing", 10); err == nil {
+		t.Error("Snippet found a section for a missing anchor")
+	}
+}
+
+func TestSnippetJapanese(t *testing.T) {
+	src := []byte(`#(ja)
+
+#about# 概要
+
+#[Sandは複数の言語で文書を書くためのマークアップ言語です。各文をすべての言語で並べて書きます。]
+
+#thanks# 謝辞
+
+#[協力してくれた皆さん、本当にありがとうございました]
+`)
+	for _, tt := range []struct {
+		anchor   string
+		maxRunes int
+		want     string
+	}{
+		{"about", 0, "Sandは複数の言語で文書を書くためのマークアップ言語です。各文をすべての言語で並べて書きます。"},
+		{"about", 40, "Sandは複数の言語で文書を書くためのマークアップ言語です。…"},
+		// Without a sentence end or a comma the text is cut between runes.
+		{"about", 20, "Sandは複数の言語で文書を書くための…"},
+		{"thanks", 15, "協力してくれた皆さん…"},
+		{"thanks", 8, "協力してくれた…"},
+	} {
+		got, err := tree_sitter_sand.Snippet(src, tt.anchor, tt.maxRunes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s %d: got %q, want %q", tt.anchor, tt.maxRunes, got, tt.want)
+		}
+	}
+}
+
+func TestSnippetAt(t *testing.T) {
+	src := []byte(snippetDoc)
+	for _, tt := range []struct {
+		at   string
+		want string
+	}{
+		{"#(en", "Preamble."},
+		{"Notes for", "Sand is a…"},
+		{"#details", "Details…"},
+		{"## Empty", ""},
+		{"\n\n## Last", ""},
+	} {
+		offset := uint(strings.Index(snippetDoc, tt.at))
+		got, err := tree_sitter_sand.SnippetAt(src, offset, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("at %q: got %q, want %q", tt.at, got, tt.want)
+		}
+	}
+}
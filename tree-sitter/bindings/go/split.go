@@ -0,0 +1,173 @@
+package tree_sitter_sand
+
+// Fragment is a part of a document that [Split] cut out to publish on its
+// own.
+type Fragment struct {
+	// Anchor is the anchor of the section the fragment starts with, as
+	// [SectionAnchors] gives it for the whole document. It is "" for the
+	// index, the fragment with the text before the first heading.
+	Anchor string
+	// Parent is the Anchor of the fragment holding the section this one is
+	// nested in, or "" for the index.
+	Parent string
+	// Item is the outline item of the section, with ranges in the
+	// document. It is nil for the index.
+	Item *OutlineItem
+
+	// Header is what the fragment needs from the document to parse on its
+	// own: the name definition, followed by a blank line. It is empty for
+	// the index, which holds the definition, and when the document has
+	// none.
+	Header []byte
+	// Body is the source of the fragment, from its heading up to the next
+	// fragment. The bodies of all fragments make up the document.
+	Body  []byte
+	Range Range
+
+	// Anchors maps the document anchor of every heading in the fragment to
+	// the anchor it has when the fragment is parsed on its own, which
+	// differs where a title slugs the same as one in an earlier fragment.
+	Anchors map[string]string
+	// Links lists the selectors in the fragment whose target is in another
+	// fragment. Their ranges are in the document.
+	Links []FragmentLink
+}
+
+// FragmentLink is a selector whose target is in another fragment.
+type FragmentLink struct {
+	Link
+	// Fragment is the Anchor of the fragment holding the target.
+	Fragment string
+}
+
+// Source returns Header followed by Body: a document of its own.
+func (f Fragment) Source() []byte {
+	return append(append([]byte{}, f.Header...), f.Body...)
+}
+
+// Split cuts src into fragments to publish separately: the index, which is
+// empty if src starts with a heading, then one per section up to level, in
+// document order. A level of 1 gives a fragment per top-level section;
+// deeper sections are part of the fragment of the section they are in. A
+// section with a fragment of its own leaves it out of the fragment of its
+// parent.
+//
+// Split returns nil if src cannot be parsed. Syntax errors are kept in the
+// fragments they are in.
+func Split(src []byte, level int) []Fragment {
+	doc, err := parseLenient(src)
+	if err != nil {
+		return nil
+	}
+	level = max(level, 1)
+	anchors := SectionAnchors(doc)
+	lines := newLineIndex(src)
+
+	var header []byte
+	if doc.NamesRange.EndByte > doc.NamesRange.StartByte {
+		header = append(src[doc.NamesRange.StartByte:doc.NamesRange.EndByte:doc.NamesRange.EndByte], "\n\n"...)
+	}
+
+	// Cut at every heading up to level.
+	frags := []Fragment{{}}
+	// sections lists the sections in each fragment.
+	sections := [][]*Section{nil}
+	var walk func(nodes []Node, parent int, items []OutlineItem)
+	walk = func(nodes []Node, parent int, items []OutlineItem) {
+		i := 0
+		for _, node := range nodes {
+			s, ok := node.(*Section)
+			if !ok {
+				continue
+			}
+			item := &items[i]
+			i++
+			frag := parent
+			if s.Level <= level {
+				frag = len(frags)
+				frags = append(frags, Fragment{
+					Anchor: anchors[s],
+					Parent: frags[parent].Anchor,
+					Item:   item,
+					Header: header,
+					Range:  Range{StartByte: s.Heading.StartByte},
+				})
+				sections = append(sections, nil)
+			}
+			sections[frag] = append(sections[frag], s)
+			walk(s.Children, frag, item.Children)
+		}
+	}
+	walk(doc.Children, 0, outline(doc.Children, anchors))
+	for i := range frags {
+		end := uint(len(src))
+		if i+1 < len(frags) {
+			end = frags[i+1].Range.StartByte
+		}
+		frags[i].Range = lines.rangeOf(frags[i].Range.StartByte, end)
+		frags[i].Body = src[frags[i].Range.StartByte:end]
+	}
+
+	// Parse each fragment on its own to find the anchors it gives its
+	// headings there.
+	for i, f := range frags {
+		if len(sections[i]) == 0 {
+			continue
+		}
+		local, err := parseLenient(f.Source())
+		if err != nil {
+			continue
+		}
+		byHeading := map[uint]string{}
+		for ls, anchor := range SectionAnchors(local) {
+			byHeading[ls.Heading.StartByte] = anchor
+		}
+		frags[i].Anchors = map[string]string{}
+		shift := f.Range.StartByte - uint(len(f.Header))
+		for _, s := range sections[i] {
+			frags[i].Anchors[anchors[s]] = byHeading[s.Heading.StartByte-shift]
+		}
+	}
+
+	// Find the selectors that reach into another fragment.
+	fragmentOf := func(offset uint) int {
+		i := 0
+		for i+1 < len(frags) && frags[i+1].Range.StartByte <= offset {
+			i++
+		}
+		return i
+	}
+	r := newRefs(doc)
+	var links func(container Node, children []Node)
+	links = func(container Node, children []Node) {
+		for _, child := range children {
+			switch child := child.(type) {
+			case *Section:
+				links(child, child.Children)
+			case *Paragraph:
+				for _, span := range child.Spans {
+					if span.Kind != SpanSelector {
+						continue
+					}
+					from := Node(doc)
+					if span.Local {
+						from = container
+					}
+					target, code, _ := r.resolve(span, from, nil)
+					if code != "" || target == doc {
+						continue
+					}
+					in, to := fragmentOf(span.Range.StartByte), fragmentOf(target.Span().StartByte)
+					if in != to {
+						frags[in].Links = append(frags[in].Links, FragmentLink{
+							Link:     link(src, span),
+							Fragment: frags[to].Anchor,
+						})
+					}
+				}
+			}
+		}
+	}
+	links(doc, doc.Children)
+	return frags
+}
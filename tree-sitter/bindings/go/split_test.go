@@ -0,0 +1,124 @@
+package tree_sitter_sand_test
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+const splitDoc = `#(en, ja)
+
+Preamble. #.usage.s.en
+
+#usage# Usage
+
+#s[Run it.][実行する。] #.ref.r.en
+
+#details## Details
+
+#d[Deep.][深い。] #.usage.s.en
+
+## Usage
+
+#[Again.][再び。]
+
+#ref# Reference
+
+#r[See above.][上記参照。]
+`
+
+// summary describes the fragments as "anchor<parent body-start anchors links".
+func summary(frags []tree_sitter_sand.Fragment) []string {
+	var out []string
+	for _, f := range frags {
+		var links []string
+		for _, l := range f.Links {
+			links = append(links, l.Target+">"+l.Fragment)
+		}
+		keys := slices.Sorted(maps.Keys(f.Anchors))
+		var anchors []string
+		for _, k := range keys {
+			anchors = append(anchors, k+"="+f.Anchors[k])
+		}
+		out = append(out, fmt.Sprintf("%s<%s %q %v %v", f.Anchor, f.Parent, f.Body[:min(len(f.Body), 9)], anchors, links))
+	}
+	return out
+}
+
+func TestSplit(t *testing.T) {
+	for _, tt := range []struct {
+		level int
+		want  []string
+	}{
+		{1, []string{
+			`< "#(en, ja)" [] [usage.s.en>usage]`,
+			`usage< "#usage# U" [details=details usage=usage] [ref.r.en>ref]`,
+			`usage-1< "## Usage\n" [usage-1=usage] []`,
+			`ref< "#ref# Ref" [ref=ref] []`,
+		}},
+		{2, []string{
+			`< "#(en, ja)" [] [usage.s.en>usage]`,
+			`usage< "#usage# U" [usage=usage] [ref.r.en>ref]`,
+			`details<usage "#details#" [details=details] [usage.s.en>usage]`,
+			`usage-1< "## Usage\n" [usage-1=usage] []`,
+			`ref< "#ref# Ref" [ref=ref] []`,
+		}},
+	} {
+		frags := tree_sitter_sand.Split([]byte(splitDoc), tt.level)
+		if got := summary(frags); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("level %d: got\n%q\nwant\n%q", tt.level, got, tt.want)
+		}
+		for _, f := range frags[1:] {
+			if string(f.Header) != "#(en, ja)\n\n" {
+				t.Errorf("level %d: %s: header %q", tt.level, f.Anchor, f.Header)
+			}
+			if f.Item == nil || f.Item.Anchor != f.Anchor || f.Item.Heading.StartByte != f.Range.StartByte {
+				t.Errorf("level %d: %s: outline item %+v", tt.level, f.Anchor, f.Item)
+			}
+		}
+	}
+}
+
+func TestSplitCorpus(t *testing.T) {
+	for name, src := range corpus(t) {
+		doc := mustParse(t, string(src))
+		for level := 1; level <= 3; level++ {
+			frags := tree_sitter_sand.Split(src, level)
+			var all []byte
+			for _, f := range frags {
+				if !bytes.Equal(src[f.Range.StartByte:f.Range.EndByte], f.Body) {
+					t.Errorf("%s %d: %s: range %d-%d is not the body", name, level, f.Anchor, f.Range.StartByte, f.Range.EndByte)
+				}
+				all = append(all, f.Body...)
+
+				if len(doc.Errors) > 0 {
+					continue
+				}
+				local := mustParse(t, string(f.Source()))
+				if len(local.Errors) > 0 {
+					t.Errorf("%s %d: fragment %q does not parse: %v\n%s", name, level, f.Anchor, local.Errors, f.Source())
+				}
+				if !slices.Equal(local.Names, doc.Names) {
+					t.Errorf("%s %d: fragment %q has names %q, want %q", name, level, f.Anchor, local.Names, doc.Names)
+				}
+			}
+			if !bytes.Equal(all, src) {
+				t.Errorf("%s %d: the fragments do not make up the document:\n%s", name, level, all)
+			}
+		}
+	}
+}
+
+func TestSplitInvalidUTF8(t *testing.T) {
+	frags := tree_sitter_sand.Split([]byte(invalidDoc), 1)
+	if len(frags) != 3 || frags[1].Anchors["title"] != "title" {
+		t.Fatalf("got %+v", frags)
+	}
+	if string(frags[1].Body) != invalidDoc[7:52] {
+		t.Errorf("got body %q", frags[1].Body)
+	}
+}
@@ -0,0 +1,181 @@
+package tree_sitter_sand
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// TextStats are counts of the text of a document, as [PlainText] extracts
+// it: markup, selectors and escapes do not count.
+type TextStats struct {
+	// Words counts runs of letters and digits, which may be joined by
+	// punctuation as in "don't" or "3.14". Every Han, Hiragana and Katakana
+	// rune is a word of its own, since Chinese and Japanese do not put
+	// spaces between words.
+	Words int
+	// Characters counts the runes other than whitespace.
+	Characters int
+	// Sentences is a best-effort count of the sentences in the contents of
+	// sentence definitions and apply-all blocks, and in prose if it is
+	// included. A sentence ends at `.`, `!` or `?` followed by whitespace,
+	// at `。`, `！` or `？`, and at the end of each content. Heading titles
+	// are not sentences.
+	Sentences int
+	// Sections counts headings.
+	Sections int
+}
+
+// ReadingTime returns how long reading Words takes at wordsPerMinute, or at
+// 200 words a minute if it is zero or less, rounded to the second.
+func (s TextStats) ReadingTime(wordsPerMinute int) time.Duration {
+	if wordsPerMinute <= 0 {
+		wordsPerMinute = 200
+	}
+	d := time.Duration(s.Words) * time.Minute / time.Duration(wordsPerMinute)
+	return d.Round(time.Second)
+}
+
+func (s *TextStats) add(t TextStats) {
+	s.Words += t.Words
+	s.Characters += t.Characters
+	s.Sentences += t.Sentences
+	s.Sections += t.Sections
+}
+
+// Stats counts the text of src that [PlainText] returns for opts. Select a
+// name with opts.Name to count one language of a multilingual document.
+func Stats(src []byte, opts TextOptions) TextStats {
+	var stats TextStats
+	doc, err := parseLenient(src)
+	if err != nil {
+		return stats
+	}
+	texts(doc, opts, func(r Range, _ *Section, heading bool) {
+		stats.add(countText(src, r, heading))
+	})
+	return stats
+}
+
+// SectionStats are the statistics of a section, as returned by
+// [StatsByOutline].
+type SectionStats struct {
+	Item OutlineItem
+	// Own counts the heading and the text before the first subsection, and
+	// Total the whole section including subsections.
+	Own, Total TextStats
+	Children   []SectionStats
+}
+
+// StatsByOutline returns the statistics of every section of src, nested like
+// [Outline]. Text before the first heading is in no section.
+func StatsByOutline(src []byte, opts TextOptions) []SectionStats {
+	doc, err := parseLenient(src)
+	if err != nil {
+		return nil
+	}
+	own := map[*Section]*TextStats{}
+	texts(doc, opts, func(r Range, section *Section, heading bool) {
+		if section == nil {
+			return
+		}
+		if own[section] == nil {
+			own[section] = &TextStats{}
+		}
+		own[section].add(countText(src, r, heading))
+	})
+
+	var walk func(nodes []Node) []SectionStats
+	walk = func(nodes []Node) []SectionStats {
+		var out []SectionStats
+		for _, node := range nodes {
+			s, ok := node.(*Section)
+			if !ok {
+				continue
+			}
+			stats := SectionStats{Children: walk(s.Children)}
+			if own[s] != nil {
+				stats.Own = *own[s]
+			}
+			stats.Own.Sections = 1
+			stats.Total = stats.Own
+			for _, child := range stats.Children {
+				stats.Total.add(child.Total)
+			}
+			out = append(out, stats)
+		}
+		return out
+	}
+	stats := walk(doc.Children)
+
+	// Pair the statistics with the outline, which is built from the same
+	// sections in the same order.
+	var pair func(stats []SectionStats, items []OutlineItem)
+	pair = func(stats []SectionStats, items []OutlineItem) {
+		for i := range stats {
+			stats[i].Item = items[i]
+			pair(stats[i].Children, items[i].Children)
+		}
+	}
+	pair(stats, outline(doc.Children, SectionAnchors(doc)))
+	return stats
+}
+
+// countText counts a piece of text of [texts].
+func countText(src []byte, r Range, heading bool) TextStats {
+	t := &textBuilder{src: src, m: &TextMap{}}
+	t.piece(" ", r)
+	text := t.out.String()
+
+	var stats TextStats
+	if heading {
+		stats.Sections = 1
+	}
+	inWord := false
+	// pending is set while there is text after the last sentence end.
+	pending := false
+	runes := []rune(text)
+	for i, c := range runes {
+		switch {
+		case unicode.IsSpace(c):
+			inWord = false
+			continue
+		case unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			stats.Words++
+			inWord = false
+			pending = true
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.IsMark(c):
+			if !inWord {
+				stats.Words++
+			}
+			inWord = true
+			pending = true
+		case strings.ContainsRune("。！？", c):
+			inWord = false
+			if pending {
+				stats.Sentences++
+				pending = false
+			}
+		case strings.ContainsRune(".!?", c) && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])):
+			inWord = false
+			if pending {
+				stats.Sentences++
+				pending = false
+			}
+		default:
+			// Other punctuation ends a word unless a letter or digit
+			// follows.
+			if inWord && (i+1 == len(runes) || !(unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]))) {
+				inWord = false
+			}
+		}
+		stats.Characters++
+	}
+	if pending {
+		stats.Sentences++
+	}
+	if heading {
+		stats.Sentences = 0
+	}
+	return stats
+}
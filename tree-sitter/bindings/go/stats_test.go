@@ -0,0 +1,122 @@
+package tree_sitter_sand_test
+
+import (
+	"testing"
+	"time"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+)
+
+const englishDoc = `#(en)
+
+## Getting started
+
+Prose is not counted: #[Sand keeps translations side by side. It's simple!]
+
+#{{Version 3.14 is out... Try it?}}
+
+### Details
+
+See #.intro for more. #[One more sentence without a full stop]
+`
+
+const japaneseDoc = `#(ja)
+
+## はじめに
+
+#[サンドは翻訳を並べて書きます。簡単です！]
+
+### 詳細
+
+#[カタカナとひらがな]
+`
+
+const mixedDoc = `#(en, ja)
+
+## Intro
+
+#[Hello, world.][こんにちは、世界。]
+
+#{[ja], {日本語だけ}}
+`
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		opts tree_sitter_sand.TextOptions
+		want tree_sitter_sand.TextStats
+	}{
+		// Words: Getting started (2), Sand keeps translations side by side
+		// It's simple (8), Version 3.14 is out Try it (6), Details (1),
+		// One more sentence without a full stop (7).
+		{"english", englishDoc, tree_sitter_sand.TextOptions{}, tree_sitter_sand.TextStats{
+			Words: 24, Characters: 120, Sentences: 5, Sections: 2,
+		}},
+		// The prose adds "Prose is not counted:", "See" and "for more.",
+		// each ending a sentence.
+		{"english with prose", englishDoc, tree_sitter_sand.TextOptions{Prose: true}, tree_sitter_sand.TextStats{
+			Words: 31, Characters: 149, Sentences: 8, Sections: 2,
+		}},
+		// Every kana and kanji is a word; 、。！ are characters only.
+		{"japanese", japaneseDoc, tree_sitter_sand.TextOptions{}, tree_sitter_sand.TextStats{
+			Words: 33, Characters: 35, Sentences: 3, Sections: 2,
+		}},
+		{"mixed", mixedDoc, tree_sitter_sand.TextOptions{}, tree_sitter_sand.TextStats{
+			Words: 15, Characters: 31, Sentences: 3, Sections: 1,
+		}},
+		{"mixed en", mixedDoc, tree_sitter_sand.TextOptions{Name: "en"}, tree_sitter_sand.TextStats{
+			Words: 3, Characters: 17, Sentences: 1, Sections: 1,
+		}},
+		{"mixed ja", mixedDoc, tree_sitter_sand.TextOptions{Name: "ja"}, tree_sitter_sand.TextStats{
+			Words: 13, Characters: 19, Sentences: 2, Sections: 1,
+		}},
+	}
+	for _, tt := range tests {
+		if got := tree_sitter_sand.Stats([]byte(tt.src), tt.opts); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	stats := tree_sitter_sand.TextStats{Words: 500}
+	if got := stats.ReadingTime(250); got != 2*time.Minute {
+		t.Errorf("ReadingTime(250) = %v, want 2m", got)
+	}
+	if got := stats.ReadingTime(0); got != 2*time.Minute+30*time.Second {
+		t.Errorf("ReadingTime(0) = %v, want 2m30s", got)
+	}
+	if got := (tree_sitter_sand.TextStats{Words: 1}).ReadingTime(7); got != 9*time.Second {
+		t.Errorf("rounding: got %v, want 9s", got)
+	}
+}
+
+func TestStatsByOutline(t *testing.T) {
+	stats := tree_sitter_sand.StatsByOutline([]byte(englishDoc), tree_sitter_sand.TextOptions{})
+	if len(stats) != 1 || len(stats[0].Children) != 1 {
+		t.Fatalf("got %+v, want one section with one child", stats)
+	}
+	top, child := stats[0], stats[0].Children[0]
+	if top.Item.Title != "Getting started" || child.Item.Title != "Details" {
+		t.Errorf("items = %q, %q", top.Item.Title, child.Item.Title)
+	}
+	if want := (tree_sitter_sand.TextStats{Words: 16, Characters: 82, Sentences: 4, Sections: 1}); top.Own != want {
+		t.Errorf("own = %+v, want %+v", top.Own, want)
+	}
+	if want := (tree_sitter_sand.TextStats{Words: 8, Characters: 38, Sentences: 1, Sections: 1}); child.Own != want || child.Total != want {
+		t.Errorf("child = %+v / %+v, want %+v", child.Own, child.Total, want)
+	}
+	if total := tree_sitter_sand.Stats([]byte(englishDoc), tree_sitter_sand.TextOptions{}); top.Total != total {
+		t.Errorf("total = %+v, want the document's %+v", top.Total, total)
+	}
+}
+
+func TestStatsInvalidUTF8(t *testing.T) {
+	if got := tree_sitter_sand.Stats([]byte(invalidDoc), tree_sitter_sand.TextOptions{}); got.Sections != 2 || got.Sentences != 2 {
+		t.Errorf("got %+v", got)
+	}
+	if got := tree_sitter_sand.StatsByOutline([]byte(invalidDoc), tree_sitter_sand.TextOptions{}); len(got) != 2 || got[0].Own.Sentences != 1 {
+		t.Errorf("got %+v", got)
+	}
+}
@@ -0,0 +1,72 @@
+package tree_sitter_sand
+
+import (
+	"errors"
+	"fmt"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// version is the grammar version from tree-sitter.json. Release builds can
+// stamp it with
+//
+//	-ldflags "-X github.com/satler-git/sand-markup/bindings/go.version=1.2.3"
+var version = "0.1.0"
+
+// Version returns the version of the grammar this package was built from.
+// Queries written for another major version, or another minor version before
+// 1.0, may not compile against it.
+func Version() string { return version }
+
+// ABIVersion returns the tree-sitter ABI version of the compiled parser.
+func ABIVersion() int { return int(language().AbiVersion()) }
+
+// CompatibilityError reports a parser whose ABI version the go-tree-sitter
+// runtime cannot load.
+type CompatibilityError struct {
+	// ABI is the ABI version of the parser.
+	ABI int
+	// MinABI and MaxABI are the range of versions the runtime supports.
+	MinABI, MaxABI int
+}
+
+func (e *CompatibilityError) Error() string {
+	supports := fmt.Sprintf("%d–%d", e.MinABI, e.MaxABI)
+	if e.MinABI == e.MaxABI {
+		supports = fmt.Sprint(e.MinABI)
+	}
+	hint := "regenerate the parser with an older tree-sitter CLI or upgrade go-tree-sitter"
+	if e.ABI < e.MinABI {
+		hint = "regenerate the parser with a newer tree-sitter CLI"
+	}
+	return fmt.Sprintf("sand: parser ABI %d, runtime supports %s; %s", e.ABI, supports, hint)
+}
+
+// CheckCompatibility reports whether the go-tree-sitter runtime can load
+// lang. The error is a [*CompatibilityError] when the ABI versions do not
+// match.
+func CheckCompatibility(lang *tree_sitter.Language) error {
+	if lang == nil || lang.Inner == nil {
+		return errors.New("sand: language is nil")
+	}
+	abi := int(lang.AbiVersion())
+	if abi < tree_sitter.MIN_COMPATIBLE_LANGUAGE_VERSION || abi > tree_sitter.LANGUAGE_VERSION {
+		return &CompatibilityError{
+			ABI:    abi,
+			MinABI: tree_sitter.MIN_COMPATIBLE_LANGUAGE_VERSION,
+			MaxABI: tree_sitter.LANGUAGE_VERSION,
+		}
+	}
+	return nil
+}
+
+// MustLanguage returns the Sand language, panicking with the error from
+// [CheckCompatibility] if the runtime cannot load it. Call it during
+// initialization to fail early instead of at the first parse.
+func MustLanguage() *tree_sitter.Language {
+	lang := language()
+	if err := CheckCompatibility(lang); err != nil {
+		panic(err)
+	}
+	return lang
+}
@@ -0,0 +1,82 @@
+package tree_sitter_sand_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestVersionMatchesMetadata(t *testing.T) {
+	data, err := os.ReadFile("../../tree-sitter.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Metadata struct {
+			Version string `json:"version"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	if v := tree_sitter_sand.Version(); v != config.Metadata.Version {
+		t.Errorf("Version() = %q, tree-sitter.json has %q", v, config.Metadata.Version)
+	}
+
+	m := tree_sitter_sand.MustLanguage().Metadata()
+	if m == nil {
+		t.Fatal("compiled parser has no metadata")
+	}
+	if v := fmt.Sprintf("%d.%d.%d", m.MajorVersion, m.MinorVersion, m.PatchVersion); v != tree_sitter_sand.Version() {
+		t.Errorf("compiled parser is version %s, Version() = %q; regenerate src/parser.c", v, tree_sitter_sand.Version())
+	}
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	lang := tree_sitter_sand.MustLanguage()
+	if err := tree_sitter_sand.CheckCompatibility(lang); err != nil {
+		t.Fatal(err)
+	}
+	if abi := tree_sitter_sand.ABIVersion(); abi != int(lang.AbiVersion()) || abi == 0 {
+		t.Errorf("ABIVersion() = %d, language has %d", abi, lang.AbiVersion())
+	}
+	for _, lang := range []*tree_sitter.Language{nil, {}} {
+		if err := tree_sitter_sand.CheckCompatibility(lang); err == nil {
+			t.Errorf("CheckCompatibility(%v) succeeded", lang)
+		}
+	}
+}
+
+func TestCompatibilityError(t *testing.T) {
+	for _, test := range []struct {
+		err  tree_sitter_sand.CompatibilityError
+		want string
+	}{
+		{
+			tree_sitter_sand.CompatibilityError{ABI: 15, MinABI: 13, MaxABI: 14},
+			"sand: parser ABI 15, runtime supports 13–14; regenerate the parser with an older tree-sitter CLI or upgrade go-tree-sitter",
+		},
+		{
+			tree_sitter_sand.CompatibilityError{ABI: 12, MinABI: 13, MaxABI: 15},
+			"sand: parser ABI 12, runtime supports 13–15; regenerate the parser with a newer tree-sitter CLI",
+		},
+		{
+			tree_sitter_sand.CompatibilityError{ABI: 15, MinABI: 14, MaxABI: 14},
+			"sand: parser ABI 15, runtime supports 14; regenerate the parser with an older tree-sitter CLI or upgrade go-tree-sitter",
+		},
+	} {
+		var err error = &test.err
+		if got := err.Error(); got != test.want {
+			t.Errorf("%+v: got %q, want %q", test.err, got, test.want)
+		}
+		var target *tree_sitter_sand.CompatibilityError
+		if !errors.As(err, &target) || target.ABI != test.err.ABI {
+			t.Errorf("errors.As = %+v", target)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package tree_sitter_sand
+
+import tree_sitter "github.com/tree-sitter/go-tree-sitter"
+
+// WalkAction tells [Walk] how to go on after visiting a node.
+type WalkAction int
+
+const (
+	// WalkContinue goes on to the children of the node.
+	WalkContinue WalkAction = iota
+	// WalkSkipChildren goes on to the next sibling of the node, or of its
+	// closest ancestor that has one.
+	WalkSkipChildren
+	// WalkStop ends the walk.
+	WalkStop
+)
+
+// Walk calls visit for every node of tree in document order, parents before
+// their children. depth is 0 for the root node and one more for each
+// ancestor below it.
+//
+// Walk moves a single [tree_sitter.TreeCursor] instead of recursing, so the
+// depth of the tree does not matter. The node is only allocated for the
+// call; visit may keep it, and can get its text with [NoCopyText].
+func Walk(tree *tree_sitter.Tree, visit func(n *tree_sitter.Node, depth int) WalkAction) {
+	walk(tree, false, visit)
+}
+
+// WalkNamed is like [Walk] but only visits named nodes. The children of
+// anonymous nodes are still visited, and depth still counts anonymous
+// ancestors.
+func WalkNamed(tree *tree_sitter.Tree, visit func(n *tree_sitter.Node, depth int) WalkAction) {
+	walk(tree, true, visit)
+}
+
+// NoCopyText returns the text of node as a subslice of src, the source it
+// was parsed from. Unlike [tree_sitter.Node.Utf8Text] it does not copy, so
+// the result must not be modified unless src may be.
+func NoCopyText(node *tree_sitter.Node, src []byte) []byte {
+	return src[node.StartByte():node.EndByte()]
+}
+
+func walk(tree *tree_sitter.Tree, named bool, visit func(n *tree_sitter.Node, depth int) WalkAction) {
+	cursor := tree.Walk()
+	defer cursor.Close()
+
+	depth := 0
+	for {
+		action := WalkContinue
+		if node := cursor.Node(); !named || node.IsNamed() {
+			action = visit(node, depth)
+		}
+		switch action {
+		case WalkStop:
+			return
+		case WalkContinue:
+			if cursor.GotoFirstChild() {
+				depth++
+				continue
+			}
+		}
+		for !cursor.GotoNextSibling() {
+			if depth == 0 || !cursor.GotoParent() {
+				return
+			}
+			depth--
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package tree_sitter_sand_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	tree_sitter_sand "github.com/satler-git/sand-markup/bindings/go"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// recursiveWalk visits the nodes under n by child index.
+func recursiveWalk(n *tree_sitter.Node, depth int, visit func(n *tree_sitter.Node, depth int)) {
+	visit(n, depth)
+	for i := range n.ChildCount() {
+		recursiveWalk(n.Child(i), depth+1, visit)
+	}
+}
+
+func describeNode(n *tree_sitter.Node, depth int) string {
+	return fmt.Sprintf("%d %s %d-%d", depth, n.Kind(), n.StartByte(), n.EndByte())
+}
+
+func TestWalk(t *testing.T) {
+	for name, src := range corpus(t) {
+		tree := cst(t, src)
+		defer tree.Close()
+
+		var want, wantNamed []string
+		recursiveWalk(tree.RootNode(), 0, func(n *tree_sitter.Node, depth int) {
+			want = append(want, describeNode(n, depth))
+			if n.IsNamed() {
+				wantNamed = append(wantNamed, describeNode(n, depth))
+			}
+		})
+
+		var got, gotNamed []string
+		tree_sitter_sand.Walk(tree, func(n *tree_sitter.Node, depth int) tree_sitter_sand.WalkAction {
+			got = append(got, describeNode(n, depth))
+			return tree_sitter_sand.WalkContinue
+		})
+		tree_sitter_sand.WalkNamed(tree, func(n *tree_sitter.Node, depth int) tree_sitter_sand.WalkAction {
+			gotNamed = append(gotNamed, describeNode(n, depth))
+			return tree_sitter_sand.WalkContinue
+		})
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: Walk visited\n%q\nwant\n%q", name, got, want)
+		}
+		if !slices.Equal(gotNamed, wantNamed) {
+			t.Errorf("%s: WalkNamed visited\n%q\nwant\n%q", name, gotNamed, wantNamed)
+		}
+	}
+}
+
+func TestWalkActions(t *testing.T) {
+	src := []byte("#(en)\n\n## One\n\n#s[a]\n\n## Two\n\n#t[b]\n")
+	tree := cst(t, src)
+	defer tree.Close()
+
+	var kinds []string
+	tree_sitter_sand.WalkNamed(tree, func(n *tree_sitter.Node, depth int) tree_sitter_sand.WalkAction {
+		kinds = append(kinds, n.Kind())
+		if n.Kind() == string(tree_sitter_sand.KindSection) || n.Kind() == string(tree_sitter_sand.KindNameDefinition) {
+			return tree_sitter_sand.WalkSkipChildren
+		}
+		return tree_sitter_sand.WalkContinue
+	})
+	want := []string{"source_file", "name_definition", "section", "sentence_definition", "identifier", "string", "section", "sentence_definition", "identifier", "string"}
+	if !slices.Equal(kinds, want) {
+		t.Errorf("skipping children visited %q, want %q", kinds, want)
+	}
+
+	var texts []string
+	tree_sitter_sand.WalkNamed(tree, func(n *tree_sitter.Node, depth int) tree_sitter_sand.WalkAction {
+		if n.Kind() != string(tree_sitter_sand.KindString) {
+			return tree_sitter_sand.WalkContinue
+		}
+		texts = append(texts, string(src[n.StartByte():n.EndByte()]))
+		return tree_sitter_sand.WalkStop
+	})
+	if !slices.Equal(texts, []string{"a"}) {
+		t.Errorf("stopping visited %q, want the first string only", texts)
+	}
+
+	count := 0
+	tree_sitter_sand.Walk(tree, func(n *tree_sitter.Node, depth int) tree_sitter_sand.WalkAction {
+		count++
+		return tree_sitter_sand.WalkSkipChildren
+	})
+	if count != 1 {
+		t.Errorf("skipping the root visited %d nodes, want 1", count)
+	}
+}
+
+// TestWalkManySections walks thousands of nested sections. The grammar has
+// no nesting constructs, so they form a wide, flat tree rather than a deep
+// one; Walk does not recurse either way.
+func TestWalkManySections(t *testing.T) {
+	const n = 5000
+	var b strings.Builder
+	b.WriteString("#(en)\n\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "#%s Level %d\n#[text %d]\n", strings.Repeat("#", i), i, i)
+	}
+	src := []byte(b.String())
+	tree := cst(t, src)
+	defer tree.Close()
+
+	want := 0
+	recursiveWalk(tree.RootNode(), 0, func(*tree_sitter.Node, int) { want++ })
+	got, sections := 0, 0
+	tree_sitter_sand.Walk(tree, func(n *tree_sitter.Node, depth int) tree_sitter_sand.WalkAction {
+		got++
+		if n.Kind() == string(tree_sitter_sand.KindSection) {
+			sections++
+		}
+		return tree_sitter_sand.WalkContinue
+	})
+	if got != want || sections != n {
+		t.Errorf("visited %d nodes and %d sections, want %d and %d", got, sections, want, n)
+	}
+	if doc := mustParse(t, string(src)); len(doc.Children) != 1 {
+		t.Errorf("document has %d top-level sections, want 1", len(doc.Children))
+	}
+}
+
+func BenchmarkWalk(b *testing.B) {
+	src := generate(1 << 20)
+	tree := cst(b, src)
+	defer tree.Close()
+
+	b.Run("cursor", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			n := 0
+			tree_sitter_sand.Walk(tree, func(*tree_sitter.Node, int) tree_sitter_sand.WalkAction {
+				n++
+				return tree_sitter_sand.WalkContinue
+			})
+		}
+	})
+	b.Run("recursive", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			n := 0
+			recursiveWalk(tree.RootNode(), 0, func(*tree_sitter.Node, int) { n++ })
+		}
+	})
+}